@@ -33,6 +33,12 @@ type Cassandra struct {
 	TLS              bool              `json:"tls,omitempty"`
 	Port             int               `json:"port,omitempty"`
 	DeploymentSchema *DeploymentSchema `json:"deploymentSchema,omitempty"`
+	// a name of Kubernetes secret with the client certificate and private key under `tls.signedCRTFileName` and `tls.privateKeyFileName`
+	// the operator and the components authenticate to Cassandra with over mutual TLS
+	ClientCertSecretName string `json:"clientCertSecretName,omitempty"`
+	// Cassandra authenticates the operator and the components by the `clientCertSecretName` certificate only,
+	// no password is sent and `secretName` is not read
+	PasswordlessAuth bool `json:"passwordlessAuth,omitempty"`
 }
 
 type DeploymentSchema struct {
@@ -62,7 +68,7 @@ type CassandraServiceSpec struct {
 	Monitoring                 `json:"monitoringAgent"`
 	RobotTests                 `json:"robotTests"`
 	VaultRegistration          types.VaultRegistration                    `json:"vaultRegistration" common:"true"`
-	ServiceAccountName         string                                     `json:"serviceAccountName"`
+	ServiceAccountName         string                                     `json:"serviceAccountName,omitempty"`
 	IpV6                       bool                                       `json:"ipV6,omitempty"`
 	StopOnFailedResourceUpdate bool                                       `json:"stopOnFailedResourceUpdate,omitempty"`
 	ConsulRegistration         types.ConsulRegistration                   `json:"consulRegistration,omitempty"`
@@ -76,6 +82,9 @@ type CassandraServiceSpec struct {
 	ManagedBy                  string                                     `json:"managedBy,omitempty"`
 	Instance                   string                                     `json:"instance,omitempty"`
 	DeletePVConUninstall       bool                                       `json:"deletePVConUninstall,omitempty"`
+	// `restricted` hardens every pod template of the components: seccomp `RuntimeDefault`, non-root UIDs and
+	// a read-only root filesystem with emptyDir volumes for the writable paths. Empty keeps the defaults.
+	SecurityProfile string `json:"securityProfile,omitempty"`
 }
 
 // CassandraServiceStatus defines the observed state of CassandraService
@@ -84,12 +93,204 @@ type CassandraServiceStatus struct {
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
 	Conditions []types.ServiceStatusCondition `json:"conditions,omitempty"`
+	Backup     *BackupStatus                  `json:"backup,omitempty"`
+	Dbaas      *DbaasStatus                   `json:"dbaas,omitempty"`
+	TLS        *TLSStatus                     `json:"tls,omitempty"`
+}
+
+// TLSStatus holds the certificates mounted into the component pods as the operator found them
+type TLSStatus struct {
+	Certificates  []CertificateStatus `json:"certificates,omitempty"`
+	LastCheckTime *metav1.Time        `json:"lastCheckTime,omitempty"`
+}
+
+type CertificateStatus struct {
+	SecretName string `json:"secretName"`
+	// deployments the secret is mounted into
+	Deployments []string     `json:"deployments,omitempty"`
+	Subject     string       `json:"subject,omitempty"`
+	NotAfter    *metav1.Time `json:"notAfter,omitempty"`
+	// the smallest expiry warning threshold the certificate has crossed, a Warning event is raised once per threshold
+	WarnedThreshold string `json:"warnedThreshold,omitempty"`
+	Message         string `json:"message,omitempty"`
+}
+
+// DbaasStatus holds the state of dbaas resources managed by the operator outside Kubernetes
+type DbaasStatus struct {
+	StreamingRole *StreamingRoleStatus `json:"streamingRole,omitempty"`
+	Registration  *RegistrationStatus  `json:"registration,omitempty"`
+}
+
+// RegistrationStatus is the physical database registration in DBaaS aggregator as the aggregator reports it
+type RegistrationStatus struct {
+	PhysicalDatabaseIdentifier string `json:"physicalDatabaseIdentifier,omitempty"`
+	Registered                 bool   `json:"registered"`
+	// adapter address the aggregator sends requests to
+	AdapterAddress string       `json:"adapterAddress,omitempty"`
+	LastCheckTime  *metav1.Time `json:"lastCheckTime,omitempty"`
+	Result         string       `json:"result,omitempty"`
+	Message        string       `json:"message,omitempty"`
+}
+
+type StreamingRoleStatus struct {
+	Name string `json:"name,omitempty"`
+	// permissions on all keyspaces applied by the operator
+	Permissions          []string     `json:"permissions,omitempty"`
+	LastCheckTime        *metav1.Time `json:"lastCheckTime,omitempty"`
+	PasswordRotationTime *metav1.Time `json:"passwordRotationTime,omitempty"`
+	// grants changed outside the operator found by the last check
+	Drift   []string `json:"drift,omitempty"`
+	Result  string   `json:"result,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// BackupStatus holds the backup daemon state collected by the operator
+type BackupStatus struct {
+	// the latest full backup known to the backup daemon
+	LastFullBackup *BackupInfo `json:"lastFullBackup,omitempty"`
+	// the latest granular backup for each keyspace
+	LastGranularBackups map[string]BackupInfo `json:"lastGranularBackups,omitempty"`
+	// the number of backups currently retained by the backup daemon
+	RetainedBackups int                  `json:"retainedBackups,omitempty"`
+	Storage         *BackupStorageStatus `json:"storage,omitempty"`
+	// the last time the backup daemon was successfully queried
+	LastCollectionTime *metav1.Time                   `json:"lastCollectionTime,omitempty"`
+	Conditions         []types.ServiceStatusCondition `json:"conditions,omitempty"`
+	// the full backup made before the last upgrade
+	PreUpgradeBackup *PreUpgradeBackupStatus `json:"preUpgradeBackup,omitempty"`
+	Snapshots        *SnapshotBackupStatus   `json:"snapshots,omitempty"`
+	PITR             *PITRStatus             `json:"pitr,omitempty"`
+	Catalog          *BackupCatalogStatus    `json:"catalog,omitempty"`
+	Replication      *ReplicationStatus      `json:"replication,omitempty"`
+	Volume           *BackupVolumeStatus     `json:"volume,omitempty"`
+	Schema           *SchemaBackupStatus     `json:"schema,omitempty"`
+	// clients that still connect to the backup daemon over plain HTTP in the `tls.optional` mode, as the backup daemon reports them
+	PlainHTTPClients []string `json:"plainHTTPClients,omitempty"`
+	// hash of the deployment version and images the last successful pre-upgrade backup was made for,
+	// or that were installed by a clean deploy
+	BackedUpVersionHash string `json:"backedUpVersionHash,omitempty"`
+}
+
+type SchemaBackupStatus struct {
+	// the latest stored schema version, a new version is stored only if the schema has changed
+	LastVersion    string       `json:"lastVersion,omitempty"`
+	Location       string       `json:"location,omitempty"`
+	LastExportTime *metav1.Time `json:"lastExportTime,omitempty"`
+	Keyspaces      int          `json:"keyspaces,omitempty"`
+	// changes of the latest version against the previous one
+	Changes  []string `json:"changes,omitempty"`
+	Versions []string `json:"versions,omitempty"`
+	Result   string   `json:"result,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+type BackupVolumeStatus struct {
+	// the PVC mounted to the backup daemon
+	PVCName       string `json:"pvcName,omitempty"`
+	StorageClass  string `json:"storageClass,omitempty"`
+	RequestedSize string `json:"requestedSize,omitempty"`
+	// the size reported by the storage, it lags behind the requested size while the volume is being expanded
+	Capacity  string                       `json:"capacity,omitempty"`
+	Message   string                       `json:"message,omitempty"`
+	Migration *BackupVolumeMigrationStatus `json:"migration,omitempty"`
+}
+
+type BackupVolumeMigrationStatus struct {
+	From         string       `json:"from,omitempty"`
+	To           string       `json:"to,omitempty"`
+	StorageClass string       `json:"storageClass,omitempty"`
+	Time         *metav1.Time `json:"time,omitempty"`
+	Result       string       `json:"result,omitempty"`
+	Message      string       `json:"message,omitempty"`
+}
+
+type ReplicationStatus struct {
+	// the secondary storage location
+	Target       string       `json:"target,omitempty"`
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// age of the oldest successful backup not yet replicated
+	Lag     string                   `json:"lag,omitempty"`
+	Backups []BackupReplicationState `json:"backups,omitempty"`
+}
+
+type BackupReplicationState struct {
+	Id string `json:"id"`
+	// Replicated, Pending or Failed
+	State   string       `json:"state"`
+	Time    *metav1.Time `json:"time,omitempty"`
+	Message string       `json:"message,omitempty"`
+}
+
+type BackupCatalogStatus struct {
+	// the ConfigMap holding an entry per retained backup
+	ConfigMapName string `json:"configMapName,omitempty"`
+	Entries       int    `json:"entries,omitempty"`
+	// backups and snapshot groups under legal hold, they are never evicted
+	LegalHolds []string `json:"legalHolds,omitempty"`
+	// backups evicted by the operator on the last collection
+	LastEvicted []string `json:"lastEvicted,omitempty"`
+}
+
+type PITRStatus struct {
+	// the latest time Cassandra can be restored to with the shipped commitlog segments
+	LatestRecoverablePoint *metav1.Time `json:"latestRecoverablePoint,omitempty"`
+	LastShipTime           *metav1.Time `json:"lastShipTime,omitempty"`
+	// the number of segments shipped to the backup storage
+	ShippedSegments int                `json:"shippedSegments,omitempty"`
+	Restore         *PITRRestoreStatus `json:"restore,omitempty"`
+}
+
+type PITRRestoreStatus struct {
+	PointInTime string `json:"pointInTime,omitempty"`
+	// the full backup the segments were replayed on
+	BackupId string       `json:"backupId,omitempty"`
+	Time     *metav1.Time `json:"time,omitempty"`
+	Result   string       `json:"result,omitempty"`
+	Message  string       `json:"message,omitempty"`
+}
+
+type SnapshotBackupStatus struct {
+	LastGroup *SnapshotOperationStatus `json:"lastGroup,omitempty"`
+	// snapshot groups left after eviction
+	RetainedGroups []string                 `json:"retainedGroups,omitempty"`
+	Restore        *SnapshotOperationStatus `json:"restore,omitempty"`
+}
+
+type SnapshotOperationStatus struct {
+	Group   string       `json:"group,omitempty"`
+	Time    *metav1.Time `json:"time,omitempty"`
+	Result  string       `json:"result,omitempty"`
+	Message string       `json:"message,omitempty"`
+}
+
+type PreUpgradeBackupStatus struct {
+	Id string `json:"id,omitempty"`
+	// the deployment version the upgrade was performed to
+	DeploymentVersion string `json:"deploymentVersion,omitempty"`
+	// hash of the deployment version and component images the upgrade was performed to
+	VersionHash string       `json:"versionHash,omitempty"`
+	Time        *metav1.Time `json:"time,omitempty"`
+	Result      string       `json:"result,omitempty"`
+	Message     string       `json:"message,omitempty"`
+}
+
+type BackupInfo struct {
+	Id     string       `json:"id,omitempty"`
+	Time   *metav1.Time `json:"time,omitempty"`
+	Result string       `json:"result,omitempty"`
+}
+
+type BackupStorageStatus struct {
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	FreeBytes  int64 `json:"freeBytes,omitempty"`
+	// space occupied by the retained backups
+	UsedBytes int64 `json:"usedBytes,omitempty"`
 }
 
 type TLS struct {
 	//if TLS encryption should be enabled
 	Enabled bool `json:"enabled,omitempty"`
-	//if TLS encryption is optional. If `true` then Cassandra cluster will accept TLS and non TLS connections.
+	//if TLS encryption is optional. If `true` then Cassandra cluster will accept TLS and non TLS connections, and the backup daemon and DBaaS adapter serve plain HTTP on 8080 next to HTTPS on 8443.
 	Optional bool `json:"optional,omitempty"`
 	//a name of Kubernetes secret that holds a CA certificate, a Signed Cassandra sertificate and a private key.
 	RootCASecretName string `json:"rootCASecretName,omitempty"`
@@ -99,8 +300,47 @@ type TLS struct {
 	SignedCRTFileName string `json:"signedCRTFileName,omitempty"`
 	//a key in the Kubernetes secret `tls.rootCASecretName` that holds the private key.
 	PrivateKeyFileName string `json:"privateKeyFileName,omitempty"`
-	//a password to Cassandra keystore.
+	//a password to Cassandra keystore. The PKCS12 keystores and truststore the operator builds for the components with `mountKeystores` are protected by it too.
 	KeystorePass string `json:"keystorePass,omitempty"`
+	//a name of Kubernetes secret the operator stores the PKCS12 keystores and truststore built from the TLS secrets to.
+	// +kubebuilder:default="cassandra-tls-keystores"
+	KeystoresSecretName string `json:"keystoresSecretName,omitempty"`
+	//a cert-manager issuer of the backup daemon and DBaaS adapter certificates. If set, the operator creates cert-manager `Certificate` resources for the server certificate secrets.
+	Issuer *TLSIssuer `json:"issuer,omitempty"`
+	//a built-in CA for clusters without cert-manager. If set, the operator generates the CA in `tls.rootCASecretName` and the backup daemon and DBaaS adapter certificates signed by it, and renews them before expiry.
+	SelfSigned *TLSSelfSigned `json:"selfSigned,omitempty"`
+	//the operator raises a Warning event when a certificate mounted into the component pods expires within each of these durations, and once it has expired.
+	// +kubebuilder:default={"720h","168h","24h"}
+	ExpiryWarningThresholds []string `json:"expiryWarningThresholds,omitempty"`
+}
+
+// TLSIssuer references the cert-manager issuer that signs the component certificates
+type TLSIssuer struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +kubebuilder:default=Issuer
+	Kind string `json:"kind,omitempty"`
+	// +kubebuilder:default="cert-manager.io"
+	Group string `json:"group,omitempty"`
+	// certificate lifetime
+	// +kubebuilder:default="8760h"
+	Duration string `json:"duration,omitempty"`
+	// how long before expiry cert-manager renews the certificate
+	// +kubebuilder:default="720h"
+	RenewBefore string `json:"renewBefore,omitempty"`
+}
+
+// TLSSelfSigned sets the lifetime of the certificates generated by the operator
+type TLSSelfSigned struct {
+	// CA certificate lifetime
+	// +kubebuilder:default="87600h"
+	CADuration string `json:"caDuration,omitempty"`
+	// server certificate lifetime
+	// +kubebuilder:default="8760h"
+	Duration string `json:"duration,omitempty"`
+	// how long before expiry the operator renews the CA and server certificates
+	// +kubebuilder:default="720h"
+	RenewBefore string `json:"renewBefore,omitempty"`
 }
 
 type BackupDaemonTLS struct {
@@ -111,6 +351,29 @@ type BackupDaemonTLS struct {
 type DbaasAdapterTLS struct {
 	// a name of Kubernetes secret that holds a CA certificate, a Signed Cassandra Dbaas Adapter sertificate and a private key.
 	DbaasAdapterCASecretName string `json:"dbaasAdapterCASecretName,omitempty"`
+	// if the adapter serves its API over HTTPS on port 8443. If not set, HTTPS is served when `tls.enabled` is `true` and the aggregator address is HTTPS.
+	Server *bool `json:"server,omitempty"`
+	// if the adapter and the operator connect to DBaaS aggregator over TLS verifying it with the CA of `tls.rootCASecretName` or the system trust store. If not set, follows the scheme of `dbaas.aggregator.dbaasAggregatorRegistrationAddress`.
+	Client *bool `json:"client,omitempty"`
+	// a name of Kubernetes secret that holds a client certificate and a private key presented to DBaaS aggregator. Enables mutual TLS with the aggregator.
+	ClientCertSecretName string `json:"clientCertSecretName,omitempty"`
+}
+
+// ProbeTimings tune a container probe, zero values fall back to the operator defaults
+type ProbeTimings struct {
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	TimeoutSeconds      int32 `json:"timeoutSeconds,omitempty"`
+	PeriodSeconds       int32 `json:"periodSeconds,omitempty"`
+	SuccessThreshold    int32 `json:"successThreshold,omitempty"`
+	FailureThreshold    int32 `json:"failureThreshold,omitempty"`
+}
+
+// Probes configure the HTTP health probes of a component,
+// the liveness and readiness probes start once the startup probe succeeds
+type Probes struct {
+	Liveness  ProbeTimings `json:"liveness,omitempty"`
+	Readiness ProbeTimings `json:"readiness,omitempty"`
+	Startup   ProbeTimings `json:"startup,omitempty"`
 }
 
 type Backup struct {
@@ -133,6 +396,111 @@ type Backup struct {
 	PriorityClassName          string                     `json:"priorityClassName,omitempty"`
 	S3                         S3backup                   `json:"s3,omitempty"`
 	TLS                        BackupDaemonTLS            `json:"tls,omitempty"`
+	StatusCollector            BackupStatusCollector      `json:"statusCollector,omitempty"`
+	// if a full backup should be made before components are redeployed with a new `deploymentVersion` or images. The upgrade is blocked if the backup fails.
+	BackupBeforeUpgrade bool            `json:"backupBeforeUpgrade,omitempty"`
+	Snapshots           BackupSnapshots `json:"snapshots,omitempty"`
+	PITR                BackupPITR      `json:"pitr,omitempty"`
+	// per-node bandwidth limit for backup transfers in bytes per second, e.g. `50Mi`. Unlimited if empty.
+	BandwidthLimit string `json:"bandwidthLimit,omitempty"`
+	// the maximum number of nodes backed up at once in each data center. All nodes are backed up at once if 0.
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrentNodesPerDC int `json:"maxConcurrentNodesPerDC,omitempty"`
+	// windows during which scheduled backups are deferred until the window ends. Windows must not overlap.
+	BlackoutWindows []BackupBlackoutWindow `json:"blackoutWindows,omitempty"`
+	Catalog         BackupCatalog          `json:"catalog,omitempty"`
+	Replication     BackupReplication      `json:"replication,omitempty"`
+	Schema          BackupSchema           `json:"schema,omitempty"`
+	Probes          Probes                 `json:"probes,omitempty"`
+	// if the PKCS12 keystore and truststore built from the TLS secrets should be mounted into the pod, for Java tooling.
+	MountKeystores bool `json:"mountKeystores,omitempty"`
+	// pod security context of the component, replaces `securityContext`
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// security context of the component containers, replaces the default one that drops all capabilities
+	ContainerSecurityContext *v1.SecurityContext `json:"containerSecurityContext,omitempty"`
+	// rclone image of the jobs that ship commitlog segments and replicate backups. The default value is `rclone/rclone:1.68.2`.
+	TransferImage string `json:"transferImage,omitempty"`
+}
+
+type BackupReplication struct {
+	// if backups should be copied to the secondary storage target. Backups are copied one at a time by a job with `transferImage`.
+	Enabled bool `json:"enabled,omitempty"`
+	// secondary S3 bucket with its own credentials and TLS secret
+	S3 S3backup `json:"s3,omitempty"`
+	// secondary PVC mounted to the backup daemon, used if `s3.enabled` is not set
+	PVCName string `json:"pvcName,omitempty"`
+	// how often new backups are replicated. The default value is `10m`.
+	Interval string `json:"interval,omitempty"`
+	// age of a successful backup not yet replicated after which the `ReplicationLagging` condition is raised. The default value is `1h`.
+	MaxLag string `json:"maxLag,omitempty"`
+}
+
+type BackupSchema struct {
+	// if the operator periodically exports DDL of non-system keyspaces and reports schema changes
+	Enabled bool `json:"enabled,omitempty"`
+	// how often the schema is exported. The default value is `1h`.
+	Interval string `json:"interval,omitempty"`
+	// where schema versions are stored: `ConfigMap` or `BackupStorage`, the latter is the backup daemon storage or S3
+	// +kubebuilder:validation:Enum=ConfigMap;BackupStorage
+	Target string `json:"target,omitempty"`
+	// the number of schema versions kept. The default value is `10`.
+	// +kubebuilder:validation:Minimum=0
+	KeepVersions int `json:"keepVersions,omitempty"`
+}
+
+type BackupCatalog struct {
+	// if the operator keeps the backup catalog ConfigMap in sync and evicts backups instead of the backup daemon.
+	// The catalog is refreshed by the status collector, `statusCollector.enabled` is required.
+	// Backups and snapshot groups are put under legal hold with `legal-hold.netcracker.com/<backup id>` CR labels.
+	Enabled bool `json:"enabled,omitempty"`
+	// eviction policies of granular backups per keyspace in `evictionPolicy` format, `granularEvictionPolicy` is used for other keyspaces.
+	// A granular backup is evicted when policies of all its keyspaces evict it.
+	KeyspaceEvictionPolicies map[string]string `json:"keyspaceEvictionPolicies,omitempty"`
+}
+
+type BackupBlackoutWindow struct {
+	// days of week the window starts on, e.g. `Mon`, `Sat`. Every day if empty.
+	Days []string `json:"days,omitempty"`
+	// window start time in `HH:MM` format
+	Start string `json:"start"`
+	// window end time in `HH:MM` format. The window crosses midnight if it is earlier than the start.
+	End string `json:"end"`
+	// IANA time zone of the window, e.g. `Europe/Berlin`. The default value is `UTC`.
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+type BackupPITR struct {
+	// if Cassandra commitlog segments should be archived and shipped to the backup storage for point-in-time recovery. Without `backupDaemon.s3.enabled`
+	// the segments are shipped to the backup daemon PVC, it is created ReadWriteMany to be mounted on Cassandra nodes.
+	// `commitlog_archiving.properties` is kept in the `cassandra-commitlog-archiving` ConfigMap which must be mounted to the Cassandra configuration directory
+	// through the Cassandra CR, archiving is applied after Cassandra restart. Segments are shipped by jobs running on Cassandra nodes.
+	Enabled bool `json:"enabled,omitempty"`
+	// a directory on Cassandra data volume for archived commitlog segments. The default value is `/var/lib/cassandra/data/commitlog_archive`.
+	ArchiveDirectory string `json:"archiveDirectory,omitempty"`
+	// how often archived segments are shipped to the backup storage. The default value is `5m`.
+	ShipInterval string `json:"shipInterval,omitempty"`
+	// a time in RFC3339 format to restore Cassandra to. The nearest full backup before it is restored and archived segments are replayed on top of it.
+	RestorePointInTime string `json:"restorePointInTime,omitempty"`
+}
+
+type BackupSnapshots struct {
+	// if full backups should be made as CSI VolumeSnapshots of Cassandra data volumes instead of the backup daemon schedule. `backupDaemon.evictionPolicy` is applied to snapshot groups.
+	Enabled bool `json:"enabled,omitempty"`
+	// VolumeSnapshotClass for the snapshots. The default class is used if empty.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// how often a snapshot group is created. The default value is `24h`.
+	Interval string `json:"interval,omitempty"`
+	// a snapshot group to provision Cassandra data PVCs from. Cassandra must be scaled down and its PVCs removed before the restore.
+	RestoreFromGroup string `json:"restoreFromGroup,omitempty"`
+}
+
+type BackupStatusCollector struct {
+	// if the operator should periodically query the backup daemon and store its state in `status.backup`
+	Enabled bool `json:"enabled,omitempty"`
+	// how often the backup daemon is queried. The default value is `5m`.
+	Interval string `json:"interval,omitempty"`
+	// age of the newest successful backup after which the `BackupOutdated` condition is raised. The default value is `25h`.
+	StaleThreshold string `json:"staleThreshold,omitempty"`
 }
 
 type S3backup struct {
@@ -172,6 +540,56 @@ type Dbaas struct {
 	//default topology strategy for keyspaces created via Dbaas. The default values is `"{'class':'SimpleStrategy','replication_factor': 1 }"`. This parameter is ignored if `dbaas.allDCTopologyStrategy` is `true`. The value can be overridden in a request body to Dbaas.
 	TopologyStrategy string          `json:"topologyStrategy,omitempty"`
 	TLS              DbaasAdapterTLS `json:"tls,omitempty"`
+	// number of adapter pods, the pods are spread across nodes and zones
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	Replicas int `json:"replicas,omitempty"`
+	// the operator keeps a PodDisruptionBudget allowing one adapter pod to be evicted at a time, a single pod gets no budget
+	PDB         bool             `json:"pdb,omitempty"`
+	Autoscaling DbaasAutoscaling `json:"autoscaling,omitempty"`
+	Probes      Probes           `json:"probes,omitempty"`
+	// labels the adapter registers the physical database with in DBaaS aggregator
+	PhysicalDatabaseLabels map[string]string  `json:"physicalDatabaseLabels,omitempty"`
+	StreamingRole          DbaasStreamingRole `json:"streamingRole,omitempty"`
+	// pod security context of the component, replaces `securityContext`
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// security context of the component containers, replaces the default one that drops all capabilities
+	ContainerSecurityContext *v1.SecurityContext `json:"containerSecurityContext,omitempty"`
+}
+
+// DbaasStreamingRole makes the operator keep the CQL role described by the `dbaas-streaming-role` secret in Cassandra
+type DbaasStreamingRole struct {
+	Managed bool `json:"managed,omitempty"`
+	// +kubebuilder:default="720h"
+	PasswordRotationPeriod string `json:"passwordRotationPeriod,omitempty"`
+	// how often the role grants are compared with the secret
+	// +kubebuilder:default="10m"
+	CheckInterval string `json:"checkInterval,omitempty"`
+	// if grants the secret does not list, e.g. on a keyspace, are revoked. Otherwise they are only reported as drift.
+	RevokeUnexpectedPermissions bool `json:"revokeUnexpectedPermissions,omitempty"`
+}
+
+// DbaasAutoscaling is turned into a HorizontalPodAutoscaler of the adapter deployment, `dbaas.replicas` is ignored while it is enabled
+type DbaasAutoscaling struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+	// average CPU utilization in percent of the requests
+	TargetCPUUtilization *int32 `json:"targetCPUUtilization,omitempty"`
+	// average memory utilization in percent of the requests
+	TargetMemoryUtilization *int32 `json:"targetMemoryUtilization,omitempty"`
+	// pod metric served by a custom metrics API adapter, e.g. Prometheus Adapter
+	CustomMetric *DbaasCustomMetric `json:"customMetric,omitempty"`
+}
+
+type DbaasCustomMetric struct {
+	Name string `json:"name"`
+	// average value per pod, e.g. `10` or `500m`
+	TargetAverageValue string `json:"targetAverageValue"`
 }
 
 type AWSKeyspaces struct {
@@ -191,6 +609,12 @@ type RobotTests struct {
 	ReplicationFactor int                      `json:"replicationFactor,omitempty"`
 	AttemptsNumber    int                      `json:"attemptsNumber,omitempty"`
 	NodeLabels        map[string]string        `json:"nodeLabels,omitempty"`
+	// if the PKCS12 keystore and truststore built from the TLS secrets should be mounted into the pod, for Java tooling.
+	MountKeystores bool `json:"mountKeystores,omitempty"`
+	// pod security context of the component, replaces `securityContext`
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// security context of the component containers, replaces the default one that drops all capabilities
+	ContainerSecurityContext *v1.SecurityContext `json:"containerSecurityContext,omitempty"`
 }
 
 type Monitoring struct {
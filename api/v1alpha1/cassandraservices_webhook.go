@@ -0,0 +1,362 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AWSKeyspacesConsistency is the only consistency level AWS Keyspaces accepts for writes
+const AWSKeyspacesConsistency = "LOCAL_QUORUM"
+
+// RestrictedSecurityProfile hardens the pods of every component to the restricted Pod Security Standard
+const RestrictedSecurityProfile = "restricted"
+
+// SetupWebhookWithManager registers the validating webhook of the custom resource
+func (r *CassandraSupplService) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&CassandraSupplServiceValidator{}).
+		Complete()
+}
+
+// failurePolicy matches operator.webhook.failurePolicy of the chart, the CR is created while the operator is not running yet on the first install
+//+kubebuilder:webhook:path=/validate-netcracker-com-v1alpha1-cassandrasupplservice,mutating=false,failurePolicy=ignore,sideEffects=None,groups=netcracker.com,resources=cassandrasupplservices,verbs=create;update,versions=v1alpha1,name=vcassandrasupplservice.netcracker.com,admissionReviewVersions=v1
+
+// CassandraSupplServiceValidator rejects custom resources with options the target cluster does not support
+// +kubebuilder:object:generate=false
+type CassandraSupplServiceValidator struct{}
+
+var _ admission.CustomValidator = &CassandraSupplServiceValidator{}
+
+func (v *CassandraSupplServiceValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, validateObject(obj)
+}
+
+func (v *CassandraSupplServiceValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, validateObject(newObj)
+}
+
+func (v *CassandraSupplServiceValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validateObject(obj runtime.Object) error {
+	service, ok := obj.(*CassandraSupplService)
+	if !ok {
+		return fmt.Errorf("expected a CassandraSupplService but got a %T", obj)
+	}
+	return service.Validate()
+}
+
+// Validate checks the spec and returns an Invalid API error listing every rejected field
+func (r *CassandraSupplService) Validate() error {
+	errs := r.Spec.validate(field.NewPath("spec"))
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("CassandraSupplService").GroupKind(), r.Name, errs)
+}
+
+func (s *CassandraServiceSpec) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if s.AWSKeyspaces.Install {
+		errs = append(errs, s.validateAWSKeyspaces(path)...)
+	}
+	if s.Cassandra.ClientCertSecretName != "" {
+		errs = append(errs, s.validateCassandraClientCert(path.Child("cassandra", "clientCertSecretName"))...)
+	} else if s.Cassandra.PasswordlessAuth {
+		errs = append(errs, field.Required(path.Child("cassandra", "clientCertSecretName"), "passwordless authentication needs a client certificate"))
+	}
+	errs = append(errs, metav1validation.ValidateLabels(s.Dbaas.PhysicalDatabaseLabels, path.Child("dbaas", "physicalDatabaseLabels"))...)
+	if s.Dbaas.Autoscaling.Enabled {
+		errs = append(errs, s.Dbaas.Autoscaling.validate(path.Child("dbaas", "autoscaling"))...)
+	}
+	if s.Dbaas.Install {
+		errs = append(errs, s.validateDbaasTLS(path.Child("dbaas", "tls"))...)
+	}
+	if s.TLS.Issuer != nil {
+		errs = append(errs, s.TLS.Issuer.validate(path.Child("tls", "issuer"))...)
+	}
+	if s.TLS.SelfSigned != nil {
+		errs = append(errs, s.TLS.validateSelfSigned(path.Child("tls"))...)
+	}
+	var keystoreMounts []*field.Path
+	if s.Backup.Install && s.Backup.MountKeystores {
+		keystoreMounts = append(keystoreMounts, path.Child("backupDaemon", "mountKeystores"))
+	}
+	if s.RobotTests.Install && s.RobotTests.MountKeystores {
+		keystoreMounts = append(keystoreMounts, path.Child("robotTests", "mountKeystores"))
+	}
+	if len(keystoreMounts) > 0 {
+		errs = append(errs, s.TLS.validateKeystores(path.Child("tls"), keystoreMounts)...)
+	}
+	if s.Backup.Install && s.Backup.Catalog.Enabled && !s.Backup.StatusCollector.Enabled {
+		errs = append(errs, field.Required(path.Child("backupDaemon", "statusCollector", "enabled"), "the backup daemon does not evict backups with catalog.enabled, the status collector does"))
+	}
+	if s.Backup.Install && s.Backup.PITR.Enabled && !s.Backup.S3.Enabled && (s.Backup.Storage == nil || s.Backup.Storage.EmptyDir) {
+		errs = append(errs, field.Required(path.Child("backupDaemon", "s3", "enabled"), "commitlog segments are shipped to the backup S3 storage or the backup daemon PVC"))
+	}
+	errs = append(errs, s.validateSecurityProfile(path)...)
+	for i, threshold := range s.TLS.ExpiryWarningThresholds {
+		if _, err := time.ParseDuration(threshold); err != nil {
+			errs = append(errs, field.Invalid(path.Child("tls", "expiryWarningThresholds").Index(i), threshold, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateSelfSigned checks the operator can store the generated certificates under the configured keys
+func (t *TLS) validateSelfSigned(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if t.Issuer != nil {
+		errs = append(errs, field.Forbidden(path.Child("selfSigned"), "tls.issuer and tls.selfSigned are mutually exclusive"))
+	}
+	if t.RootCASecretName == "" {
+		errs = append(errs, field.Required(path.Child("rootCASecretName"), "self-signed CA is stored in the secret"))
+	}
+	fileName := func(fieldPath *field.Path, value, expected string) {
+		if value != "" && value != expected {
+			errs = append(errs, field.NotSupported(fieldPath, value, []string{expected}))
+		}
+	}
+	fileName(path.Child("rootCAFileName"), t.RootCAFileName, "ca.crt")
+	fileName(path.Child("signedCRTFileName"), t.SignedCRTFileName, "tls.crt")
+	fileName(path.Child("privateKeyFileName"), t.PrivateKeyFileName, "tls.key")
+
+	selfSignedPath := path.Child("selfSigned")
+	parse := func(fieldPath *field.Path, value string) time.Duration {
+		parsed, err := time.ParseDuration(value)
+		if value != "" && err != nil {
+			errs = append(errs, field.Invalid(fieldPath, value, err.Error()))
+		}
+		return parsed
+	}
+	caDuration := parse(selfSignedPath.Child("caDuration"), t.SelfSigned.CADuration)
+	duration := parse(selfSignedPath.Child("duration"), t.SelfSigned.Duration)
+	renewBefore := parse(selfSignedPath.Child("renewBefore"), t.SelfSigned.RenewBefore)
+	if renewBefore > 0 && (renewBefore >= duration && duration > 0 || renewBefore >= caDuration && caDuration > 0) {
+		errs = append(errs, field.Invalid(selfSignedPath.Child("renewBefore"), t.SelfSigned.RenewBefore, "must be shorter than the certificate lifetimes"))
+	}
+	return errs
+}
+
+// validateKeystores checks the keystores mounted into a component can be built and opened
+func (t *TLS) validateKeystores(path *field.Path, mounts []*field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if !t.Enabled {
+		for _, mount := range mounts {
+			errs = append(errs, field.Forbidden(mount, "keystores are built from the TLS secrets, tls.enabled is required"))
+		}
+	}
+	if t.KeystorePass == "" {
+		errs = append(errs, field.Required(path.Child("keystorePass"), "keystores are protected by the password"))
+	}
+	return errs
+}
+
+func (i *TLSIssuer) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if i.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), "cert-manager issuer name is required"))
+	}
+	parse := func(fieldPath *field.Path, value string) {
+		if _, err := time.ParseDuration(value); value != "" && err != nil {
+			errs = append(errs, field.Invalid(fieldPath, value, err.Error()))
+		}
+	}
+	parse(path.Child("duration"), i.Duration)
+	parse(path.Child("renewBefore"), i.RenewBefore)
+	return errs
+}
+
+// validateCassandraClientCert checks the client certificate can be presented to Cassandra
+func (s *CassandraServiceSpec) validateCassandraClientCert(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if s.AWSKeyspaces.Install {
+		errs = append(errs, field.Forbidden(path, "not supported by AWS Keyspaces"))
+	}
+	if !s.TLS.Enabled {
+		errs = append(errs, field.Forbidden(path, "client certificate needs tls.enabled"))
+	}
+	tlsPath := path.Root().Child("tls")
+	if s.TLS.SignedCRTFileName == "" {
+		errs = append(errs, field.Required(tlsPath.Child("signedCRTFileName"), "key of the client certificate in the secret"))
+	}
+	if s.TLS.PrivateKeyFileName == "" {
+		errs = append(errs, field.Required(tlsPath.Child("privateKeyFileName"), "key of the client private key in the secret"))
+	}
+	return errs
+}
+
+func (s *CassandraServiceSpec) validateDbaasTLS(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	dbaasTLS := s.Dbaas.TLS
+	if dbaasTLS.Server != nil && *dbaasTLS.Server && dbaasTLS.DbaasAdapterCASecretName == "" {
+		errs = append(errs, field.Required(path.Child("dbaasAdapterCASecretName"), "adapter certificate is required for server TLS"))
+	}
+
+	var address string
+	if s.Dbaas.Aggregator != nil {
+		address = s.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress
+	}
+	clientTLS := strings.HasPrefix(address, "https://")
+	if dbaasTLS.Client != nil {
+		if *dbaasTLS.Client && !clientTLS {
+			errs = append(errs, field.Invalid(path.Child("client"), true, "dbaas.aggregator.dbaasAggregatorRegistrationAddress must be an https:// address"))
+		}
+		clientTLS = *dbaasTLS.Client
+	}
+	if dbaasTLS.ClientCertSecretName != "" && !clientTLS {
+		errs = append(errs, field.Forbidden(path.Child("clientCertSecretName"), "client certificate needs client TLS toward DBaaS aggregator"))
+	}
+	return errs
+}
+
+func (a *DbaasAutoscaling) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if a.MaxReplicas < a.MinReplicas {
+		errs = append(errs, field.Invalid(path.Child("maxReplicas"), a.MaxReplicas, "must not be less than minReplicas"))
+	}
+	if a.CustomMetric != nil {
+		if _, err := resource.ParseQuantity(a.CustomMetric.TargetAverageValue); err != nil {
+			errs = append(errs, field.Invalid(path.Child("customMetric", "targetAverageValue"), a.CustomMetric.TargetAverageValue, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateAWSKeyspaces rejects options that need a self-managed Cassandra cluster
+func (s *CassandraServiceSpec) validateAWSKeyspaces(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	awsPath := path.Child("awsKeyspaces")
+	if s.AWSKeyspaces.SecretName == "" {
+		errs = append(errs, field.Required(awsPath.Child("secretName"), "AWS credentials secret is required"))
+	}
+	if s.AWSKeyspaces.Host == "" {
+		errs = append(errs, field.Required(awsPath.Child("host"), "regional AWS Keyspaces endpoint is required"))
+	}
+
+	unsupported := func(fieldPath *field.Path, set bool) {
+		if set {
+			errs = append(errs, field.Forbidden(fieldPath, "not supported by AWS Keyspaces"))
+		}
+	}
+
+	cassandraPath := path.Child("cassandra")
+	if s.Cassandra.Consistency != "" && s.Cassandra.Consistency != AWSKeyspacesConsistency {
+		errs = append(errs, field.NotSupported(cassandraPath.Child("consistency"), s.Cassandra.Consistency, []string{AWSKeyspacesConsistency}))
+	}
+
+	backupPath := path.Child("backupDaemon")
+	unsupported(backupPath.Child("snapshots", "enabled"), s.Backup.Snapshots.Enabled)
+	unsupported(backupPath.Child("pitr", "enabled"), s.Backup.PITR.Enabled)
+	unsupported(backupPath.Child("schema", "enabled"), s.Backup.Schema.Enabled)
+	unsupported(backupPath.Child("replication", "enabled"), s.Backup.Replication.Enabled)
+	unsupported(backupPath.Child("bandwidthLimit"), s.Backup.BandwidthLimit != "")
+	unsupported(backupPath.Child("maxConcurrentNodesPerDC"), s.Backup.MaxConcurrentNodesPerDC != 0)
+
+	dbaasPath := path.Child("dbaas")
+	unsupported(dbaasPath.Child("multiUsers"), s.Dbaas.MultiUsers)
+	unsupported(path.Child("vaultRegistration", "enabled"), s.VaultRegistration.Enabled)
+	return errs
+}
+
+// validateSecurityProfile rejects the security context overrides that contradict the `restricted` profile
+func (s *CassandraServiceSpec) validateSecurityProfile(path *field.Path) field.ErrorList {
+	switch s.SecurityProfile {
+	case "":
+		return nil
+	case RestrictedSecurityProfile:
+	default:
+		return field.ErrorList{field.NotSupported(path.Child("securityProfile"), s.SecurityProfile, []string{RestrictedSecurityProfile})}
+	}
+
+	errs := validateRestrictedPod(path.Child("securityContext"), s.PodSecurityContext)
+	components := []struct {
+		name      string
+		pod       *v1.PodSecurityContext
+		container *v1.SecurityContext
+	}{
+		{"backupDaemon", s.Backup.SecurityContext, s.Backup.ContainerSecurityContext},
+		{"dbaas", s.Dbaas.SecurityContext, s.Dbaas.ContainerSecurityContext},
+		{"robotTests", s.RobotTests.SecurityContext, s.RobotTests.ContainerSecurityContext},
+	}
+	for _, component := range components {
+		errs = append(errs, validateRestrictedPod(path.Child(component.name, "securityContext"), component.pod)...)
+		errs = append(errs, validateRestrictedContainer(path.Child(component.name, "containerSecurityContext"), component.container)...)
+	}
+	return errs
+}
+
+func validateRestrictedPod(path *field.Path, context *v1.PodSecurityContext) field.ErrorList {
+	if context == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if context.RunAsNonRoot != nil && !*context.RunAsNonRoot {
+		errs = append(errs, field.Forbidden(path.Child("runAsNonRoot"), "pods run as non-root in the restricted security profile"))
+	}
+	if context.RunAsUser != nil && *context.RunAsUser == 0 {
+		errs = append(errs, field.Forbidden(path.Child("runAsUser"), "pods run as non-root in the restricted security profile"))
+	}
+	if context.SeccompProfile != nil && context.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+		errs = append(errs, field.Forbidden(path.Child("seccompProfile", "type"), "seccomp profile is required in the restricted security profile"))
+	}
+	return errs
+}
+
+func validateRestrictedContainer(path *field.Path, context *v1.SecurityContext) field.ErrorList {
+	if context == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if context.Privileged != nil && *context.Privileged {
+		errs = append(errs, field.Forbidden(path.Child("privileged"), "privileged containers are not allowed in the restricted security profile"))
+	}
+	if context.AllowPrivilegeEscalation != nil && *context.AllowPrivilegeEscalation {
+		errs = append(errs, field.Forbidden(path.Child("allowPrivilegeEscalation"), "privilege escalation is not allowed in the restricted security profile"))
+	}
+	if context.ReadOnlyRootFilesystem != nil && !*context.ReadOnlyRootFilesystem {
+		errs = append(errs, field.Forbidden(path.Child("readOnlyRootFilesystem"), "root filesystem is read-only in the restricted security profile"))
+	}
+	if context.RunAsNonRoot != nil && !*context.RunAsNonRoot {
+		errs = append(errs, field.Forbidden(path.Child("runAsNonRoot"), "containers run as non-root in the restricted security profile"))
+	}
+	if context.RunAsUser != nil && *context.RunAsUser == 0 {
+		errs = append(errs, field.Forbidden(path.Child("runAsUser"), "containers run as non-root in the restricted security profile"))
+	}
+	if context.Capabilities != nil && len(context.Capabilities.Add) > 0 {
+		errs = append(errs, field.Forbidden(path.Child("capabilities", "add"), "capabilities cannot be added in the restricted security profile"))
+	}
+	if context.SeccompProfile != nil && context.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+		errs = append(errs, field.Forbidden(path.Child("seccompProfile", "type"), "seccomp profile is required in the restricted security profile"))
+	}
+	return errs
+}
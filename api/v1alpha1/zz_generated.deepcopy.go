@@ -68,6 +68,30 @@ func (in *Backup) DeepCopyInto(out *Backup) {
 	}
 	out.S3 = in.S3
 	out.TLS = in.TLS
+	out.StatusCollector = in.StatusCollector
+	out.Snapshots = in.Snapshots
+	out.PITR = in.PITR
+	if in.BlackoutWindows != nil {
+		in, out := &in.BlackoutWindows, &out.BlackoutWindows
+		*out = make([]BackupBlackoutWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Catalog.DeepCopyInto(&out.Catalog)
+	out.Replication = in.Replication
+	out.Schema = in.Schema
+	out.Probes = in.Probes
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backup.
@@ -80,6 +104,73 @@ func (in *Backup) DeepCopy() *Backup {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupBlackoutWindow) DeepCopyInto(out *BackupBlackoutWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupBlackoutWindow.
+func (in *BackupBlackoutWindow) DeepCopy() *BackupBlackoutWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupBlackoutWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupCatalog) DeepCopyInto(out *BackupCatalog) {
+	*out = *in
+	if in.KeyspaceEvictionPolicies != nil {
+		in, out := &in.KeyspaceEvictionPolicies, &out.KeyspaceEvictionPolicies
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupCatalog.
+func (in *BackupCatalog) DeepCopy() *BackupCatalog {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupCatalog)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupCatalogStatus) DeepCopyInto(out *BackupCatalogStatus) {
+	*out = *in
+	if in.LegalHolds != nil {
+		in, out := &in.LegalHolds, &out.LegalHolds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastEvicted != nil {
+		in, out := &in.LastEvicted, &out.LastEvicted
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupCatalogStatus.
+func (in *BackupCatalogStatus) DeepCopy() *BackupCatalogStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupCatalogStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupDaemonTLS) DeepCopyInto(out *BackupDaemonTLS) {
 	*out = *in
@@ -95,6 +186,257 @@ func (in *BackupDaemonTLS) DeepCopy() *BackupDaemonTLS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupInfo) DeepCopyInto(out *BackupInfo) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupInfo.
+func (in *BackupInfo) DeepCopy() *BackupInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupPITR) DeepCopyInto(out *BackupPITR) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupPITR.
+func (in *BackupPITR) DeepCopy() *BackupPITR {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupPITR)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupReplication) DeepCopyInto(out *BackupReplication) {
+	*out = *in
+	out.S3 = in.S3
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupReplication.
+func (in *BackupReplication) DeepCopy() *BackupReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupReplicationState) DeepCopyInto(out *BackupReplicationState) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupReplicationState.
+func (in *BackupReplicationState) DeepCopy() *BackupReplicationState {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupReplicationState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupSchema) DeepCopyInto(out *BackupSchema) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSchema.
+func (in *BackupSchema) DeepCopy() *BackupSchema {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupSchema)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupSnapshots) DeepCopyInto(out *BackupSnapshots) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSnapshots.
+func (in *BackupSnapshots) DeepCopy() *BackupSnapshots {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupSnapshots)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupStatus) DeepCopyInto(out *BackupStatus) {
+	*out = *in
+	if in.LastFullBackup != nil {
+		in, out := &in.LastFullBackup, &out.LastFullBackup
+		*out = new(BackupInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastGranularBackups != nil {
+		in, out := &in.LastGranularBackups, &out.LastGranularBackups
+		*out = make(map[string]BackupInfo, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(BackupStorageStatus)
+		**out = **in
+	}
+	if in.LastCollectionTime != nil {
+		in, out := &in.LastCollectionTime, &out.LastCollectionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]types.ServiceStatusCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreUpgradeBackup != nil {
+		in, out := &in.PreUpgradeBackup, &out.PreUpgradeBackup
+		*out = new(PreUpgradeBackupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = new(SnapshotBackupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PITR != nil {
+		in, out := &in.PITR, &out.PITR
+		*out = new(PITRStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Catalog != nil {
+		in, out := &in.Catalog, &out.Catalog
+		*out = new(BackupCatalogStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replication != nil {
+		in, out := &in.Replication, &out.Replication
+		*out = new(ReplicationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Volume != nil {
+		in, out := &in.Volume, &out.Volume
+		*out = new(BackupVolumeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = new(SchemaBackupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PlainHTTPClients != nil {
+		in, out := &in.PlainHTTPClients, &out.PlainHTTPClients
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStatus.
+func (in *BackupStatus) DeepCopy() *BackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupStatusCollector) DeepCopyInto(out *BackupStatusCollector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStatusCollector.
+func (in *BackupStatusCollector) DeepCopy() *BackupStatusCollector {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupStatusCollector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupStorageStatus) DeepCopyInto(out *BackupStorageStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStorageStatus.
+func (in *BackupStorageStatus) DeepCopy() *BackupStorageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupStorageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupVolumeMigrationStatus) DeepCopyInto(out *BackupVolumeMigrationStatus) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVolumeMigrationStatus.
+func (in *BackupVolumeMigrationStatus) DeepCopy() *BackupVolumeMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupVolumeMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupVolumeStatus) DeepCopyInto(out *BackupVolumeStatus) {
+	*out = *in
+	if in.Migration != nil {
+		in, out := &in.Migration, &out.Migration
+		*out = new(BackupVolumeMigrationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVolumeStatus.
+func (in *BackupVolumeStatus) DeepCopy() *BackupVolumeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupVolumeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Cassandra) DeepCopyInto(out *Cassandra) {
 	*out = *in
@@ -130,7 +472,7 @@ func (in *CassandraServiceSpec) DeepCopyInto(out *CassandraServiceSpec) {
 		*out = new(Policies)
 		(*in).DeepCopyInto(*out)
 	}
-	out.TLS = in.TLS
+	in.TLS.DeepCopyInto(&out.TLS)
 	in.Backup.DeepCopyInto(&out.Backup)
 	in.Dbaas.DeepCopyInto(&out.Dbaas)
 	out.Monitoring = in.Monitoring
@@ -167,6 +509,21 @@ func (in *CassandraServiceStatus) DeepCopyInto(out *CassandraServiceStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Backup != nil {
+		in, out := &in.Backup, &out.Backup
+		*out = new(BackupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Dbaas != nil {
+		in, out := &in.Dbaas, &out.Dbaas
+		*out = new(DbaasStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(TLSStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CassandraServiceStatus.
@@ -238,6 +595,30 @@ func (in *CassandraSupplServiceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateStatus) DeepCopyInto(out *CertificateStatus) {
+	*out = *in
+	if in.Deployments != nil {
+		in, out := &in.Deployments, &out.Deployments
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateStatus.
+func (in *CertificateStatus) DeepCopy() *CertificateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataCenter) DeepCopyInto(out *DataCenter) {
 	*out = *in
@@ -278,7 +659,27 @@ func (in *Dbaas) DeepCopyInto(out *Dbaas) {
 		*out = new(DbaasAggregatorCredentials)
 		**out = **in
 	}
-	out.TLS = in.TLS
+	in.TLS.DeepCopyInto(&out.TLS)
+	in.Autoscaling.DeepCopyInto(&out.Autoscaling)
+	out.Probes = in.Probes
+	if in.PhysicalDatabaseLabels != nil {
+		in, out := &in.PhysicalDatabaseLabels, &out.PhysicalDatabaseLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	out.StreamingRole = in.StreamingRole
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Dbaas.
@@ -309,7 +710,17 @@ func (in *DbaasAdapterCredentials) DeepCopy() *DbaasAdapterCredentials {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DbaasAdapterTLS) DeepCopyInto(out *DbaasAdapterTLS) {
 	*out = *in
-}
+	if in.Server != nil {
+		in, out := &in.Server, &out.Server
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Client != nil {
+		in, out := &in.Client, &out.Client
+		*out = new(bool)
+		**out = **in
+	}
+}
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DbaasAdapterTLS.
 func (in *DbaasAdapterTLS) DeepCopy() *DbaasAdapterTLS {
@@ -336,6 +747,91 @@ func (in *DbaasAggregatorCredentials) DeepCopy() *DbaasAggregatorCredentials {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DbaasAutoscaling) DeepCopyInto(out *DbaasAutoscaling) {
+	*out = *in
+	if in.TargetCPUUtilization != nil {
+		in, out := &in.TargetCPUUtilization, &out.TargetCPUUtilization
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetMemoryUtilization != nil {
+		in, out := &in.TargetMemoryUtilization, &out.TargetMemoryUtilization
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CustomMetric != nil {
+		in, out := &in.CustomMetric, &out.CustomMetric
+		*out = new(DbaasCustomMetric)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DbaasAutoscaling.
+func (in *DbaasAutoscaling) DeepCopy() *DbaasAutoscaling {
+	if in == nil {
+		return nil
+	}
+	out := new(DbaasAutoscaling)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DbaasCustomMetric) DeepCopyInto(out *DbaasCustomMetric) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DbaasCustomMetric.
+func (in *DbaasCustomMetric) DeepCopy() *DbaasCustomMetric {
+	if in == nil {
+		return nil
+	}
+	out := new(DbaasCustomMetric)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DbaasStatus) DeepCopyInto(out *DbaasStatus) {
+	*out = *in
+	if in.StreamingRole != nil {
+		in, out := &in.StreamingRole, &out.StreamingRole
+		*out = new(StreamingRoleStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Registration != nil {
+		in, out := &in.Registration, &out.Registration
+		*out = new(RegistrationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DbaasStatus.
+func (in *DbaasStatus) DeepCopy() *DbaasStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DbaasStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DbaasStreamingRole) DeepCopyInto(out *DbaasStreamingRole) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DbaasStreamingRole.
+func (in *DbaasStreamingRole) DeepCopy() *DbaasStreamingRole {
+	if in == nil {
+		return nil
+	}
+	out := new(DbaasStreamingRole)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeploymentSchema) DeepCopyInto(out *DeploymentSchema) {
 	*out = *in
@@ -377,6 +873,53 @@ func (in *Monitoring) DeepCopy() *Monitoring {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PITRRestoreStatus) DeepCopyInto(out *PITRRestoreStatus) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PITRRestoreStatus.
+func (in *PITRRestoreStatus) DeepCopy() *PITRRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PITRRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PITRStatus) DeepCopyInto(out *PITRStatus) {
+	*out = *in
+	if in.LatestRecoverablePoint != nil {
+		in, out := &in.LatestRecoverablePoint, &out.LatestRecoverablePoint
+		*out = (*in).DeepCopy()
+	}
+	if in.LastShipTime != nil {
+		in, out := &in.LastShipTime, &out.LastShipTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Restore != nil {
+		in, out := &in.Restore, &out.Restore
+		*out = new(PITRRestoreStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PITRStatus.
+func (in *PITRStatus) DeepCopy() *PITRStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PITRStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Policies) DeepCopyInto(out *Policies) {
 	*out = *in
@@ -399,6 +942,103 @@ func (in *Policies) DeepCopy() *Policies {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreUpgradeBackupStatus) DeepCopyInto(out *PreUpgradeBackupStatus) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreUpgradeBackupStatus.
+func (in *PreUpgradeBackupStatus) DeepCopy() *PreUpgradeBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PreUpgradeBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeTimings) DeepCopyInto(out *ProbeTimings) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTimings.
+func (in *ProbeTimings) DeepCopy() *ProbeTimings {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeTimings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Probes) DeepCopyInto(out *Probes) {
+	*out = *in
+	out.Liveness = in.Liveness
+	out.Readiness = in.Readiness
+	out.Startup = in.Startup
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Probes.
+func (in *Probes) DeepCopy() *Probes {
+	if in == nil {
+		return nil
+	}
+	out := new(Probes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistrationStatus) DeepCopyInto(out *RegistrationStatus) {
+	*out = *in
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrationStatus.
+func (in *RegistrationStatus) DeepCopy() *RegistrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicationStatus) DeepCopyInto(out *ReplicationStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]BackupReplicationState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicationStatus.
+func (in *ReplicationStatus) DeepCopy() *ReplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RobotTests) DeepCopyInto(out *RobotTests) {
 	*out = *in
@@ -419,6 +1059,16 @@ func (in *RobotTests) DeepCopyInto(out *RobotTests) {
 			(*out)[key] = val
 		}
 	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RobotTests.
@@ -446,9 +1096,135 @@ func (in *S3backup) DeepCopy() *S3backup {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SchemaBackupStatus) DeepCopyInto(out *SchemaBackupStatus) {
+	*out = *in
+	if in.LastExportTime != nil {
+		in, out := &in.LastExportTime, &out.LastExportTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Versions != nil {
+		in, out := &in.Versions, &out.Versions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SchemaBackupStatus.
+func (in *SchemaBackupStatus) DeepCopy() *SchemaBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SchemaBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotBackupStatus) DeepCopyInto(out *SnapshotBackupStatus) {
+	*out = *in
+	if in.LastGroup != nil {
+		in, out := &in.LastGroup, &out.LastGroup
+		*out = new(SnapshotOperationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetainedGroups != nil {
+		in, out := &in.RetainedGroups, &out.RetainedGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Restore != nil {
+		in, out := &in.Restore, &out.Restore
+		*out = new(SnapshotOperationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotBackupStatus.
+func (in *SnapshotBackupStatus) DeepCopy() *SnapshotBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotOperationStatus) DeepCopyInto(out *SnapshotOperationStatus) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotOperationStatus.
+func (in *SnapshotOperationStatus) DeepCopy() *SnapshotOperationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotOperationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StreamingRoleStatus) DeepCopyInto(out *StreamingRoleStatus) {
+	*out = *in
+	if in.Permissions != nil {
+		in, out := &in.Permissions, &out.Permissions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PasswordRotationTime != nil {
+		in, out := &in.PasswordRotationTime, &out.PasswordRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StreamingRoleStatus.
+func (in *StreamingRoleStatus) DeepCopy() *StreamingRoleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StreamingRoleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLS) DeepCopyInto(out *TLS) {
 	*out = *in
+	if in.Issuer != nil {
+		in, out := &in.Issuer, &out.Issuer
+		*out = new(TLSIssuer)
+		**out = **in
+	}
+	if in.SelfSigned != nil {
+		in, out := &in.SelfSigned, &out.SelfSigned
+		*out = new(TLSSelfSigned)
+		**out = **in
+	}
+	if in.ExpiryWarningThresholds != nil {
+		in, out := &in.ExpiryWarningThresholds, &out.ExpiryWarningThresholds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLS.
@@ -460,3 +1236,59 @@ func (in *TLS) DeepCopy() *TLS {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSIssuer) DeepCopyInto(out *TLSIssuer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSIssuer.
+func (in *TLSIssuer) DeepCopy() *TLSIssuer {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSIssuer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSSelfSigned) DeepCopyInto(out *TLSSelfSigned) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSSelfSigned.
+func (in *TLSSelfSigned) DeepCopy() *TLSSelfSigned {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSSelfSigned)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSStatus) DeepCopyInto(out *TLSStatus) {
+	*out = *in
+	if in.Certificates != nil {
+		in, out := &in.Certificates, &out.Certificates
+		*out = make([]CertificateStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSStatus.
+func (in *TLSStatus) DeepCopy() *TLSStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSStatus)
+	in.DeepCopyInto(out)
+	return out
+}
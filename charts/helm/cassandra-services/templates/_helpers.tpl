@@ -270,6 +270,16 @@ Dictionary with:
 {{/*
 DNS names used to generate SSL certificate with "Subject Alternative Name" field
 */}}
+{{/*
+Whether the DBaaS adapter serves HTTPS, follows the operator decision for dbaas.tls.server
+*/}}
+{{- define "dbaasAdapter.serverTLS" -}}
+  {{- if hasKey .Values.dbaas.tls "server" -}}
+    {{- .Values.dbaas.tls.server -}}
+  {{- else -}}
+    {{- and .Values.tls.enabled (hasPrefix "https://" .Values.dbaas.aggregator.dbaasAggregatorRegistrationAddress) -}}
+  {{- end -}}
+{{- end -}}
 {{- define "dbaasAdapter.certDnsNames" -}}
   {{- $dnsNames := list "localhost" "dbaas-cassandra-adapter" (printf "%s.%s" "dbaas-cassandra-adapter" .Release.Namespace) (printf "%s.%s.svc" "dbaas-cassandra-adapter" .Release.Namespace) -}}
   {{- $dnsNames = concat $dnsNames .Values.tls.generateCerts.subjectAlternativeName.additionalDnsNames -}}
@@ -29,12 +29,17 @@ import (
 	k8type "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	impl "github.com/Netcracker/qubership-cassandra-supplementary/pkg"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/backup"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/common"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/dbaas"
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/types"
 )
@@ -44,21 +49,165 @@ var setupLog = ctrl.Log.WithName("setup")
 // CassandraSupplServiceReconciler reconciles a CassandraService object
 type CassandraSupplServiceReconciler struct {
 	client.Client
-	Scheme     *runtime.Scheme
-	Reconciler reconcile.Reconciler
+	Scheme                *runtime.Scheme
+	Reconciler            reconcile.Reconciler
+	BackupStatusCollector *backup.StatusCollector
+	SnapshotBackuper      *backup.SnapshotBackuper
+	CommitlogShipper      *backup.CommitlogShipper
+	BackupReplicator      *backup.BackupReplicator
+	SchemaBackuper        *backup.SchemaBackuper
+	StreamingRoleManager  *dbaas.StreamingRoleManager
+	RegistrationManager   *dbaas.RegistrationManager
+	CertificateRoller     *common.CertificateRoller
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 func (r *CassandraSupplServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	err := WaitForCassandraOperatorReady(r.Client, "cassandra-operator", req.Namespace)
+	// a deleted custom resource is only released, Cassandra may be already gone
+	deleting, err := r.RegistrationManager.Finalize(ctx, req, core.GetLogger(false))
+	if deleting {
+		if err != nil {
+			setupLog.Error(err, "DBaaS physical database deregistration failed")
+			return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
+	err = WaitForCassandraOperatorReady(r.Client, "cassandra-operator", req.Namespace)
 	if err != nil {
 		setupLog.Info("Cassandra Operator is not ready...")
 		return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
 	}
 
 	_ = log.FromContext(ctx)
-	return r.Reconciler.Reconcile(ctx, req)
+	result, err := r.Reconciler.Reconcile(ctx, req)
+	if err != nil {
+		return result, err
+	}
+
+	result = r.awaitBackupOperations(ctx, req, result)
+	result = r.makeSnapshots(ctx, req, result)
+	result = r.shipCommitlogs(ctx, req, result)
+	result = r.replicateBackups(ctx, req, result)
+	result = r.backupSchema(ctx, req, result)
+	result = r.manageStreamingRole(ctx, req, result)
+	result = r.trackRegistration(ctx, req, result)
+	result = r.rollCertificates(ctx, req, result)
+	return r.collectBackupStatus(ctx, req, result), nil
+}
+
+// awaitBackupOperations schedules the next check of the pre-upgrade backup or the backup volume copy the deployment waits for
+func (r *CassandraSupplServiceReconciler) awaitBackupOperations(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	spec := &v1alpha1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, req.NamespacedName, spec); err != nil {
+		if !errors.IsNotFound(err) {
+			core.GetLogger(false).Error(fmt.Sprintf("Backup operations check failed: %v", err))
+		}
+		return result
+	}
+	if backup.PreUpgradeBackupPending(spec) {
+		result = requeueAfter(result, backup.PreUpgradePollInterval)
+	}
+	if backup.BackupVolumeMigrationPending(spec) {
+		result = requeueAfter(result, backup.BackupVolumePollInterval)
+	}
+	return result
+}
+
+// makeSnapshots creates a snapshot group when it is due and schedules the next one
+func (r *CassandraSupplServiceReconciler) makeSnapshots(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	after, err := r.SnapshotBackuper.Run(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Snapshot backup failed: %v", err))
+	}
+
+	return requeueAfter(result, after)
+}
+
+// shipCommitlogs moves archived commitlog segments to the backup storage and schedules the next shipment
+func (r *CassandraSupplServiceReconciler) shipCommitlogs(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	after, err := r.CommitlogShipper.Run(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Commitlog shipment failed: %v", err))
+	}
+
+	return requeueAfter(result, after)
+}
+
+// replicateBackups copies new backups to the secondary storage and schedules the next replication
+func (r *CassandraSupplServiceReconciler) replicateBackups(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	after, err := r.BackupReplicator.Run(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Backup replication failed: %v", err))
+	}
+
+	return requeueAfter(result, after)
+}
+
+// backupSchema exports the Cassandra schema when it is due and schedules the next export
+func (r *CassandraSupplServiceReconciler) backupSchema(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	after, err := r.SchemaBackuper.Run(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Schema backup failed: %v", err))
+	}
+
+	return requeueAfter(result, after)
+}
+
+// manageStreamingRole keeps the DBaaS streaming role in Cassandra and schedules the next check
+func (r *CassandraSupplServiceReconciler) manageStreamingRole(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	after, err := r.StreamingRoleManager.Run(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("DBaaS streaming role management failed: %v", err))
+	}
+
+	return requeueAfter(result, after)
+}
+
+// trackRegistration records the DBaaS aggregator registration and schedules the next check
+func (r *CassandraSupplServiceReconciler) trackRegistration(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	after, err := r.RegistrationManager.Run(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("DBaaS registration check failed: %v", err))
+	}
+
+	return requeueAfter(result, after)
+}
+
+// rollCertificates checks the mounted certificates, restarts components with changed TLS secrets and schedules the next check
+func (r *CassandraSupplServiceReconciler) rollCertificates(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	after, err := r.CertificateRoller.Run(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Certificate check failed: %v", err))
+	}
+
+	return requeueAfter(result, after)
+}
+
+// collectBackupStatus refreshes `status.backup` and schedules the next collection
+func (r *CassandraSupplServiceReconciler) collectBackupStatus(ctx context.Context, req ctrl.Request, result ctrl.Result) ctrl.Result {
+	logger := core.GetLogger(false)
+	interval, err := r.BackupStatusCollector.Collect(ctx, req, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Backup status collection failed: %v", err))
+	}
+
+	return requeueAfter(result, interval)
+}
+
+func requeueAfter(result ctrl.Result, after time.Duration) ctrl.Result {
+	if after > 0 && (result.RequeueAfter == 0 || after < result.RequeueAfter) {
+		result.RequeueAfter = after
+	}
+	return result
 }
 
 func WaitForCassandraOperatorReady(k8sClient client.Client, name, namespace string) error {
@@ -123,8 +272,54 @@ func WaitForCassandraOperatorReady(k8sClient client.Client, name, namespace stri
 // SetupWithManager sets up the controller with the Manager.
 func (r *CassandraSupplServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Reconciler = newCassandraServiceReconciler(mgr)
+	r.BackupStatusCollector = &backup.StatusCollector{
+		Client:        mgr.GetClient(),
+		ClientBuilder: &backup.DaemonClientBuilderImpl{},
+	}
+	helper := &core.DefaultKubernetesHelperImpl{Client: mgr.GetClient()}
+	r.SnapshotBackuper = &backup.SnapshotBackuper{
+		Client:     mgr.GetClient(),
+		KubeConfig: mgr.GetConfig(),
+		Helper:     helper,
+	}
+	storeBuilder := &backup.ObjectStoreBuilderImpl{
+		Helper:     helper,
+		KubeConfig: mgr.GetConfig(),
+		Log:        core.GetLogger(false),
+	}
+	r.CommitlogShipper = &backup.CommitlogShipper{
+		Client:       mgr.GetClient(),
+		Helper:       helper,
+		StoreBuilder: storeBuilder,
+	}
+	r.BackupReplicator = &backup.BackupReplicator{
+		Client:        mgr.GetClient(),
+		ClientBuilder: &backup.DaemonClientBuilderImpl{},
+		StoreBuilder:  storeBuilder,
+	}
+	r.SchemaBackuper = &backup.SchemaBackuper{
+		Client:       mgr.GetClient(),
+		Reader:       &backup.CQLSchemaReader{},
+		StoreBuilder: storeBuilder,
+		Recorder:     mgr.GetEventRecorderFor("cassandra-services-operator"),
+	}
+	r.StreamingRoleManager = &dbaas.StreamingRoleManager{
+		Client:   mgr.GetClient(),
+		Builder:  &dbaas.CQLRoleStoreBuilder{},
+		Recorder: mgr.GetEventRecorderFor("cassandra-services-operator"),
+	}
+	r.RegistrationManager = &dbaas.RegistrationManager{
+		Client:        mgr.GetClient(),
+		ClientBuilder: &dbaas.AggregatorClientBuilderImpl{},
+		Recorder:      mgr.GetEventRecorderFor("cassandra-services-operator"),
+	}
+	r.CertificateRoller = &common.CertificateRoller{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("cassandra-services-operator"),
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.CassandraSupplService{}).
+		// status writes do not change the generation, the periodic tasks are scheduled with RequeueAfter
+		For(&v1alpha1.CassandraSupplService{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
 
@@ -6,8 +6,10 @@ require (
 	github.com/Netcracker/qubership-cql-driver v0.0.2
 	github.com/Netcracker/qubership-credential-manager v0.0.11
 	github.com/Netcracker/qubership-nosqldb-operator-core v1.0.7
+	github.com/aws/aws-sdk-go v1.49.12
 	github.com/gocql/gocql v1.6.0
 	github.com/hashicorp/vault/api v1.1.2-0.20210713235431-1fc8af4c041f
+	github.com/prometheus/client_golang v1.22.0
 	github.com/stretchr/testify v1.11.1
 	go.uber.org/zap v1.27.0
 	golang.org/x/crypto v0.43.0
@@ -15,6 +17,7 @@ require (
 	k8s.io/apimachinery v0.34.0
 	k8s.io/client-go v0.34.0
 	sigs.k8s.io/controller-runtime v0.22.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
 // replace github.com/Netcracker/qubership-nosqldb-operator-core => ../nosqldb-operator-core
@@ -23,7 +26,6 @@ require (
 
 require (
 	github.com/armon/go-metrics v0.4.1 // indirect
-	github.com/aws/aws-sdk-go v1.49.12 // indirect
 	github.com/aws/aws-sigv4-auth-cassandra-gocql-driver-plugin v1.1.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cenkalti/backoff/v3 v3.0.0 // indirect
@@ -90,7 +92,6 @@ require (
 	github.com/mxk/go-flowrate v0.0.0-20140419014527-cca7078d478f // indirect
 	github.com/pierrec/lz4 v2.5.2+incompatible // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
-	github.com/prometheus/client_golang v1.22.0 // indirect
 	github.com/prometheus/client_model v0.6.1 // indirect
 	github.com/prometheus/common v0.62.0 // indirect
 	github.com/prometheus/procfs v0.15.1 // indirect
@@ -2,13 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
-	cqlMocks "github.com/Netcracker/qubership-cql-driver/mocks"
 	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/backup"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/common"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/dbaas"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	cql "github.com/Netcracker/qubership-cql-driver"
+	cqlMocks "github.com/Netcracker/qubership-cql-driver/mocks"
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
 	mTypes "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/types"
@@ -20,15 +40,26 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	v1app "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	v1core "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 type FakeVaultImpl struct {
@@ -541,3 +572,2701 @@ func TestExecutionCheck(t *testing.T) {
 		})
 	}
 }
+
+type testDaemonClientBuilder struct {
+	address string
+}
+
+func (b *testDaemonClientBuilder) Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (backup.DaemonClient, error) {
+	daemonClient, err := (&backup.DaemonClientBuilderImpl{}).Build(kubeClient, spec, namespace)
+	if err != nil {
+		return nil, err
+	}
+	daemonClient.(*backup.DaemonClientImpl).Address = b.address
+	return daemonClient, nil
+}
+
+func newBackupDaemonStandIn(t *testing.T, backups map[string]map[string]interface{}, jobStatus string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "backup" || pass != "backup-pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var response interface{}
+		switch {
+		case r.URL.Path == "/health":
+			response = map[string]interface{}{
+				"status":             "UP",
+				"storage":            map[string]interface{}{"dump_count": len(backups), "size": 300, "total_space": 1000, "free_space": 700},
+				"plain_http_clients": []string{"10.129.4.17"},
+			}
+		case r.URL.Path == "/backup" && r.Method == http.MethodPost:
+			fmt.Fprint(w, "pre-upgrade-backup")
+			return
+		case strings.HasPrefix(r.URL.Path, "/restore/") && r.Method == http.MethodPost:
+			fmt.Fprint(w, "restore-"+r.URL.Path[len("/restore/"):])
+			return
+		case strings.HasPrefix(r.URL.Path, "/evict/") && r.Method == http.MethodPost:
+			delete(backups, r.URL.Path[len("/evict/"):])
+			return
+		case strings.HasPrefix(r.URL.Path, "/jobstatus/"):
+			response = map[string]interface{}{"status": jobStatus}
+		case r.URL.Path == "/listbackups":
+			ids := []string{}
+			for id := range backups {
+				ids = append(ids, id)
+			}
+			response = ids
+		default:
+			backupInfo, found := backups[r.URL.Path[len("/listbackups/"):]]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			response = backupInfo
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func TestBackupStatusCollector(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		backups         map[string]map[string]interface{}
+		expectOutdated  bool
+		expectFull      string
+		expectGranular  map[string]string
+		expectRetained  int
+		expectUnhealthy bool
+	}{
+		{
+			name: "Fresh full and granular backups",
+			backups: map[string]map[string]interface{}{
+				"full-old": {"id": "full-old", "failed": false, "is_granular": false, "db_list": "full backup", "ts": now.Add(-48 * time.Hour).UnixMilli()},
+				"full-new": {"id": "full-new", "failed": true, "is_granular": false, "db_list": "full backup", "ts": now.Add(-time.Hour).UnixMilli()},
+				"gran":     {"id": "gran", "failed": false, "is_granular": true, "db_list": []string{"ks1", "ks2"}, "ts": now.Add(-2 * time.Hour).UnixMilli()},
+			},
+			expectOutdated: false,
+			expectFull:     "full-new",
+			expectGranular: map[string]string{"ks1": "gran", "ks2": "gran"},
+			expectRetained: 3,
+		},
+		{
+			name: "Outdated backups",
+			backups: map[string]map[string]interface{}{
+				"full-old": {"id": "full-old", "failed": false, "is_granular": false, "db_list": "full backup", "ts": now.Add(-48 * time.Hour).UnixMilli()},
+			},
+			expectOutdated: true,
+			expectFull:     "full-old",
+			expectRetained: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daemon := newBackupDaemonStandIn(t, tt.backups, backup.JobStatusSuccessful)
+			defer daemon.Close()
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = v1.AddToScheme(scheme)
+
+			cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+			cr.Name = "cassandra-services"
+			cr.Namespace = nameSpace
+			cr.Spec.Backup.StatusCollector = v1.BackupStatusCollector{Enabled: true, Interval: "1m", StaleThreshold: "25h"}
+
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(cr, generateSecrets(nameSpace, cr.Spec.Backup.SecretName, "backup", "backup-pass")).
+				WithStatusSubresource(cr).Build()
+
+			collector := &backup.StatusCollector{Client: kubeClient, ClientBuilder: &testDaemonClientBuilder{address: daemon.URL}}
+			request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+			interval, err := collector.Collect(context.TODO(), request, core.GetLogger(true))
+			assert.NoError(t, err)
+			assert.Equal(t, time.Minute, interval)
+
+			result := &v1.CassandraSupplService{}
+			assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+			status := result.Status.Backup
+			if !assert.NotNil(t, status) {
+				return
+			}
+			assert.Equal(t, tt.expectRetained, status.RetainedBackups)
+			assert.Equal(t, []string{"10.129.4.17"}, status.PlainHTTPClients)
+			assert.Equal(t, tt.expectFull, status.LastFullBackup.Id)
+			assert.Equal(t, int64(300), status.Storage.UsedBytes)
+			for keyspace, id := range tt.expectGranular {
+				assert.Equal(t, id, status.LastGranularBackups[keyspace].Id)
+			}
+			for _, condition := range status.Conditions {
+				switch condition.Type {
+				case backup.BackupOutdated:
+					assert.Equal(t, tt.expectOutdated, condition.Status)
+				case backup.BackupDaemonUnavailable:
+					assert.False(t, condition.Status)
+				}
+			}
+
+			// the daemon is not queried again until the interval passes
+			daemon.Close()
+			interval, err = collector.Collect(context.TODO(), request, core.GetLogger(true))
+			assert.NoError(t, err)
+			assert.True(t, interval > 0 && interval <= time.Minute)
+			again := &v1.CassandraSupplService{}
+			assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, again))
+			assert.Equal(t, result.ResourceVersion, again.ResourceVersion)
+		})
+	}
+}
+
+func TestPreUpgradeBackupStep(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+
+	tests := []struct {
+		name           string
+		daemonDeployed bool
+		backedUp       string
+		jobStatus      string
+		expectBackup   bool
+		expectedResult string
+	}{
+		{
+			name:         "Clean deploy",
+			jobStatus:    backup.JobStatusSuccessful,
+			expectBackup: false,
+		},
+		{
+			// the status is empty after the operator upgrade or when the last reconcile failed
+			name:           "Upgrade without backed up versions",
+			daemonDeployed: true,
+			jobStatus:      backup.JobStatusSuccessful,
+			expectBackup:   true,
+			expectedResult: backup.BackupResultSuccessful,
+		},
+		{
+			name:           "Upgrade with successful backup",
+			daemonDeployed: true,
+			backedUp:       "previous-version-hash",
+			jobStatus:      backup.JobStatusSuccessful,
+			expectBackup:   true,
+			expectedResult: backup.BackupResultSuccessful,
+		},
+		{
+			name:           "Upgrade with failed backup",
+			daemonDeployed: true,
+			backedUp:       "previous-version-hash",
+			jobStatus:      backup.JobStatusFailed,
+			expectBackup:   true,
+			expectedResult: backup.BackupResultFailed,
+		},
+		{
+			name:           "Upgrade with running backup",
+			daemonDeployed: true,
+			backedUp:       "previous-version-hash",
+			jobStatus:      backup.JobStatusProcessing,
+			expectBackup:   true,
+			expectedResult: backup.BackupResultInProgress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daemon := newBackupDaemonStandIn(t, nil, tt.jobStatus)
+			defer daemon.Close()
+
+			cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+			cr.Spec.DeploymentVersion = "2.0.0"
+			cr.Spec.Backup.BackupBeforeUpgrade = true
+			if tt.backedUp != "" {
+				cr.Status.Backup = &v1.BackupStatus{BackedUpVersionHash: tt.backedUp}
+			}
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = v1.AddToScheme(scheme)
+			cr.Name = "cassandra-services"
+			cr.Namespace = nameSpace
+
+			specHash := &v1core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-services-spec", Namespace: nameSpace}}
+			objects := []client.Object{cr, specHash, generateSecrets(nameSpace, cr.Spec.Backup.SecretName, "backup", "backup-pass")}
+			if tt.daemonDeployed {
+				objects = append(objects, &v1app.Deployment{ObjectMeta: metav1.ObjectMeta{Name: utils.BackupDaemon, Namespace: nameSpace}})
+			}
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			ctx := core.GetExecutionContext(map[string]interface{}{
+				constants.ContextSpec:   cr,
+				constants.ContextSchema: scheme,
+				constants.ContextRequest: reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: "cassandra-name"},
+				},
+				constants.ContextClient:                kubeClient,
+				constants.ContextLogger:                core.GetLogger(true),
+				constants.ContextHashConfigMap:         specHash.Name,
+				utils.ContextBackupDaemonClientBuilder: &testDaemonClientBuilder{address: daemon.URL},
+			})
+
+			step := &backup.PreUpgradeBackupStep{Timeout: time.Hour}
+			gate := &backup.PreUpgradeBackupGate{}
+			run, err := step.Condition(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectBackup, run)
+
+			if !tt.expectBackup {
+				// the installed versions need no backup on the next upgrade
+				assert.Equal(t, backup.ComponentVersionsHash(cr), cr.Status.Backup.BackedUpVersionHash)
+				return
+			}
+
+			switch tt.expectedResult {
+			case backup.BackupResultFailed:
+				assert.Panics(t, func() { _ = step.Execute(ctx) })
+				assert.Equal(t, tt.backedUp, cr.Status.Backup.BackedUpVersionHash)
+			case backup.BackupResultInProgress:
+				// the reconcile does not wait for the backup, the components are skipped and upgraded by a later reconcile
+				assert.NoError(t, step.Execute(ctx))
+				assert.Equal(t, tt.backedUp, cr.Status.Backup.BackedUpVersionHash)
+				assert.True(t, backup.PreUpgradeBackupPending(cr))
+				run, err = gate.Condition(ctx)
+				assert.NoError(t, err)
+				assert.False(t, run)
+				err = kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(specHash), &v1core.ConfigMap{})
+				assert.True(t, errors.IsNotFound(err))
+
+				// the same job is checked until it is completed
+				started := cr.Status.Backup.PreUpgradeBackup.Time
+				completed := newBackupDaemonStandIn(t, nil, backup.JobStatusSuccessful)
+				defer completed.Close()
+				ctx.Set(utils.ContextBackupDaemonClientBuilder, &testDaemonClientBuilder{address: completed.URL})
+				assert.NoError(t, step.Execute(ctx))
+				assert.Equal(t, started, cr.Status.Backup.PreUpgradeBackup.Time)
+				assert.Equal(t, backup.ComponentVersionsHash(cr), cr.Status.Backup.BackedUpVersionHash)
+				run, err = gate.Condition(ctx)
+				assert.NoError(t, err)
+				assert.True(t, run)
+
+				// a backup running longer than the timeout blocks the upgrade
+				cr.Status.Backup.PreUpgradeBackup.Result = backup.BackupResultInProgress
+				cr.Status.Backup.PreUpgradeBackup.Time = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+				ctx.Set(utils.ContextBackupDaemonClientBuilder, &testDaemonClientBuilder{address: daemon.URL})
+				assert.Panics(t, func() { _ = step.Execute(ctx) })
+				assert.Equal(t, backup.BackupResultFailed, cr.Status.Backup.PreUpgradeBackup.Result)
+				return
+			default:
+				assert.NoError(t, step.Execute(ctx))
+				assert.Equal(t, backup.ComponentVersionsHash(cr), cr.Status.Backup.BackedUpVersionHash)
+			}
+			// the upgrade stays blocked until the backup succeeds
+			run, err = step.Condition(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedResult == backup.BackupResultFailed, run)
+
+			status := cr.Status.Backup.PreUpgradeBackup
+			assert.Equal(t, "pre-upgrade-backup", status.Id)
+			assert.Equal(t, "2.0.0", status.DeploymentVersion)
+			assert.Equal(t, tt.expectedResult, status.Result)
+		})
+	}
+}
+
+type snapshotTestHelper struct {
+	TestUtilsImpl
+	pods     []v1core.Pod
+	commands []string
+}
+
+func (r *snapshotTestHelper) ListPods(namespace string, labelSelectors map[string]string) (*v1core.PodList, error) {
+	return &v1core.PodList{Items: r.pods}, nil
+}
+
+func (r *snapshotTestHelper) ExecRemote(log *zap.Logger, kubeConfig *rest.Config, podName string, namespace string, containerName string, command string, args []string) (string, error) {
+	r.commands = append(r.commands, fmt.Sprintf("%s: %s", podName, strings.Join(args, " ")))
+	return "", nil
+}
+
+func TestSnapshotBackup(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	now := time.Now()
+	storageClass := "csi-storage"
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	scheme.AddKnownTypeWithName(backup.VolumeSnapshotGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(backup.VolumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotList"), &unstructured.UnstructuredList{})
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Backup.EvictionPolicy = "0/1d,7d/delete"
+	cr.Spec.Backup.Snapshots = v1.BackupSnapshots{Enabled: true, Interval: "1h", VolumeSnapshotClassName: "csi-snapclass"}
+
+	objects := []client.Object{cr}
+	helper := &snapshotTestHelper{}
+	for i := 0; i < 2; i++ {
+		pvc := &v1core.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("data-cassandra%d-0", i), Namespace: nameSpace, Labels: map[string]string{"app": "cassandra"}},
+			Spec: v1core.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClass,
+				AccessModes:      []v1core.PersistentVolumeAccessMode{v1core.ReadWriteOnce},
+				Resources: v1core.VolumeResourceRequirements{
+					Requests: v1core.ResourceList{v1core.ResourceStorage: resource.MustParse("10Gi")},
+				},
+			},
+		}
+		objects = append(objects, pvc)
+		helper.pods = append(helper.pods, v1core.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("cassandra%d-0", i), Namespace: nameSpace},
+			Spec: v1core.PodSpec{
+				Containers: []v1core.Container{{Name: "cassandra"}},
+				Volumes: []v1core.Volume{{
+					Name:         "data",
+					VolumeSource: v1core.VolumeSource{PersistentVolumeClaim: &v1core.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name}},
+				}},
+			},
+		})
+	}
+
+	// groups made 30 hours and 10 days ago, the latter is evicted by the policy
+	for _, age := range []time.Duration{30 * time.Hour, 240 * time.Hour} {
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(backup.VolumeSnapshotGVK)
+		group := now.Add(-age).UTC().Format("20060102-150405")
+		snapshot.SetName("data-cassandra0-0-" + group)
+		snapshot.SetNamespace(nameSpace)
+		snapshot.SetLabels(map[string]string{utils.SnapshotGroupLabel: group})
+		objects = append(objects, snapshot)
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithStatusSubresource(cr).Build()
+	backuper := &backup.SnapshotBackuper{Client: kubeClient, KubeConfig: &rest.Config{}, Helper: helper}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+	log := core.GetLogger(true)
+
+	after, err := backuper.Run(context.TODO(), request, log)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, after)
+	assert.Equal(t, []string{"cassandra0-0: nodetool flush", "cassandra1-0: nodetool flush"}, helper.commands)
+
+	groups, err := backup.ListSnapshotGroups(kubeClient, nameSpace)
+	assert.NoError(t, err)
+	if !assert.Equal(t, 2, len(groups)) {
+		return
+	}
+	newGroup := groups[0]
+	assert.Equal(t, 2, len(newGroup.Snapshots))
+	for _, snapshot := range newGroup.Snapshots {
+		className, _, _ := unstructured.NestedString(snapshot.Object, "spec", "volumeSnapshotClassName")
+		assert.Equal(t, "csi-snapclass", className)
+	}
+
+	result := &v1.CassandraSupplService{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	assert.Equal(t, backup.BackupResultInProgress, result.Status.Backup.Snapshots.LastGroup.Result)
+	assert.Equal(t, []string{newGroup.Name, groups[1].Name}, result.Status.Backup.Snapshots.RetainedGroups)
+
+	// the group succeeds once the snapshot controller reports every snapshot ready to use
+	after, err = backuper.Run(context.TODO(), request, log)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, after)
+	assert.ErrorContains(t, backup.RestoreSnapshotGroup(kubeClient, nameSpace, newGroup.Name, log), "not ready")
+	for i := range newGroup.Snapshots {
+		snapshot := &newGroup.Snapshots[i]
+		assert.NoError(t, unstructured.SetNestedField(snapshot.Object, true, "status", "readyToUse"))
+		assert.NoError(t, kubeClient.Update(context.TODO(), snapshot))
+	}
+	after, err = backuper.Run(context.TODO(), request, log)
+	assert.NoError(t, err)
+	assert.True(t, after > 30*time.Second && after <= time.Hour)
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	assert.Equal(t, backup.BackupResultSuccessful, result.Status.Backup.Snapshots.LastGroup.Result)
+
+	// the next group is not due yet
+	after, err = backuper.Run(context.TODO(), request, log)
+	assert.NoError(t, err)
+	assert.True(t, after > 0 && after <= time.Hour)
+	assert.Equal(t, 2, len(helper.commands))
+
+	// PVCs must be removed before the restore
+	assert.Error(t, backup.RestoreSnapshotGroup(kubeClient, nameSpace, newGroup.Name, log))
+	for i := 0; i < 2; i++ {
+		pvc := &v1core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("data-cassandra%d-0", i), Namespace: nameSpace}}
+		assert.NoError(t, kubeClient.Delete(context.TODO(), pvc))
+	}
+	assert.NoError(t, backup.RestoreSnapshotGroup(kubeClient, nameSpace, newGroup.Name, log))
+	// restore is idempotent
+	assert.NoError(t, backup.RestoreSnapshotGroup(kubeClient, nameSpace, newGroup.Name, log))
+
+	restored := &v1core.PersistentVolumeClaim{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: nameSpace, Name: "data-cassandra1-0"}, restored))
+	assert.Equal(t, "VolumeSnapshot", restored.Spec.DataSource.Kind)
+	assert.Equal(t, "data-cassandra1-0-"+newGroup.Name, restored.Spec.DataSource.Name)
+	assert.Equal(t, storageClass, *restored.Spec.StorageClassName)
+	assert.Equal(t, "10Gi", restored.Spec.Resources.Requests.Storage().String())
+	assert.Equal(t, "cassandra", restored.Labels["app"])
+
+	assert.Error(t, backup.RestoreSnapshotGroup(kubeClient, nameSpace, "missing-group", log))
+}
+
+func TestEvictionPolicy(t *testing.T) {
+	now := time.Now()
+	rules, err := utils.ParseEvictionPolicy("0/1h,3d/7d,1y/delete")
+	assert.NoError(t, err)
+	assert.Equal(t, []utils.EvictionRule{
+		{Start: 0, Interval: time.Hour},
+		{Start: 72 * time.Hour, Interval: 7 * 24 * time.Hour},
+		{Start: 365 * 24 * time.Hour, Delete: true},
+	}, rules)
+
+	times := []time.Time{
+		now.Add(-10 * time.Minute),
+		now.Add(-20 * time.Minute),
+		now.Add(-90 * time.Minute),
+		now.Add(-4 * 24 * time.Hour),
+		now.Add(-5 * 24 * time.Hour),
+		now.Add(-400 * 24 * time.Hour),
+	}
+	assert.Equal(t, []int{1, 4, 5}, utils.EvictedItems(times, rules, now))
+
+	_, err = utils.ParseEvictionPolicy("0/1h,3x/delete")
+	assert.Error(t, err)
+}
+
+type memoryObjectStore map[string][]byte
+
+func (s memoryObjectStore) List(prefix string) ([]string, error) {
+	var keys []string
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s memoryObjectStore) Put(key string, data []byte) error {
+	s[key] = data
+	return nil
+}
+
+func (s memoryObjectStore) Get(key string) ([]byte, error) {
+	data, found := s[key]
+	if !found {
+		return nil, fmt.Errorf("%s not found", key)
+	}
+	return data, nil
+}
+
+func (s memoryObjectStore) Delete(key string) error {
+	delete(s, key)
+	return nil
+}
+
+type testObjectStoreBuilder struct {
+	store   memoryObjectStore
+	replica memoryObjectStore
+}
+
+func (b *testObjectStoreBuilder) Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (backup.ObjectStore, error) {
+	return b.store, nil
+}
+
+func (b *testObjectStoreBuilder) BuildReplica(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (backup.ObjectStore, error) {
+	return b.replica, nil
+}
+
+// pitrTestHelper records pod restarts with the commitlog archiving properties Cassandra starts with
+type pitrTestHelper struct {
+	TestUtilsImpl
+	client     client.Client
+	pods       []v1core.Pod
+	restarted  []string
+	properties []string
+	commands   []string
+}
+
+func (r *pitrTestHelper) ListPods(namespace string, labelSelectors map[string]string) (*v1core.PodList, error) {
+	return &v1core.PodList{Items: r.pods}, nil
+}
+
+func (r *pitrTestHelper) RestartPod(pod *v1core.Pod, namespace string, waitSeconds int) error {
+	cm := &v1core.ConfigMap{}
+	if err := r.client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: utils.CommitlogArchivingConfig}, cm); err != nil {
+		return err
+	}
+	r.restarted = append(r.restarted, pod.Name)
+	r.properties = append(r.properties, cm.Data[utils.CommitlogArchivingConfigFile])
+	return nil
+}
+
+func (r *pitrTestHelper) ExecRemote(log *zap.Logger, kubeConfig *rest.Config, podName string, namespace string, containerName string, command string, args []string) (string, error) {
+	r.commands = append(r.commands, fmt.Sprintf("%s: %s", podName, strings.Join(args, "\n")))
+	return "", nil
+}
+
+func TestPointInTimeRecovery(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	now := time.Now().Truncate(time.Second)
+	archive := "/var/lib/cassandra/data/commitlog_archive"
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Backup.PITR = v1.BackupPITR{Enabled: true, ShipInterval: "1m"}
+	cr.Spec.Backup.S3 = v1.S3backup{Enabled: true, BucketName: "backups", EndpointUrl: "https://s3.example.com", SecretName: "s3-secret", SslVerify: true, SslSecretName: "s3-ca"}
+
+	// staging jobs complete at once
+	var staged []string
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(cr, generateSecrets(nameSpace, cr.Spec.Backup.SecretName, "backup", "backup-pass")).
+		WithStatusSubresource(cr).
+		WithInterceptorFuncs(interceptor.Funcs{Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if job, ok := obj.(*batchv1.Job); ok && strings.HasPrefix(job.Name, "cassandra-commitlog-staging-") {
+				staged = append(staged, job.Spec.Template.Spec.Containers[0].Command[2])
+				job.Status.Succeeded = 1
+			}
+			return c.Create(ctx, obj, opts...)
+		}}).Build()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+
+	helper := &pitrTestHelper{client: kubeClient}
+	fsGroup := int64(999)
+	for i := 0; i < 2; i++ {
+		name := fmt.Sprintf("cassandra%d-0", i)
+		helper.pods = append(helper.pods, v1core.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: nameSpace},
+			Spec: v1core.PodSpec{
+				NodeName:        fmt.Sprintf("node-%d", i),
+				SecurityContext: &v1core.PodSecurityContext{FSGroup: &fsGroup},
+				Containers: []v1core.Container{{Name: "cassandra", VolumeMounts: []v1core.VolumeMount{
+					{Name: "config", MountPath: "/etc/cassandra"},
+					{Name: "data", MountPath: "/var/lib/cassandra/data"},
+				}}},
+				Volumes: []v1core.Volume{
+					{Name: "config", VolumeSource: v1core.VolumeSource{EmptyDir: &v1core.EmptyDirVolumeSource{}}},
+					{Name: "data", VolumeSource: v1core.VolumeSource{PersistentVolumeClaim: &v1core.PersistentVolumeClaimVolumeSource{ClaimName: "data-" + name}}},
+				},
+			},
+		})
+	}
+
+	// keys of segments shipped by the jobs, the recoverable point is limited by the pod that shipped the least
+	store := memoryObjectStore{
+		fmt.Sprintf("commitlogs/cassandra0-0/%d_CommitLog-7-1.log", now.Add(-2*time.Hour).Unix()):    nil,
+		fmt.Sprintf("commitlogs/cassandra0-0/%d_CommitLog-7-2.log", now.Add(-30*time.Minute).Unix()): nil,
+		fmt.Sprintf("commitlogs/cassandra1-0/%d_CommitLog-7-3.log", now.Add(-time.Hour).Unix()):      nil,
+	}
+	shipper := &backup.CommitlogShipper{Client: kubeClient, Helper: helper, StoreBuilder: &testObjectStoreBuilder{store: store}}
+	interval, err := shipper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, interval)
+
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, cr))
+	status := cr.Status.Backup.PITR
+	assert.Equal(t, 3, status.ShippedSegments)
+	assert.NotNil(t, status.LastShipTime)
+	assert.True(t, now.Add(-time.Hour).Equal(status.LatestRecoverablePoint.Time))
+
+	// segments are shipped by jobs next to Cassandra pods on their data volumes
+	for i, pod := range helper.pods {
+		job := &batchv1.Job{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: nameSpace, Name: "cassandra-commitlog-shipping-" + pod.Name}, job))
+		podSpec := job.Spec.Template.Spec
+		assert.Equal(t, fmt.Sprintf("node-%d", i), podSpec.NodeName)
+		assert.Equal(t, &fsGroup, podSpec.SecurityContext.FSGroup)
+		assert.Equal(t, "data-"+pod.Name, podSpec.Volumes[0].PersistentVolumeClaim.ClaimName)
+		assert.Equal(t, "rclone/rclone:1.68.2", podSpec.Containers[0].Image)
+		assert.Contains(t, podSpec.Containers[0].VolumeMounts, v1core.VolumeMount{Name: "data", MountPath: "/var/lib/cassandra/data"})
+		assert.Contains(t, podSpec.Containers[0].VolumeMounts, v1core.VolumeMount{Name: "store-ssl-certs", MountPath: "/s3Certs/store", ReadOnly: true})
+		assert.Contains(t, podSpec.Containers[0].Env, v1core.EnvVar{Name: "RCLONE_CONFIG_STORE_ENDPOINT", Value: "https://s3.example.com"})
+		assert.Contains(t, podSpec.Containers[0].Env, v1core.EnvVar{Name: "RCLONE_CA_CERT", Value: "/s3Certs/store/ca.crt"})
+		assert.Contains(t, podSpec.Containers[0].Command[2], "mkdir -p '"+archive+"'")
+		assert.Contains(t, podSpec.Containers[0].Command[2], "'store:backups/commitlogs/"+pod.Name+"/'")
+	}
+
+	// the next run is due after the interval, finished jobs are collected then
+	interval, err = shipper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.True(t, interval > 0 && interval <= time.Minute)
+
+	for i, pod := range helper.pods {
+		job := &batchv1.Job{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: nameSpace, Name: "cassandra-commitlog-shipping-" + pod.Name}, job))
+		if i == 0 {
+			job.Status.Succeeded = 1
+		} else {
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1core.ConditionTrue, Message: "BackoffLimitExceeded"}}
+		}
+		assert.NoError(t, kubeClient.Status().Update(context.TODO(), job))
+	}
+	cr.Status.Backup.PITR.LastShipTime = &metav1.Time{Time: now.Add(-2 * time.Minute)}
+	assert.NoError(t, kubeClient.Status().Update(context.TODO(), cr))
+	_, err = shipper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.ErrorContains(t, err, "'cassandra1-0' pod: job cassandra-commitlog-shipping-cassandra1-0 failed: BackoffLimitExceeded")
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, cr))
+	for _, pod := range helper.pods {
+		err := kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: nameSpace, Name: "cassandra-commitlog-shipping-" + pod.Name}, &batchv1.Job{})
+		assert.True(t, errors.IsNotFound(err))
+	}
+
+	daemon := newBackupDaemonStandIn(t, map[string]map[string]interface{}{
+		"full-before": {"id": "full-before", "failed": false, "is_granular": false, "db_list": "full backup", "ts": now.Add(-90 * time.Minute).UnixMilli()},
+		"full-after":  {"id": "full-after", "failed": false, "is_granular": false, "db_list": "full backup", "ts": now.Add(-10 * time.Minute).UnixMilli()},
+		"granular":    {"id": "granular", "failed": false, "is_granular": true, "db_list": []string{"ks1"}, "ts": now.Add(-40 * time.Minute).UnixMilli()},
+	}, backup.JobStatusSuccessful)
+	defer daemon.Close()
+
+	ctx := core.GetExecutionContext(map[string]interface{}{
+		constants.ContextSpec:                  cr,
+		constants.ContextRequest:               request,
+		constants.ContextClient:                kubeClient,
+		constants.ContextKubeClient:            &rest.Config{},
+		constants.ContextLogger:                core.GetLogger(true),
+		utils.KubernetesHelperImpl:             helper,
+		utils.ContextBackupDaemonClientBuilder: &testDaemonClientBuilder{address: daemon.URL},
+	})
+
+	// the properties are kept in the ConfigMap Cassandra pods mount, so they survive pod restarts
+	archiving := "archive_command=/bin/ln %path " + archive + "/%name\n"
+	assert.NoError(t, (&backup.CommitlogArchivingStep{}).Execute(ctx))
+	cm := &v1core.ConfigMap{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: nameSpace, Name: utils.CommitlogArchivingConfig}, cm))
+	assert.Equal(t, archiving, cm.Data[utils.CommitlogArchivingConfigFile])
+
+	step := &backup.PITRRestoreStep{Timeout: 5 * time.Second, PollInterval: 10 * time.Millisecond}
+	run, err := step.Condition(ctx)
+	assert.NoError(t, err)
+	assert.False(t, run)
+
+	// the nearest full backup is restored, segments archived after it are staged by jobs and replayed on restart
+	point := now.Add(-20 * time.Minute)
+	cr.Spec.Backup.PITR.RestorePointInTime = point.Format(time.RFC3339)
+	run, err = step.Condition(ctx)
+	assert.NoError(t, err)
+	assert.True(t, run)
+	assert.NoError(t, step.Execute(ctx))
+
+	restore := cr.Status.Backup.PITR.Restore
+	assert.Equal(t, "full-before", restore.BackupId)
+	assert.Equal(t, backup.BackupResultSuccessful, restore.Result)
+	assert.Len(t, staged, 2)
+	for i, script := range staged {
+		assert.Contains(t, script, fmt.Sprintf("rclone lsf 'store:backups/commitlogs/cassandra%d-0/'", i))
+		assert.Contains(t, script, fmt.Sprintf("-ge %d", now.Add(-90*time.Minute).Unix()))
+		assert.Contains(t, script, "'"+archive+"/restore/'")
+	}
+	assert.Equal(t, []string{"cassandra0-0", "cassandra1-0"}, helper.restarted)
+	for _, properties := range helper.properties {
+		assert.Contains(t, properties, "restore_directories="+archive+"/restore\n")
+		assert.Contains(t, properties, "restore_point_in_time="+point.UTC().Format("2006:01:02 15:04:05")+"\n")
+	}
+	// archiving only is left after the restore together with staged files removed
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: nameSpace, Name: utils.CommitlogArchivingConfig}, cm))
+	assert.Equal(t, archiving, cm.Data[utils.CommitlogArchivingConfigFile])
+	assert.Equal(t, []string{"cassandra0-0: rm -rf '" + archive + "/restore'", "cassandra1-0: rm -rf '" + archive + "/restore'"}, helper.commands)
+
+	run, err = step.Condition(ctx)
+	assert.NoError(t, err)
+	assert.False(t, run)
+
+	// segments are shipped to S3 or the backup daemon PVC, an emptyDir does not keep them
+	cr.Spec.Backup.S3.Enabled = false
+	assert.NoError(t, cr.Validate())
+	cr.Spec.Backup.Storage = &mTypes.StorageRequirements{EmptyDir: true}
+	err = cr.Validate()
+	assert.ErrorContains(t, err, "spec.backupDaemon.s3.enabled")
+}
+
+func TestPointInTimeRecoveryOnBackupVolume(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Backup.PITR = v1.BackupPITR{Enabled: true, ShipInterval: "1m"}
+
+	// without S3 the segments go to the backup daemon PVC, it is created ReadWriteMany
+	assert.Equal(t, v1core.ReadWriteMany, backup.BackupVolumeAccessMode(cr))
+
+	pvc := &v1core.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup-data-0", Namespace: nameSpace, Labels: map[string]string{utils.Name: utils.BackupDaemon}},
+		Spec:       v1core.PersistentVolumeClaimSpec{AccessModes: []v1core.PersistentVolumeAccessMode{v1core.ReadWriteOnce}},
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, pvc).WithStatusSubresource(cr).Build()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+
+	helper := &pitrTestHelper{client: kubeClient}
+	helper.pods = append(helper.pods, v1core.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra0-0", Namespace: nameSpace},
+		Spec: v1core.PodSpec{
+			NodeName:   "node-0",
+			Containers: []v1core.Container{{Name: "cassandra", VolumeMounts: []v1core.VolumeMount{{Name: "data", MountPath: "/var/lib/cassandra/data"}}}},
+			Volumes: []v1core.Volume{
+				{Name: "data", VolumeSource: v1core.VolumeSource{PersistentVolumeClaim: &v1core.PersistentVolumeClaimVolumeSource{ClaimName: "data-cassandra0-0"}}},
+			},
+		},
+	})
+	shipper := &backup.CommitlogShipper{Client: kubeClient, Helper: helper, StoreBuilder: &testObjectStoreBuilder{store: memoryObjectStore{}}}
+
+	// a ReadWriteOnce volume can not be mounted on Cassandra nodes
+	_, err := shipper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.ErrorContains(t, err, "backup daemon PVC backup-data-0 is not ReadWriteMany")
+
+	pvc.Spec.AccessModes = []v1core.PersistentVolumeAccessMode{v1core.ReadWriteMany}
+	assert.NoError(t, kubeClient.Update(context.TODO(), pvc))
+	_, err = shipper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+
+	job := &batchv1.Job{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: nameSpace, Name: "cassandra-commitlog-shipping-cassandra0-0"}, job))
+	podSpec := job.Spec.Template.Spec
+	assert.Equal(t, "node-0", podSpec.NodeName)
+	assert.Contains(t, podSpec.Volumes, v1core.Volume{Name: "store",
+		VolumeSource: v1core.VolumeSource{PersistentVolumeClaim: &v1core.PersistentVolumeClaimVolumeSource{ClaimName: "backup-data-0"}}})
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts, v1core.VolumeMount{Name: "store", MountPath: "/store"})
+	assert.Contains(t, podSpec.Containers[0].Command[2], "'/store/commitlogs/cassandra0-0/'")
+	for _, env := range podSpec.Containers[0].Env {
+		assert.False(t, strings.HasPrefix(env.Name, "RCLONE_CONFIG_STORE_"), env.Name)
+	}
+}
+
+func TestBackupThrottling(t *testing.T) {
+	// Monday
+	now := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	workdays := []string{"Mon", "Tue", "Wed", "Thu", "Fri"}
+
+	tests := []struct {
+		name        string
+		backup      v1.Backup
+		expectError string
+	}{
+		{
+			name: "Limits and windows",
+			backup: v1.Backup{
+				BackupSchedule:          "0 0 * * *",
+				BandwidthLimit:          "50Mi",
+				MaxConcurrentNodesPerDC: 1,
+				BlackoutWindows: []v1.BackupBlackoutWindow{
+					{Days: workdays, Start: "08:00", End: "20:00", TimeZone: "Europe/Berlin"},
+					{Days: []string{"Sat"}, Start: "22:00", End: "02:00"},
+				},
+			},
+		},
+		{
+			name: "Overlapping windows in different time zones",
+			backup: v1.Backup{
+				BlackoutWindows: []v1.BackupBlackoutWindow{
+					{Start: "08:00", End: "12:00", TimeZone: "Europe/Berlin"},
+					{Start: "10:00", End: "11:00"},
+				},
+			},
+			expectError: "overlap",
+		},
+		{
+			name: "Window crossing midnight overlaps the next day window",
+			backup: v1.Backup{
+				BlackoutWindows: []v1.BackupBlackoutWindow{
+					{Days: []string{"Sun"}, Start: "23:00", End: "01:00"},
+					{Days: []string{"Monday"}, Start: "00:30", End: "02:00"},
+				},
+			},
+			expectError: "overlap",
+		},
+		{
+			name: "Schedule always deferred",
+			backup: v1.Backup{
+				BackupSchedule:  "30 9 * * 1-5",
+				BlackoutWindows: []v1.BackupBlackoutWindow{{Days: workdays, Start: "08:00", End: "18:00"}},
+			},
+			expectError: "falls into",
+		},
+		{
+			name:        "Invalid time zone",
+			backup:      v1.Backup{BlackoutWindows: []v1.BackupBlackoutWindow{{Start: "08:00", End: "18:00", TimeZone: "Mars/Olympus"}}},
+			expectError: "time zone",
+		},
+		{
+			name:        "Invalid bandwidth",
+			backup:      v1.Backup{BandwidthLimit: "fast"},
+			expectError: "bandwidthLimit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := backup.NewThrottlingConfig(tt.backup, now)
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(50*1024*1024), config.BandwidthLimitBytesPerSecond)
+			assert.Equal(t, 1, config.MaxConcurrentNodesPerDC)
+			assert.Equal(t, "UTC", config.BlackoutWindows[1].TimeZone)
+		})
+	}
+
+	window, err := utils.ParseBlackoutWindow([]string{"Sat"}, "22:00", "02:00", "UTC")
+	assert.NoError(t, err)
+	assert.True(t, window.Contains(time.Date(2026, time.March, 8, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2026, time.March, 9, 1, 0, 0, 0, time.UTC)))
+}
+
+func TestBackupCatalog(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	now := time.Now()
+	day := 24 * time.Hour
+
+	backups := map[string]map[string]interface{}{
+		"full-held":     {"id": "full-held", "is_granular": false, "db_list": "full backup", "size": "10G", "ts": now.Add(-40 * day).UnixMilli()},
+		"full-old":      {"id": "full-old", "is_granular": false, "db_list": "full backup", "ts": now.Add(-35 * day).UnixMilli()},
+		"full-new":      {"id": "full-new", "is_granular": false, "db_list": "full backup", "ts": now.Add(-time.Hour).UnixMilli()},
+		"granular-both": {"id": "granular-both", "is_granular": true, "db_list": []string{"ks1", "audit"}, "ts": now.Add(-20 * day).UnixMilli()},
+		"granular-ks1":  {"id": "granular-ks1", "is_granular": true, "db_list": []string{"ks1"}, "ts": now.Add(-10 * day).UnixMilli()},
+		"granular-new":  {"id": "granular-new", "is_granular": true, "db_list": []string{"ks1"}, "ts": now.Add(-time.Hour).UnixMilli()},
+	}
+	daemon := newBackupDaemonStandIn(t, backups, backup.JobStatusSuccessful)
+	defer daemon.Close()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Labels = map[string]string{utils.LegalHoldLabelPrefix + "full-held": "true"}
+	cr.Spec.Backup.StatusCollector = v1.BackupStatusCollector{Enabled: true}
+	cr.Spec.Backup.EvictionPolicy = "0/1d,30d/delete"
+	cr.Spec.Backup.GranularEvictionPolicy = "7d/delete"
+	cr.Spec.Backup.Catalog = v1.BackupCatalog{Enabled: true, KeyspaceEvictionPolicies: map[string]string{"audit": "0/1d,1y/delete"}}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(cr, generateSecrets(nameSpace, cr.Spec.Backup.SecretName, "backup", "backup-pass")).
+		WithStatusSubresource(cr).Build()
+	collector := &backup.StatusCollector{Client: kubeClient, ClientBuilder: &testDaemonClientBuilder{address: daemon.URL}}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+	_, err := collector.Collect(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+
+	// the held backup survives the delete rule, the granular backup is kept by the audit keyspace policy
+	assert.NotContains(t, backups, "full-old")
+	assert.NotContains(t, backups, "granular-ks1")
+	assert.Len(t, backups, 4)
+
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, cr))
+	status := cr.Status.Backup
+	assert.Equal(t, 4, status.RetainedBackups)
+	assert.Equal(t, []string{"full-held"}, status.Catalog.LegalHolds)
+	assert.ElementsMatch(t, []string{"full-old", "granular-ks1"}, status.Catalog.LastEvicted)
+	assert.Equal(t, 4, status.Catalog.Entries)
+
+	catalog := &v1core.ConfigMap{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: nameSpace, Name: utils.BackupCatalog}, catalog))
+	assert.Len(t, catalog.Data, 4)
+
+	held := backup.CatalogEntry{}
+	assert.NoError(t, json.Unmarshal([]byte(catalog.Data["full-held"]), &held))
+	assert.True(t, held.LegalHold)
+	assert.Empty(t, held.Expiry)
+	assert.Equal(t, "10G", held.Size)
+	assert.Equal(t, backup.CatalogTypeFull, held.Type)
+
+	granular := backup.CatalogEntry{}
+	assert.NoError(t, json.Unmarshal([]byte(catalog.Data["granular-both"]), &granular))
+	assert.Equal(t, backup.CatalogTypeGranular, granular.Type)
+	assert.Equal(t, []string{"ks1", "audit"}, granular.Keyspaces)
+	expiry, err := time.Parse(time.RFC3339, granular.Expiry)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, now.Add(-20*day).Add(365*day), expiry, time.Second)
+
+	// backups would never be evicted without the collector
+	assert.NoError(t, cr.Validate())
+	cr.Spec.Backup.StatusCollector.Enabled = false
+	assert.ErrorContains(t, cr.Validate(), "spec.backupDaemon.statusCollector.enabled")
+
+	// the daemon keeps every backup, including the held ones
+	cs := GenerateDefaultCassandraWrapper(nil, "Backup daemon with catalog", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Backup.EvictionPolicy = "0/1d,30d/delete"
+	spec.Spec.Backup.Catalog.Enabled = true
+	spec.Spec.Backup.StatusCollector.Enabled = true
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	deployment := &v1app.Deployment{}
+	assert.NoError(t, cs.ctx.Get(constants.ContextClient).(client.Client).Get(context.TODO(),
+		types.NamespacedName{Name: utils.BackupDaemon, Namespace: cs.nameSpace}, deployment))
+	envs := map[string]string{}
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		envs[env.Name] = env.Value
+	}
+	assert.Equal(t, utils.NoEvictionPolicy, envs["EVICTION_POLICY"])
+	assert.Equal(t, utils.NoEvictionPolicy, envs["GRANULAR_EVICTION_POLICY"])
+	rules, err := utils.ParseEvictionPolicy(utils.NoEvictionPolicy)
+	assert.NoError(t, err)
+	var times []time.Time
+	for _, age := range []time.Duration{time.Second, 2 * time.Second, time.Hour, 40 * day, 3 * 365 * day} {
+		times = append(times, now.Truncate(time.Second).Add(-age))
+	}
+	assert.Empty(t, utils.EvictedItems(times, rules, now))
+}
+
+// newS3StandIn emulates path-style S3 object API of a single bucket, requests must be signed with the access key
+func newS3StandIn(t *testing.T, bucket, accessKey string, objects map[string][]byte) *httptest.Server {
+	type content struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	}
+	type listResult struct {
+		XMLName     xml.Name  `xml:"ListBucketResult"`
+		Name        string    `xml:"Name"`
+		KeyCount    int       `xml:"KeyCount"`
+		IsTruncated bool      `xml:"IsTruncated"`
+		Contents    []content `xml:"Contents"`
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential="+accessKey+"/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/"+bucket && r.Method == http.MethodGet {
+			result := listResult{Name: bucket}
+			prefix := r.URL.Query().Get("prefix")
+			for key, data := range objects {
+				if strings.HasPrefix(key, prefix) {
+					result.Contents = append(result.Contents, content{Key: key, Size: len(data)})
+				}
+			}
+			result.KeyCount = len(result.Contents)
+			if err := xml.NewEncoder(w).Encode(result); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/"+bucket+"/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		key := r.URL.Path[len(bucket)+2:]
+		switch r.Method {
+		case http.MethodPut:
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+			}
+			objects[key] = data
+		case http.MethodGet:
+			data, found := objects[key]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestBackupReplication(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	now := time.Now()
+
+	backups := map[string]map[string]interface{}{
+		"backup-1": {"id": "backup-1", "is_granular": false, "db_list": "full backup", "ts": now.Add(-3 * time.Hour).UnixMilli()},
+		"backup-2": {"id": "backup-2", "is_granular": false, "db_list": "full backup", "ts": now.Add(-2 * time.Hour).UnixMilli()},
+		"failed":   {"id": "failed", "failed": true, "is_granular": false, "db_list": "full backup", "ts": now.Add(-time.Hour).UnixMilli()},
+		"running":  {"id": "running", "locked": true, "is_granular": false, "db_list": "full backup", "ts": now.UnixMilli()},
+	}
+	daemon := newBackupDaemonStandIn(t, backups, backup.JobStatusSuccessful)
+	defer daemon.Close()
+
+	// the objects copied by replication jobs
+	replicaObjects := map[string][]byte{"backup-1/manifest.json": []byte("{}"), "backup-2/manifest.json": []byte("{}")}
+	replica := newS3StandIn(t, "dr-backups", "replica-key", replicaObjects)
+	defer replica.Close()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Backup.S3 = v1.S3backup{Enabled: true, EndpointUrl: "https://s3.example.com", BucketName: "backups", SecretName: "s3-primary", SslVerify: true}
+	cr.Spec.Backup.Replication = v1.BackupReplication{
+		Enabled: true,
+		MaxLag:  "1h",
+		S3:      v1.S3backup{Enabled: true, EndpointUrl: replica.URL, BucketName: "dr-backups", SecretName: "s3-replica"},
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(cr,
+			generateSecrets(nameSpace, cr.Spec.Backup.SecretName, "backup", "backup-pass"),
+			generateSecrets(nameSpace, "s3-replica", "replica-key", "replica-secret")).
+		WithStatusSubresource(cr).Build()
+	replicator := &backup.BackupReplicator{
+		Client:        kubeClient,
+		ClientBuilder: &testDaemonClientBuilder{address: daemon.URL},
+		StoreBuilder:  &backup.ObjectStoreBuilderImpl{},
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+	run := func(expectedInterval time.Duration) *v1.BackupStatus {
+		interval, err := replicator.Run(context.TODO(), request, core.GetLogger(true))
+		assert.NoError(t, err)
+		assert.Equal(t, expectedInterval, interval)
+		result := &v1.CassandraSupplService{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+		return result.Status.Backup
+	}
+	states := func(status *v1.BackupStatus) map[string]string {
+		result := map[string]string{}
+		for _, state := range status.Replication.Backups {
+			result[state.Id] = state.State
+		}
+		return result
+	}
+	lagging := func(status *v1.BackupStatus) bool {
+		for _, condition := range status.Conditions {
+			if condition.Type == backup.ReplicationLagging {
+				return condition.Status
+			}
+		}
+		return false
+	}
+	jobKey := client.ObjectKey{Namespace: nameSpace, Name: utils.BackupReplicationJob}
+	finishJob := func(failure string) {
+		job := &batchv1.Job{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), jobKey, job))
+		if failure != "" {
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1core.ConditionTrue, Message: failure}}
+		} else {
+			job.Status.Succeeded = 1
+		}
+		assert.NoError(t, kubeClient.Status().Update(context.TODO(), job))
+	}
+
+	// the oldest backup is copied by the job, the operator only tracks it
+	status := run(time.Minute)
+	assert.Equal(t, map[string]string{"backup-1": backup.ReplicationPending, "backup-2": backup.ReplicationPending}, states(status))
+	assert.True(t, lagging(status))
+	assert.NotEmpty(t, status.Replication.Lag)
+	job := &batchv1.Job{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), jobKey, job))
+	assert.Equal(t, "backup-1", job.Annotations[utils.ReplicatedBackupAnnotation])
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, []string{"sh", "-c", "rclone copy 'source:backups/backup-1' 'replica:dr-backups/backup-1'"}, container.Command)
+	assert.Contains(t, container.Env, v1core.EnvVar{Name: "RCLONE_CONFIG_SOURCE_ENDPOINT", Value: "https://s3.example.com"})
+	assert.Contains(t, container.Env, v1core.EnvVar{Name: "RCLONE_CONFIG_REPLICA_ENDPOINT", Value: replica.URL})
+	assert.Contains(t, container.Env, v1core.EnvVar{Name: "RCLONE_NO_CHECK_CERTIFICATE", Value: "true"})
+	assert.Empty(t, job.Spec.Template.Spec.NodeName)
+
+	// the failed backup is retried after the pending ones
+	finishJob("BackoffLimitExceeded")
+	status = run(time.Minute)
+	assert.Equal(t, map[string]string{"backup-1": backup.ReplicationFailed, "backup-2": backup.ReplicationPending}, states(status))
+	assert.Equal(t, "BackoffLimitExceeded", status.Replication.Backups[0].Message)
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), jobKey, &batchv1.Job{})))
+
+	run(time.Minute)
+	assert.NoError(t, kubeClient.Get(context.TODO(), jobKey, job))
+	assert.Equal(t, "backup-2", job.Annotations[utils.ReplicatedBackupAnnotation])
+	finishJob("")
+	status = run(time.Minute)
+	assert.Equal(t, map[string]string{"backup-1": backup.ReplicationFailed, "backup-2": backup.ReplicationReplicated}, states(status))
+
+	run(time.Minute)
+	assert.NoError(t, kubeClient.Get(context.TODO(), jobKey, job))
+	assert.Equal(t, "backup-1", job.Annotations[utils.ReplicatedBackupAnnotation])
+	finishJob("")
+	status = run(time.Minute)
+	assert.Equal(t, map[string]string{"backup-1": backup.ReplicationReplicated, "backup-2": backup.ReplicationReplicated}, states(status))
+	assert.NotNil(t, status.Replication.Backups[0].Time)
+	assert.False(t, lagging(status))
+	assert.Empty(t, status.Replication.Lag)
+	run(10 * time.Minute)
+
+	// evicted backups are removed from the secondary target
+	delete(backups, "backup-1")
+	status = run(10 * time.Minute)
+	assert.Len(t, status.Replication.Backups, 1)
+	assert.Equal(t, map[string][]byte{"backup-2/manifest.json": []byte("{}")}, replicaObjects)
+
+	// PVCs are mounted by the job on the backup daemon node
+	cr = GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Backup.Replication = v1.BackupReplication{Enabled: true, PVCName: "dr-backups"}
+	pvc := &v1core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "backup-data-0", Namespace: nameSpace, Labels: map[string]string{utils.Name: utils.BackupDaemon}}}
+	pod := &v1core.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cassandra-backup-daemon-1", Namespace: nameSpace, Labels: map[string]string{utils.Name: utils.BackupDaemon}},
+		Spec: v1core.PodSpec{NodeName: "node-1"}}
+	kubeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, pvc, pod, generateSecrets(nameSpace, cr.Spec.Backup.SecretName, "backup", "backup-pass")).
+		WithStatusSubresource(cr).Build()
+	replicator = &backup.BackupReplicator{
+		Client:        kubeClient,
+		ClientBuilder: &testDaemonClientBuilder{address: daemon.URL},
+		StoreBuilder:  &testObjectStoreBuilder{replica: memoryObjectStore{}},
+	}
+	run(time.Minute)
+	assert.NoError(t, kubeClient.Get(context.TODO(), jobKey, job))
+	podSpec := job.Spec.Template.Spec
+	assert.Equal(t, "node-1", podSpec.NodeName)
+	assert.Equal(t, "backup-data-0", podSpec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, "dr-backups", podSpec.Volumes[1].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, []string{"sh", "-c", "rclone copy '/source/backup-2' '/replica/backup-2'"}, podSpec.Containers[0].Command)
+}
+
+func TestBackupVolume(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	standard := "standard"
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Backup.Storage = &mTypes.StorageRequirements{Size: []string{"10Gi"}, StorageClasses: []string{standard}}
+
+	pvc := &v1core.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup-data-0", Namespace: nameSpace, Labels: map[string]string{utils.Name: utils.BackupDaemon}},
+		Spec: v1core.PersistentVolumeClaimSpec{
+			StorageClassName: &standard,
+			AccessModes:      []v1core.PersistentVolumeAccessMode{v1core.ReadWriteOnce},
+			Resources: v1core.VolumeResourceRequirements{
+				Requests: v1core.ResourceList{v1core.ResourceStorage: resource.MustParse("5Gi")},
+			},
+		},
+		Status: v1core.PersistentVolumeClaimStatus{
+			Capacity: v1core.ResourceList{v1core.ResourceStorage: resource.MustParse("5Gi")},
+		},
+	}
+	deployment := &v1app.Deployment{ObjectMeta: metav1.ObjectMeta{Name: utils.BackupDaemon, Namespace: nameSpace}}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, pvc, deployment).WithStatusSubresource(cr).Build()
+	volume := &backup.BackupVolume{
+		Client:  kubeClient,
+		Spec:    cr,
+		Log:     core.GetLogger(true),
+		Timeout: time.Hour,
+	}
+
+	// the size is increased in place
+	active, err := volume.Reconcile(nameSpace)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup-data-0", active.Name)
+	assert.Equal(t, "10Gi", active.Spec.Resources.Requests.Storage().String())
+	assert.Equal(t, &v1.BackupVolumeStatus{PVCName: "backup-data-0", StorageClass: standard, RequestedSize: "10Gi", Capacity: "5Gi"},
+		cr.Status.Backup.Volume)
+
+	// shrinking is reported, not applied
+	cr.Spec.Backup.Storage.Size = []string{"1Gi"}
+	active, err = volume.Reconcile(nameSpace)
+	assert.NoError(t, err)
+	assert.Equal(t, "10Gi", active.Spec.Resources.Requests.Storage().String())
+	assert.Contains(t, cr.Status.Backup.Volume.Message, "can not be shrunk")
+
+	// a new storage class starts the copy job, the daemon keeps the old volume until it completes
+	cr.Spec.Backup.Storage = &mTypes.StorageRequirements{Size: []string{"20Gi"}, StorageClasses: []string{"fast"}}
+	active, err = volume.Reconcile(nameSpace)
+	assert.NoError(t, err)
+	assert.Nil(t, active)
+	assert.Equal(t, backup.BackupResultInProgress, cr.Status.Backup.Volume.Migration.Result)
+	assert.True(t, backup.BackupVolumeMigrationPending(cr))
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(deployment), &v1app.Deployment{})))
+	started := cr.Status.Backup.Volume.Migration.Time
+
+	jobKey := types.NamespacedName{Namespace: nameSpace, Name: utils.BackupVolumeMigrationJob}
+	job := &batchv1.Job{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), jobKey, job))
+	volumes := job.Spec.Template.Spec.Volumes
+	assert.Equal(t, "backup-data-0", volumes[0].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, "backup-data-0-1", volumes[1].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, int64(3600), *job.Spec.ActiveDeadlineSeconds)
+	active, err = backup.ActiveBackupPVC(kubeClient, nameSpace)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup-data-0", active.Name)
+
+	// a running job is only checked, the migration keeps its start time
+	active, err = volume.Reconcile(nameSpace)
+	assert.NoError(t, err)
+	assert.Nil(t, active)
+	assert.Equal(t, started, cr.Status.Backup.Volume.Migration.Time)
+
+	// a failed job is reported and removed, the next reconcile starts the copy again
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1core.ConditionTrue, Message: "deadline exceeded"}}
+	assert.NoError(t, kubeClient.Status().Update(context.TODO(), job))
+	_, err = volume.Reconcile(nameSpace)
+	assert.ErrorContains(t, err, "copy job failed: deadline exceeded")
+	assert.Equal(t, backup.BackupResultFailed, cr.Status.Backup.Volume.Migration.Result)
+	assert.False(t, backup.BackupVolumeMigrationPending(cr))
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), jobKey, &batchv1.Job{})))
+	active, err = volume.Reconcile(nameSpace)
+	assert.NoError(t, err)
+	assert.Nil(t, active)
+	job = &batchv1.Job{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), jobKey, job))
+
+	job.Status.Succeeded = 1
+	assert.NoError(t, kubeClient.Status().Update(context.TODO(), job))
+	active, err = volume.Reconcile(nameSpace)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup-data-0-1", active.Name)
+	assert.Equal(t, "fast", *active.Spec.StorageClassName)
+	assert.Equal(t, "20Gi", active.Spec.Resources.Requests.Storage().String())
+	assert.Equal(t, backup.BackupResultSuccessful, cr.Status.Backup.Volume.Migration.Result)
+	assert.Equal(t, "backup-data-0-1", cr.Status.Backup.Volume.PVCName)
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(job), &batchv1.Job{})))
+
+	// the source PVC is retained but detached from the daemon
+	source := &v1core.PersistentVolumeClaim{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(pvc), source))
+	assert.Empty(t, source.Labels[utils.Name])
+	assert.Equal(t, "backup-data-0-1", source.Annotations[utils.MigratedToAnnotation])
+	active, err = backup.ActiveBackupPVC(kubeClient, nameSpace)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup-data-0-1", active.Name)
+}
+
+type testSchemaReader struct {
+	keyspaces []backup.KeyspaceSchema
+}
+
+func (r *testSchemaReader) ReadSchema(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) ([]backup.KeyspaceSchema, error) {
+	return r.keyspaces, nil
+}
+
+func TestSchemaBackup(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	options := map[string]string{"gc_grace_seconds": "864000"}
+	orders := backup.TableSchema{
+		Name: "orders",
+		Columns: []backup.ColumnSchema{
+			{Name: "total", Type: "decimal", Kind: backup.ColumnRegular},
+			{Name: "ts", Type: "timestamp", Kind: backup.ColumnClustering, ClusteringOrder: "desc"},
+			{Name: "id", Type: "uuid", Kind: backup.ColumnPartitionKey},
+		},
+		Options: options,
+		Indexes: []backup.IndexSchema{{Name: "orders_total", Kind: "COMPOSITES", Options: map[string]string{"target": "total"}}},
+	}
+	previous := []backup.KeyspaceSchema{{
+		Name:        "shop",
+		Replication: map[string]string{"class": "NetworkTopologyStrategy", "dc1": "3"},
+		Tables:      []backup.TableSchema{orders, {Name: "carts", Columns: []backup.ColumnSchema{{Name: "id", Type: "uuid", Kind: backup.ColumnPartitionKey}}}},
+	}}
+	backup.SortSchema(previous)
+	previousData, _ := json.Marshal(previous)
+
+	changedOrders := orders
+	changedOrders.Columns = append([]backup.ColumnSchema{{Name: "currency", Type: "text", Kind: backup.ColumnRegular}}, orders.Columns...)
+	changedOrders.Options = map[string]string{"gc_grace_seconds": "3600"}
+	current := []backup.KeyspaceSchema{
+		{Name: "shop", Replication: map[string]string{"class": "NetworkTopologyStrategy", "dc1": "3"}, Tables: []backup.TableSchema{changedOrders}},
+		{Name: "audit", Replication: map[string]string{"class": "NetworkTopologyStrategy", "dc1": "2"}},
+	}
+	backup.SortSchema(current)
+
+	ddl := backup.RenderSchema(current)
+	assert.Contains(t, ddl, "CREATE KEYSPACE audit WITH replication = {'class': 'NetworkTopologyStrategy', 'dc1': '2'} AND durable_writes = false;")
+	assert.Contains(t, ddl, "CREATE TABLE shop.orders (\n    id uuid,\n    ts timestamp,\n    currency text,\n    total decimal,\n    PRIMARY KEY ((id), ts)\n)"+
+		" WITH CLUSTERING ORDER BY (ts DESC)\n    AND gc_grace_seconds = 3600;")
+	assert.Contains(t, ddl, "CREATE INDEX orders_total ON shop.orders (total);")
+
+	// a user type is created after the types it uses
+	nested := []backup.KeyspaceSchema{{Name: "crm", Types: []backup.TypeSchema{
+		{Name: "account", Fields: []backup.ColumnSchema{{Name: "addresses", Type: "map<text, frozen<address>>"}}},
+		{Name: "address", Fields: []backup.ColumnSchema{{Name: "street", Type: "text"}, {Name: "zip", Type: `frozen<"zip_Code">`}}},
+		{Name: "zip_Code", Fields: []backup.ColumnSchema{{Name: "code", Type: "text"}}},
+	}}}
+	backup.SortSchema(nested)
+	var typeNames []string
+	for _, udt := range nested[0].Types {
+		typeNames = append(typeNames, udt.Name)
+	}
+	assert.Equal(t, []string{"zip_Code", "address", "account"}, typeNames)
+	nestedDDL := backup.RenderSchema(nested)
+	assert.Less(t, strings.Index(nestedDDL, `CREATE TYPE crm."zip_Code"`), strings.Index(nestedDDL, "CREATE TYPE crm.address"))
+	assert.Less(t, strings.Index(nestedDDL, "CREATE TYPE crm.address"), strings.Index(nestedDDL, "CREATE TYPE crm.account"))
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Backup.Schema = v1.BackupSchema{Enabled: true, Interval: "1h", KeepVersions: 1}
+	oldVersion := &v1core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.SchemaBackup + "-20260101-000000",
+			Namespace: nameSpace,
+			Labels:    map[string]string{utils.Name: utils.SchemaBackup, utils.SchemaVersionLabel: "20260101-000000"},
+		},
+		Data: map[string]string{"schema.json": string(previousData)},
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, oldVersion).WithStatusSubresource(cr).Build()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+	recorder := record.NewFakeRecorder(10)
+	reader := &testSchemaReader{keyspaces: current}
+	backuper := &backup.SchemaBackuper{Client: kubeClient, Reader: reader, StoreBuilder: &testObjectStoreBuilder{}, Recorder: recorder}
+
+	after, err := backuper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, after)
+
+	changes := []string{
+		"keyspace audit created",
+		"table shop.carts dropped",
+		"table shop.orders: column currency text added",
+		"table shop.orders: gc_grace_seconds changed from 864000 to 3600",
+	}
+	result := &v1.CassandraSupplService{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	status := result.Status.Backup.Schema
+	assert.Equal(t, backup.BackupResultSuccessful, status.Result)
+	assert.Equal(t, changes, status.Changes)
+	assert.Equal(t, 2, status.Keyspaces)
+	assert.Equal(t, []string{status.LastVersion}, status.Versions)
+	assert.Equal(t, "Normal SchemaChanged Schema version "+status.LastVersion+": "+strings.Join(changes, "; "), <-recorder.Events)
+
+	// the previous version is evicted by keepVersions
+	versions := &v1core.ConfigMapList{}
+	assert.NoError(t, kubeClient.List(context.TODO(), versions, client.MatchingLabels{utils.Name: utils.SchemaBackup}))
+	if assert.Len(t, versions.Items, 1) {
+		assert.Equal(t, ddl, versions.Items[0].Data["schema.cql"])
+		assert.Equal(t, strings.Join(changes, "\n"), versions.Items[0].Data["changes.txt"])
+	}
+
+	// the export is not due yet
+	after, err = backuper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.True(t, after > 0 && after < time.Hour)
+
+	// an unchanged schema does not make a new version
+	result.Status.Backup.Schema.LastExportTime = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+	assert.NoError(t, kubeClient.Status().Update(context.TODO(), result))
+	_, err = backuper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.NoError(t, kubeClient.List(context.TODO(), versions, client.MatchingLabels{utils.Name: utils.SchemaBackup}))
+	assert.Len(t, versions.Items, 1)
+	assert.Empty(t, recorder.Events)
+
+	// versions go to the backup storage
+	store := memoryObjectStore{}
+	cr.Spec.Backup.Schema.Target = backup.SchemaTargetBackupStorage
+	kubeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).WithStatusSubresource(cr).Build()
+	backuper = &backup.SchemaBackuper{Client: kubeClient, Reader: reader, StoreBuilder: &testObjectStoreBuilder{store: store}, Recorder: recorder}
+	_, err = backuper.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	version := result.Status.Backup.Schema.LastVersion
+	assert.Equal(t, "backup-storage:schema/"+version+"/", result.Status.Backup.Schema.Location)
+	assert.Equal(t, []byte(ddl), store["schema/"+version+"/schema.cql"])
+	assert.Equal(t, "Normal SchemaExported Initial schema version "+version+" with 2 keyspaces is stored to backup-storage:schema/"+version+"/", <-recorder.Events)
+}
+
+func TestAWSKeyspaces(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "AWS Keyspaces profile", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.AWSKeyspaces = v1.AWSKeyspaces{Install: true, SecretName: "aws-keyspaces-credentials", Host: "cassandra.eu-west-1.amazonaws.com"}
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.RobotTests.Install = true
+
+	validator := &v1.CassandraSupplServiceValidator{}
+	_, err := validator.ValidateCreate(context.TODO(), spec)
+	assert.NoError(t, err)
+
+	invalid := spec.DeepCopy()
+	invalid.Spec.AWSKeyspaces.Host = ""
+	invalid.Spec.Cassandra.Consistency = "QUORUM"
+	invalid.Spec.Backup.PITR.Enabled = true
+	invalid.Spec.Dbaas.MultiUsers = true
+	_, err = validator.ValidateUpdate(context.TODO(), spec, invalid)
+	assert.True(t, errors.IsInvalid(err))
+	for _, path := range []string{"spec.awsKeyspaces.host", "spec.cassandra.consistency", "spec.backupDaemon.pitr.enabled", "spec.dbaas.multiUsers"} {
+		assert.Contains(t, err.Error(), path)
+	}
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	envs := func(name string) map[string]v1core.EnvVar {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, cs.ctx.Get(constants.ContextClient).(client.Client).Get(context.TODO(),
+			types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		result := map[string]v1core.EnvVar{}
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			result[env.Name] = env
+		}
+		return result
+	}
+
+	dbaasEnvs := envs(utils.DbaasName)
+	assert.Equal(t, "cassandra.eu-west-1.amazonaws.com", dbaasEnvs["CASSANDRA_HOSTNAME"].Value)
+	assert.Equal(t, "9142", dbaasEnvs["CASSANDRA_PORT"].Value)
+	assert.Equal(t, "sigv4", dbaasEnvs["CASSANDRA_AUTH"].Value)
+	assert.Equal(t, "LOCAL_QUORUM", dbaasEnvs["GOCQL_CONSISTENCY"].Value)
+	assert.Equal(t, "aws-keyspaces-credentials", dbaasEnvs["AWS_ACCESS_KEY"].ValueFrom.SecretKeyRef.Name)
+	assert.NotContains(t, dbaasEnvs, "CASSANDRA_USERNAME")
+
+	robotEnvs := envs(utils.Robot)
+	assert.Equal(t, "cassandra.eu-west-1.amazonaws.com", robotEnvs["CASSANDRA_HOST"].Value)
+	assert.Equal(t, utils.AWSKeyspacesExcludedTags, robotEnvs["EXCLUDE_TAGS"].Value)
+	assert.NotContains(t, robotEnvs, "DC_NAME")
+}
+
+func TestDbaasHighAvailability(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "Dbaas with three replicas", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.Replicas = 3
+	spec.Spec.Dbaas.PDB = true
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	deployment := &v1app.Deployment{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, deployment))
+	assert.Equal(t, int32(3), *deployment.Spec.Replicas)
+	podSpec := deployment.Spec.Template.Spec
+	assert.Equal(t, utils.HostnameTopologyKey, podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.TopologyKey)
+	assert.Equal(t, utils.ZoneTopologyKey, podSpec.TopologySpreadConstraints[0].TopologyKey)
+
+	pdb := &policyv1.PodDisruptionBudget{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, pdb))
+	assert.Equal(t, 1, pdb.Spec.MaxUnavailable.IntValue())
+	assert.Nil(t, pdb.Spec.MinAvailable)
+	assert.Equal(t, 0, deployment.Spec.Strategy.RollingUpdate.MaxUnavailable.IntValue())
+
+	pdb.Annotations = map[string]string{"test": "kept"}
+	assert.NoError(t, kubeClient.Update(context.TODO(), pdb))
+
+	// several pods are rolled in place
+	spec.Spec.Dbaas.DockerImage = "dbaas:next"
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	rolled := &v1app.Deployment{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, rolled))
+	// the fake client starts the resource version of a created object from 1
+	assert.NotEqual(t, "1", rolled.ResourceVersion)
+	assert.Equal(t, "dbaas:next", rolled.Spec.Template.Spec.Containers[0].Image)
+	// the budget is kept, there is no window without it
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, pdb))
+	assert.Equal(t, "kept", pdb.Annotations["test"])
+
+	// a single replica gets no budget, it would block node drains
+	spec.Spec.Dbaas.Replicas = 1
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, pdb)))
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, rolled))
+	assert.Equal(t, "1", rolled.ResourceVersion)
+
+	spec.Spec.Dbaas.Replicas = 3
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, pdb))
+
+	spec.Spec.Dbaas.PDB = false
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, pdb)))
+}
+
+func TestDbaasAutoscaling(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "Dbaas autoscaling", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	cpu := int32(70)
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.Autoscaling = v1.DbaasAutoscaling{
+		Enabled:              true,
+		MinReplicas:          2,
+		MaxReplicas:          5,
+		TargetCPUUtilization: &cpu,
+		CustomMetric:         &v1.DbaasCustomMetric{Name: "provisioning_requests", TargetAverageValue: "500m"},
+	}
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	name := types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), name, hpa))
+	assert.Equal(t, int32(2), *hpa.Spec.MinReplicas)
+	assert.Equal(t, int32(5), hpa.Spec.MaxReplicas)
+	assert.Equal(t, utils.DbaasName, hpa.Spec.ScaleTargetRef.Name)
+	assert.Len(t, hpa.Spec.Metrics, 2)
+	assert.Equal(t, "500m", hpa.Spec.Metrics[1].Pods.Target.AverageValue.String())
+
+	// the autoscaler has scaled the adapter up, a redeploy keeps its choice
+	deployment := &v1app.Deployment{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), name, deployment))
+	assert.Equal(t, int32(2), *deployment.Spec.Replicas)
+	*deployment.Spec.Replicas = 4
+	assert.NoError(t, kubeClient.Update(context.TODO(), deployment))
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	assert.NoError(t, kubeClient.Get(context.TODO(), name, deployment))
+	assert.Equal(t, int32(4), *deployment.Spec.Replicas)
+
+	invalid := spec.DeepCopy()
+	invalid.Spec.Dbaas.Autoscaling.MaxReplicas = 1
+	assert.True(t, errors.IsInvalid(invalid.Validate()))
+
+	spec.Spec.Dbaas.Autoscaling.Enabled = false
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), name, hpa)))
+}
+
+func TestHealthProbes(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "HTTP health probes", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.Probes.Startup = v1.ProbeTimings{FailureThreshold: 60}
+	spec.Spec.TLS.Enabled = true
+	spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = "https://dbaas-aggregator.dbaas:8443"
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	for _, name := range []string{utils.DbaasName, utils.BackupDaemon} {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		container := deployment.Spec.Template.Spec.Containers[0]
+		for _, probe := range []*v1core.Probe{container.StartupProbe, container.LivenessProbe, container.ReadinessProbe} {
+			assert.Equal(t, utils.HealthPath, probe.HTTPGet.Path)
+			assert.Equal(t, v1core.URISchemeHTTPS, probe.HTTPGet.Scheme)
+			assert.Equal(t, 8443, probe.HTTPGet.Port.IntValue())
+		}
+	}
+
+	dbaas := &v1app.Deployment{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, dbaas))
+	startup := dbaas.Spec.Template.Spec.Containers[0].StartupProbe
+	assert.Equal(t, int32(60), startup.FailureThreshold)
+	assert.Equal(t, int32(10), startup.PeriodSeconds)
+}
+
+func TestDbaasPhysicalDatabaseLabels(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "Dbaas physical database labels", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.PhysicalDatabaseLabels = map[string]string{"clusterName": "cassandra", "region": "eu-west"}
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	cm := &v1core.ConfigMap{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasPhysicalDatabasesLabels, Namespace: cs.nameSpace}, cm))
+	assert.JSONEq(t, `{"clusterName": "cassandra", "region": "eu-west"}`, cm.Data[utils.DbaasPhysicalDatabasesLabelsKey])
+
+	podHash := func() string {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, deployment))
+		return deployment.Spec.Template.Annotations[utils.DbaasPhysicalDatabasesLabelsHash]
+	}
+	hash := podHash()
+	assert.NotEmpty(t, hash)
+
+	spec.Spec.Dbaas.PhysicalDatabaseLabels["region"] = "eu-central"
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasPhysicalDatabasesLabels, Namespace: cs.nameSpace}, cm))
+	assert.Contains(t, cm.Data[utils.DbaasPhysicalDatabasesLabelsKey], "eu-central")
+	assert.NotEqual(t, hash, podHash())
+
+	invalid := spec.DeepCopy()
+	invalid.Spec.Dbaas.PhysicalDatabaseLabels = map[string]string{"cluster name": "cassandra", "region": "eu west"}
+	err := invalid.Validate()
+	assert.True(t, errors.IsInvalid(err))
+	assert.Contains(t, err.Error(), "spec.dbaas.physicalDatabaseLabels")
+}
+
+type testRoleStore struct {
+	permissions map[string][]string
+	found       bool
+	statements  []string
+}
+
+func (s *testRoleStore) Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (dbaas.RoleStore, error) {
+	return s, nil
+}
+
+func (s *testRoleStore) RolePermissions(role string) (map[string][]string, bool, error) {
+	return s.permissions, s.found, nil
+}
+
+func (s *testRoleStore) Exec(statements ...string) error {
+	s.statements = append(s.statements, statements...)
+	return nil
+}
+
+func TestStreamingRole(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	permissions, err := dbaas.ParseRolePermissions("[ALL]")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ALTER", "AUTHORIZE", "CREATE", "DROP", "MODIFY", "SELECT"}, permissions)
+	_, err = dbaas.ParseRolePermissions("[SELECT, TRUNCATE]")
+	assert.Error(t, err)
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Dbaas.StreamingRole = v1.DbaasStreamingRole{Managed: true, PasswordRotationPeriod: "720h", CheckInterval: "10m"}
+	secret := &v1core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: utils.DbaasAdminRoleCreds, Namespace: nameSpace},
+		Data:       map[string][]byte{utils.Name: []byte("streaming"), utils.Roles: []byte("[SELECT, MODIFY]")},
+	}
+	deployment := &v1app.Deployment{ObjectMeta: metav1.ObjectMeta{Name: utils.DbaasName, Namespace: nameSpace}}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, secret, deployment).WithStatusSubresource(cr).Build()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+	recorder := record.NewFakeRecorder(10)
+	store := &testRoleStore{}
+	manager := &dbaas.StreamingRoleManager{Client: kubeClient, Builder: store, Recorder: recorder}
+	// runs the manager as if the check interval has passed
+	run := func() error {
+		current := &v1.CassandraSupplService{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, current))
+		if current.Status.Dbaas != nil && current.Status.Dbaas.StreamingRole != nil {
+			current.Status.Dbaas.StreamingRole.LastCheckTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			assert.NoError(t, kubeClient.Status().Update(context.TODO(), current))
+		}
+		_, err := manager.Run(context.TODO(), request, core.GetLogger(true))
+		return err
+	}
+
+	// a missing role is created with a generated password
+	after, err := manager.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, after)
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret))
+	password := string(secret.Data[utils.Password])
+	assert.Len(t, password, 24)
+	assert.NotContains(t, secret.Data, utils.PendingPassword)
+	assert.NotEmpty(t, secret.Annotations[utils.PasswordRotatedAnnotation])
+	assert.Equal(t, []string{
+		`CREATE ROLE IF NOT EXISTS "streaming" WITH PASSWORD = '` + password + `' AND LOGIN = true`,
+		`GRANT MODIFY ON ALL KEYSPACES TO "streaming"`,
+		`GRANT SELECT ON ALL KEYSPACES TO "streaming"`,
+	}, store.statements)
+	assert.Equal(t, "Normal StreamingRolePasswordRotated password of DBaaS streaming role streaming was rotated", <-recorder.Events)
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(deployment), deployment))
+	assert.Equal(t, secret.Annotations[utils.PasswordRotatedAnnotation], deployment.Spec.Template.Annotations[utils.PasswordRotatedAnnotation])
+
+	result := &v1.CassandraSupplService{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	status := result.Status.Dbaas.StreamingRole
+	assert.Equal(t, dbaas.ResultSuccessful, status.Result)
+	assert.Equal(t, "streaming", status.Name)
+	assert.Equal(t, []string{"MODIFY", "SELECT"}, status.Permissions)
+	assert.NotNil(t, status.PasswordRotationTime)
+	assert.Empty(t, status.Drift)
+
+	// grants in line with the secret need no changes
+	store.found, store.statements = true, nil
+	store.permissions = map[string][]string{"data": {"MODIFY", "SELECT"}}
+	assert.NoError(t, run())
+	assert.Empty(t, store.statements)
+	assert.Empty(t, recorder.Events)
+
+	// the role is not checked again until the interval passes
+	store.permissions = map[string][]string{"data": {"SELECT"}, "data/shop": {"DROP"}}
+	after, err = manager.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.InDelta(t, 10*time.Minute, after, float64(time.Minute))
+	assert.Empty(t, store.statements)
+
+	// grants changed by hand are reported as drift, only the missing ones are restored
+	assert.NoError(t, run())
+	assert.Equal(t, []string{`GRANT MODIFY ON ALL KEYSPACES TO "streaming"`}, store.statements)
+	drift := []string{"MODIFY on data is missing", "DROP on data/shop is not expected"}
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	assert.Equal(t, drift, result.Status.Dbaas.StreamingRole.Drift)
+	assert.Equal(t, "Warning StreamingRoleDrift grants of DBaaS streaming role streaming were changed outside the operator, missing grants are restored: "+
+		strings.Join(drift, "; "), <-recorder.Events)
+
+	// unexpected grants are revoked on request
+	store.statements = nil
+	result.Spec.Dbaas.StreamingRole.RevokeUnexpectedPermissions = true
+	assert.NoError(t, kubeClient.Update(context.TODO(), result))
+	assert.NoError(t, run())
+	assert.Equal(t, []string{
+		`GRANT MODIFY ON ALL KEYSPACES TO "streaming"`,
+		`REVOKE DROP ON KEYSPACE "shop" FROM "streaming"`,
+	}, store.statements)
+	assert.Equal(t, "Warning StreamingRoleDrift grants of DBaaS streaming role streaming were changed outside the operator, grants are restored: "+
+		strings.Join(drift, "; "), <-recorder.Events)
+
+	// a changed secret is applied without drift
+	store.statements = nil
+	secret.Data[utils.Roles] = []byte("[ALL]")
+	assert.NoError(t, kubeClient.Update(context.TODO(), secret))
+	store.permissions = map[string][]string{"data": {"MODIFY", "SELECT"}}
+	assert.NoError(t, run())
+	assert.Len(t, store.statements, 4)
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	assert.Empty(t, result.Status.Dbaas.StreamingRole.Drift)
+	assert.Empty(t, recorder.Events)
+
+	// the password is rotated once the period has passed
+	store.statements = nil
+	store.permissions = map[string][]string{"data": permissions}
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret))
+	secret.Annotations[utils.PasswordRotatedAnnotation] = time.Now().Add(-721 * time.Hour).UTC().Format(time.RFC3339)
+	assert.NoError(t, kubeClient.Update(context.TODO(), secret))
+	assert.NoError(t, run())
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret))
+	rotated := string(secret.Data[utils.Password])
+	assert.NotEqual(t, password, rotated)
+	assert.Equal(t, []string{`ALTER ROLE "streaming" WITH PASSWORD = '` + rotated + `'`}, store.statements)
+	assert.Equal(t, "Normal StreamingRolePasswordRotated password of DBaaS streaming role streaming was rotated", <-recorder.Events)
+
+	// a rotation interrupted after the password was stored is completed with the stored password
+	store.statements = nil
+	secret.Data[utils.PendingPassword] = []byte("interrupted")
+	assert.NoError(t, kubeClient.Update(context.TODO(), secret))
+	assert.NoError(t, run())
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret))
+	assert.Equal(t, "interrupted", string(secret.Data[utils.Password]))
+	assert.NotContains(t, secret.Data, utils.PendingPassword)
+	assert.Equal(t, []string{`ALTER ROLE "streaming" WITH PASSWORD = 'interrupted'`}, store.statements)
+	assert.Equal(t, "Normal StreamingRolePasswordRotated password of DBaaS streaming role streaming was rotated", <-recorder.Events)
+
+	// a password without a recorded rotation is kept, its rotation period starts now
+	store.statements = nil
+	delete(secret.Annotations, utils.PasswordRotatedAnnotation)
+	assert.NoError(t, kubeClient.Update(context.TODO(), secret))
+	assert.NoError(t, run())
+	assert.NoError(t, kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret))
+	assert.Equal(t, "interrupted", string(secret.Data[utils.Password]))
+	assert.NotEmpty(t, secret.Annotations[utils.PasswordRotatedAnnotation])
+	assert.Empty(t, store.statements)
+	assert.Empty(t, recorder.Events)
+}
+
+func TestDbaasRegistration(t *testing.T) {
+	nameSpace := "cassandra-namespace"
+	registered := map[string]dbaas.PhysicalDatabase{"ind": {AdapterID: "adapter", AdapterAddress: "http://dbaas-cassandra-adapter.cassandra-namespace:8080"}}
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "cluster-dba" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v3/dbaas/cassandra/physical_databases":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"identified": registered})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/v3/dbaas/cassandra/physical_databases/"):
+			identifier := strings.TrimPrefix(r.URL.Path, "/api/v3/dbaas/cassandra/physical_databases/")
+			if _, ok := registered[identifier]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(registered, identifier)
+			deleted = append(deleted, identifier)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	cr := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = nameSpace
+	cr.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = server.URL
+	secret := &v1core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "dbaas-aggregator-credentials", Namespace: nameSpace},
+		Data:       map[string][]byte{utils.Username: []byte("cluster-dba"), utils.Password: []byte("secret")},
+	}
+	deployment := &v1app.Deployment{ObjectMeta: metav1.ObjectMeta{Name: utils.DbaasName, Namespace: nameSpace}}
+	stuck := GenerateDefaultCassandra(nameSpace, nil, nil, nil)
+	stuck.Name, stuck.Namespace = "cassandra-services-stuck", nameSpace
+	stuck.Finalizers = []string{dbaas.RegistrationFinalizer}
+	stuck.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = server.URL
+	stuck.Spec.Dbaas.Aggregator.SecretName = "dbaas-aggregator-wrong-credentials"
+	wrongSecret := &v1core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "dbaas-aggregator-wrong-credentials", Namespace: nameSpace},
+		Data:       map[string][]byte{utils.Username: []byte("cluster-dba"), utils.Password: []byte("wrong")},
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, secret, deployment, stuck, wrongSecret).WithStatusSubresource(cr, stuck).Build()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nameSpace, Name: cr.Name}}
+	recorder := record.NewFakeRecorder(10)
+	manager := &dbaas.RegistrationManager{Client: kubeClient, ClientBuilder: &dbaas.AggregatorClientBuilderImpl{}, Recorder: recorder}
+	// moves the last check out of the check interval
+	expire := func() {
+		current := &v1.CassandraSupplService{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, current))
+		if current.Status.Dbaas != nil && current.Status.Dbaas.Registration != nil {
+			current.Status.Dbaas.Registration.LastCheckTime = &metav1.Time{Time: time.Now().Add(-10 * time.Minute)}
+			assert.NoError(t, kubeClient.Status().Update(context.TODO(), current))
+		}
+	}
+	run := func() *v1.CassandraSupplService {
+		expire()
+		_, err := manager.Run(context.TODO(), request, core.GetLogger(true))
+		assert.NoError(t, err)
+		result := &v1.CassandraSupplService{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+		return result
+	}
+
+	// the registration made by the adapter is recorded
+	result := run()
+	assert.Contains(t, result.Finalizers, dbaas.RegistrationFinalizer)
+	status := result.Status.Dbaas.Registration
+	assert.Equal(t, "ind", status.PhysicalDatabaseIdentifier)
+	assert.True(t, status.Registered)
+	assert.Equal(t, "http://dbaas-cassandra-adapter.cassandra-namespace:8080", status.AdapterAddress)
+	assert.Equal(t, dbaas.ResultSuccessful, status.Result)
+
+	// the aggregator is not asked again until the check is due
+	delete(registered, "ind")
+	after, err := manager.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.InDelta(t, 5*time.Minute, after, float64(time.Minute))
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	assert.True(t, result.Status.Dbaas.Registration.Registered)
+	registered["ind"] = dbaas.PhysicalDatabase{AdapterID: "adapter", AdapterAddress: "http://dbaas-cassandra-adapter.cassandra-namespace:8080"}
+
+	// a changed identifier deregisters the previous physical database
+	result.Spec.Dbaas.Aggregator.PhysicalDatabaseIdentifier = "cassandra-2"
+	assert.NoError(t, kubeClient.Update(context.TODO(), result))
+	result = run()
+	assert.Equal(t, []string{"ind"}, deleted)
+	assert.Equal(t, "Normal PhysicalDatabaseDeregistered physical database ind is deregistered from DBaaS aggregator", <-recorder.Events)
+	status = result.Status.Dbaas.Registration
+	assert.Equal(t, "cassandra-2", status.PhysicalDatabaseIdentifier)
+	assert.False(t, status.Registered)
+
+	// a lost registration is reported
+	registered["cassandra-2"] = dbaas.PhysicalDatabase{AdapterAddress: "http://adapter"}
+	assert.True(t, run().Status.Dbaas.Registration.Registered)
+	delete(registered, "cassandra-2")
+	assert.False(t, run().Status.Dbaas.Registration.Registered)
+	assert.Equal(t, "Warning PhysicalDatabaseNotRegistered physical database cassandra-2 is no longer registered in DBaaS aggregator", <-recorder.Events)
+
+	// an unreachable aggregator keeps the last known state
+	registered["cassandra-2"] = dbaas.PhysicalDatabase{AdapterAddress: "http://adapter"}
+	assert.True(t, run().Status.Dbaas.Registration.Registered)
+	secret.Data[utils.Password] = []byte("wrong")
+	assert.NoError(t, kubeClient.Update(context.TODO(), secret))
+	expire()
+	_, err = manager.Run(context.TODO(), request, core.GetLogger(true))
+	assert.Error(t, err)
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, result))
+	status = result.Status.Dbaas.Registration
+	assert.True(t, status.Registered)
+	assert.Equal(t, dbaas.ResultFailed, status.Result)
+	assert.Contains(t, status.Message, "returned 401")
+	secret.Data[utils.Password] = []byte("secret")
+	assert.NoError(t, kubeClient.Update(context.TODO(), secret))
+
+	// uninstalled dbaas stops the adapter and deregisters the physical database
+	result.Spec.Dbaas.Install = false
+	assert.NoError(t, kubeClient.Update(context.TODO(), result))
+	result = run()
+	assert.Equal(t, []string{"ind", "cassandra-2"}, deleted)
+	assert.Equal(t, "Normal PhysicalDatabaseDeregistered physical database cassandra-2 is deregistered from DBaaS aggregator", <-recorder.Events)
+	assert.NotContains(t, result.Finalizers, dbaas.RegistrationFinalizer)
+	assert.Nil(t, result.Status.Dbaas.Registration)
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), client.ObjectKeyFromObject(deployment), &v1app.Deployment{})))
+	run()
+	assert.Empty(t, recorder.Events)
+
+	// a deleted custom resource is released once the physical database is deregistered
+	result.Spec.Dbaas.Install = true
+	assert.NoError(t, kubeClient.Update(context.TODO(), result))
+	registered["cassandra-2"] = dbaas.PhysicalDatabase{AdapterAddress: "http://adapter"}
+	run()
+	deleting, err := manager.Finalize(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.False(t, deleting)
+	assert.NoError(t, kubeClient.Delete(context.TODO(), result))
+	deleting, err = manager.Finalize(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.True(t, deleting)
+	assert.Equal(t, []string{"ind", "cassandra-2", "cassandra-2"}, deleted)
+	assert.Equal(t, "Normal PhysicalDatabaseDeregistered physical database cassandra-2 is deregistered from DBaaS aggregator", <-recorder.Events)
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), request.NamespacedName, result)))
+
+	// a failing deregistration holds the custom resource until it is skipped by the annotation
+	assert.NoError(t, kubeClient.Delete(context.TODO(), stuck))
+	stuckRequest := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(stuck)}
+	deleting, err = manager.Finalize(context.TODO(), stuckRequest, core.GetLogger(true))
+	assert.True(t, deleting)
+	assert.ErrorContains(t, err, "returned 401")
+	assert.NoError(t, kubeClient.Get(context.TODO(), stuckRequest.NamespacedName, stuck))
+	stuck.Annotations = map[string]string{dbaas.SkipDeregistrationAnnotation: "true"}
+	assert.NoError(t, kubeClient.Update(context.TODO(), stuck))
+	deleting, err = manager.Finalize(context.TODO(), stuckRequest, core.GetLogger(true))
+	assert.True(t, deleting)
+	assert.NoError(t, err)
+	assert.Equal(t, "Warning PhysicalDatabaseNotDeregistered physical database ind is left in DBaaS aggregator, deregistration is skipped by netcracker.com/skip-dbaas-deregistration annotation", <-recorder.Events)
+	assert.True(t, errors.IsNotFound(kubeClient.Get(context.TODO(), stuckRequest.NamespacedName, stuck)))
+}
+
+// newTestCertificate returns a self-signed client certificate and its key in PEM
+func newTestCertificate(t *testing.T, commonName string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestDbaasTLS(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "Dbaas server TLS with plain aggregator", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	server := true
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.TLS = v1.DbaasAdapterTLS{DbaasAdapterCASecretName: "dbaas-adapter-certificate", Server: &server}
+	spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = "http://dbaas-aggregator.dbaas:8080"
+	spec.Spec.TLS = v1.TLS{RootCASecretName: "root-ca", RootCAFileName: "ca.crt", SignedCRTFileName: "tls.crt", PrivateKeyFileName: "tls.key"}
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	adapter := func() (v1core.PodSpec, map[string]string) {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, deployment))
+		envs := map[string]string{}
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			envs[env.Name] = env.Value
+		}
+		return deployment.Spec.Template.Spec, envs
+	}
+	podSpec, envs := adapter()
+	assert.Equal(t, "8443", envs["PORT"])
+	assert.Equal(t, "https://dbaas-cassandra-adapter."+cs.nameSpace+":8443", envs["DBAAS_ADAPTER_ADDRESS"])
+	assert.Equal(t, "/certs/tls.crt", envs["INTERNAL_TLS_CERTIFICATE_FILENAME"])
+	assert.NotContains(t, envs, "DBAAS_AGGREGATOR_TLS_ENABLED")
+	assert.Equal(t, v1core.URISchemeHTTPS, podSpec.Containers[0].ReadinessProbe.HTTPGet.Scheme)
+	service := &v1core.Service{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: cs.nameSpace}, service))
+	assert.Equal(t, int32(8443), service.Spec.Ports[0].Port)
+
+	// plain adapter API with mutual TLS toward the aggregator
+	server = false
+	spec.Spec.Dbaas.TLS.ClientCertSecretName = "dbaas-aggregator-client-certificate"
+	spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = "https://dbaas-aggregator.dbaas:8443"
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+	podSpec, envs = adapter()
+	assert.Equal(t, "8080", envs["PORT"])
+	assert.NotContains(t, envs, "INTERNAL_TLS_ENABLED")
+	assert.Equal(t, "true", envs["DBAAS_AGGREGATOR_TLS_ENABLED"])
+	assert.Equal(t, utils.AggregatorCertsPath+"ca.crt", envs["DBAAS_AGGREGATOR_TLS_ROOTCERT"])
+	assert.Equal(t, utils.AggregatorCertsPath+"tls.key", envs["DBAAS_AGGREGATOR_TLS_KEY_FILENAME"])
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == utils.AggregatorCerts {
+			assert.Equal(t, "root-ca", volume.Projected.Sources[0].Secret.Name)
+			assert.Equal(t, "dbaas-aggregator-client-certificate", volume.Projected.Sources[1].Secret.Name)
+		}
+	}
+
+	// inconsistent settings are rejected
+	clientTLS := true
+	spec.Spec.Dbaas.TLS = v1.DbaasAdapterTLS{Server: &server, Client: &clientTLS}
+	spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = "http://dbaas-aggregator.dbaas:8080"
+	err := spec.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "spec.dbaas.tls.client")
+	spec.Spec.Dbaas.TLS = v1.DbaasAdapterTLS{ClientCertSecretName: "dbaas-aggregator-client-certificate"}
+	assert.Contains(t, spec.Validate().Error(), "spec.dbaas.tls.clientCertSecretName")
+
+	// the operator presents the client certificate to the aggregator
+	clientCert, clientKey := newTestCertificate(t, "cassandra-services-operator")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCert)
+	aggregator := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"identified": {"ind": {"adapterAddress": "http://adapter"}}}`))
+	}))
+	aggregator.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	aggregator.StartTLS()
+	defer aggregator.Close()
+
+	cr := GenerateDefaultCassandra(cs.nameSpace, nil, nil, nil)
+	cr.Spec.TLS = spec.Spec.TLS
+	cr.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = aggregator.URL
+	cr.Spec.Dbaas.TLS = v1.DbaasAdapterTLS{ClientCertSecretName: "dbaas-aggregator-client-certificate"}
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: aggregator.Certificate().Raw})
+	secrets := fake.NewClientBuilder().WithObjects(
+		&v1core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "dbaas-aggregator-credentials", Namespace: cs.nameSpace}},
+		&v1core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "root-ca", Namespace: cs.nameSpace}, Data: map[string][]byte{"ca.crt": serverCA}},
+		&v1core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "dbaas-aggregator-client-certificate", Namespace: cs.nameSpace},
+			Data: map[string][]byte{"tls.crt": clientCert, "tls.key": clientKey}},
+	).Build()
+	aggregatorClient, err := (&dbaas.AggregatorClientBuilderImpl{}).Build(secrets, cr, cs.nameSpace)
+	assert.NoError(t, err)
+	_, found, err := aggregatorClient.PhysicalDatabase("ind")
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	// without the client certificate the handshake fails
+	cr.Spec.Dbaas.TLS.ClientCertSecretName = ""
+	aggregatorClient, err = (&dbaas.AggregatorClientBuilderImpl{}).Build(secrets, cr, cs.nameSpace)
+	assert.NoError(t, err)
+	_, _, err = aggregatorClient.PhysicalDatabase("ind")
+	assert.Error(t, err)
+}
+
+func TestCertificateIssuer(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "cert-manager certificates", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.TLS.DbaasAdapterCASecretName = "dbaas-adapter-certificate"
+	spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = "https://dbaas-aggregator.dbaas:8443"
+	spec.Spec.Backup.TLS.BackupDaemonCASecretName = "backup-daemon-certificate"
+	spec.Spec.TLS = v1.TLS{Enabled: true, Issuer: &v1.TLSIssuer{Name: "cassandra-tls-issuer", Kind: "ClusterIssuer", Duration: "2160h"}}
+
+	// cert-manager has issued the certificates
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	for _, name := range []string{"dbaas-adapter-certificate", "backup-daemon-certificate"} {
+		assert.NoError(t, kubeClient.Create(context.TODO(), &v1core.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cs.nameSpace},
+			Data:       map[string][]byte{v1core.TLSCertKey: []byte(name + " v1")},
+		}))
+	}
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(utils.CertificateGVK)
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName + "-tls", Namespace: cs.nameSpace}, certificate))
+	dnsNames, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "dnsNames")
+	assert.Contains(t, dnsNames, "dbaas-cassandra-adapter.cassandra-namespace.svc")
+	secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+	assert.Equal(t, "dbaas-adapter-certificate", secretName)
+	issuer, _, _ := unstructured.NestedStringMap(certificate.Object, "spec", "issuerRef")
+	assert.Equal(t, map[string]string{"name": "cassandra-tls-issuer", "kind": "ClusterIssuer", "group": "cert-manager.io"}, issuer)
+	duration, _, _ := unstructured.NestedString(certificate.Object, "spec", "duration")
+	assert.Equal(t, "2160h", duration)
+	assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.BackupDaemon + "-tls", Namespace: cs.nameSpace}, certificate))
+
+	hash := func(name string) string {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		return deployment.Spec.Template.Annotations[utils.CertificateHashAnnotation]
+	}
+	dbaasHash, backupHash := hash(utils.DbaasName), hash(utils.BackupDaemon)
+	assert.NotEmpty(t, dbaasHash)
+	assert.NotEmpty(t, backupHash)
+
+	// a renewed certificate restarts its component only
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	cr := spec.DeepCopy()
+	cr.Name, cr.Namespace, cr.ResourceVersion = "cassandra-services", cs.nameSpace, ""
+	renewed := &v1core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "dbaas-adapter-certificate", Namespace: cs.nameSpace},
+		Data:       map[string][]byte{v1core.TLSCertKey: []byte("dbaas-adapter-certificate v2")},
+	}
+	current := &v1core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup-daemon-certificate", Namespace: cs.nameSpace},
+		Data:       map[string][]byte{v1core.TLSCertKey: []byte("backup-daemon-certificate v1")},
+	}
+	deployments := []client.Object{}
+	for _, name := range []string{utils.DbaasName, utils.BackupDaemon} {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		deployment.ResourceVersion = ""
+		deployments = append(deployments, deployment)
+	}
+	kubeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(deployments, cr, renewed, current)...).WithStatusSubresource(cr).Build()
+	recorder := record.NewFakeRecorder(10)
+	roller := &common.CertificateRoller{Client: kubeClient, Recorder: recorder}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cs.nameSpace, Name: cr.Name}}
+	after, err := roller.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, after)
+	assert.NotEqual(t, dbaasHash, hash(utils.DbaasName))
+	assert.Equal(t, backupHash, hash(utils.BackupDaemon))
+	assert.Equal(t, "Normal CertificateRenewed TLS secrets of dbaas-cassandra-adapter changed, pods are restarted", <-recorder.Events)
+	_, err = roller.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+
+	spec.Spec.TLS.Issuer = &v1.TLSIssuer{RenewBefore: "30d"}
+	err = spec.Validate()
+	assert.Contains(t, err.Error(), "spec.tls.issuer.name")
+	assert.Contains(t, err.Error(), "spec.tls.issuer.renewBefore")
+}
+
+func TestSelfSignedCertificates(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "self-signed certificates", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.TLS.DbaasAdapterCASecretName = "dbaas-adapter-certificate"
+	spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress = "https://dbaas-aggregator.dbaas:8443"
+	spec.Spec.Backup.TLS.BackupDaemonCASecretName = "backup-daemon-certificate"
+	spec.Spec.TLS = v1.TLS{Enabled: true, RootCASecretName: "root-ca", RootCAFileName: "ca.crt", SignedCRTFileName: "tls.crt",
+		PrivateKeyFileName: "tls.key", SelfSigned: &v1.TLSSelfSigned{Duration: "48h", RenewBefore: "24h"}}
+	assert.NoError(t, spec.Validate())
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	secret := func(name string) *v1core.Secret {
+		secret := &v1core.Secret{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, secret))
+		return secret
+	}
+	caSecret := secret("root-ca")
+	assert.NotEmpty(t, caSecret.Data[utils.CAKeyFileName])
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(caSecret.Data["ca.crt"]))
+	verify := func(secretName, dnsName string) *x509.Certificate {
+		certificate, err := utils.ParseCertificate(secret(secretName).Data[v1core.TLSCertKey])
+		assert.NoError(t, err)
+		_, err = certificate.Verify(x509.VerifyOptions{Roots: roots, DNSName: dnsName})
+		assert.NoError(t, err)
+		return certificate
+	}
+	verify("dbaas-adapter-certificate", "dbaas-cassandra-adapter.cassandra-namespace.svc")
+	backupCertificate := verify("backup-daemon-certificate", "cassandra-backup-daemon")
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), backupCertificate.NotAfter, 10*time.Minute)
+	assert.Equal(t, caSecret.Data["ca.crt"], secret("backup-daemon-certificate").Data["ca.crt"])
+
+	hash := func(name string) string {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		return deployment.Spec.Template.Annotations[utils.CertificateHashAnnotation]
+	}
+	dbaasHash, backupHash := hash(utils.DbaasName), hash(utils.BackupDaemon)
+	assert.NotEmpty(t, dbaasHash)
+
+	// a certificate within the renewal window is reissued and its component restarted
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	cr := spec.DeepCopy()
+	cr.Name, cr.Namespace, cr.ResourceVersion = "cassandra-services", cs.nameSpace, ""
+	expiring := secret("dbaas-adapter-certificate")
+	expiring.ResourceVersion = ""
+	expiring.Data[v1core.TLSCertKey], expiring.Data[v1core.TLSPrivateKeyKey], _ = utils.GenerateServerCertificate(caSecret.Data["ca.crt"],
+		caSecret.Data[utils.CAKeyFileName], utils.DbaasName, utils.ServiceDNSNames(utils.DbaasName, cs.nameSpace), 12*time.Hour)
+	objects := []client.Object{cr, expiring}
+	for _, name := range []string{"root-ca", "backup-daemon-certificate"} {
+		current := secret(name)
+		current.ResourceVersion = ""
+		objects = append(objects, current)
+	}
+	for _, name := range []string{utils.DbaasName, utils.BackupDaemon} {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		deployment.ResourceVersion = ""
+		objects = append(objects, deployment)
+	}
+	kubeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithStatusSubresource(cr).Build()
+	recorder := record.NewFakeRecorder(10)
+	roller := &common.CertificateRoller{Client: kubeClient, Recorder: recorder}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cs.nameSpace, Name: cr.Name}}
+	_, err := roller.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	renewed := verify("dbaas-adapter-certificate", "dbaas-cassandra-adapter")
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), renewed.NotAfter, 10*time.Minute)
+	assert.NotEqual(t, dbaasHash, hash(utils.DbaasName))
+	assert.Equal(t, backupHash, hash(utils.BackupDaemon))
+	assert.Equal(t, "Normal CertificateRenewed TLS secrets of dbaas-cassandra-adapter changed, pods are restarted", <-recorder.Events)
+
+	// a CA provided by hand is never overwritten
+	foreign := &v1core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "root-ca", Namespace: cs.nameSpace}, Data: map[string][]byte{"ca.crt": []byte("external")}}
+	kubeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, foreign).Build()
+	_, err = common.EnsureSelfSignedCertificates(context.TODO(), kubeClient, cr, cs.nameSpace)
+	assert.ErrorContains(t, err, "not managed by the operator")
+
+	spec.Spec.TLS.Issuer = &v1.TLSIssuer{Name: "cassandra-tls-issuer"}
+	spec.Spec.TLS.SelfSigned.RenewBefore = "72h"
+	spec.Spec.TLS.SignedCRTFileName = "cassandra.crt"
+	err = spec.Validate()
+	assert.Contains(t, err.Error(), "spec.tls.selfSigned: Forbidden")
+	assert.Contains(t, err.Error(), "spec.tls.selfSigned.renewBefore")
+	assert.Contains(t, err.Error(), "spec.tls.signedCRTFileName")
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	namespace := "cassandra-namespace"
+	cr := GenerateDefaultCassandra(namespace, nil, nil, nil)
+	cr.Name = "cassandra-services"
+	cr.Namespace = namespace
+	cr.Spec.Dbaas.Install = false
+	cr.Spec.RobotTests.Install = false
+	cr.Spec.Backup.Install = true
+	cr.Spec.Backup.TLS.BackupDaemonCASecretName = "backup-daemon-certificate"
+	cr.Spec.TLS = v1.TLS{Enabled: true, RootCASecretName: "root-ca", RootCAFileName: "ca.crt", SignedCRTFileName: "tls.crt",
+		ExpiryWarningThresholds: []string{"168h", "24h"}}
+	tlsSecret := func(name, key string, validity time.Duration) *v1core.Secret {
+		certificate, privateKey, err := utils.GenerateCA(name, validity)
+		assert.NoError(t, err)
+		return &v1core.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string][]byte{key: certificate, "tls.key": privateKey},
+		}
+	}
+	deployment := &v1app.Deployment{ObjectMeta: metav1.ObjectMeta{Name: utils.BackupDaemon, Namespace: namespace}}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(cr, deployment, tlsSecret("root-ca", "ca.crt", 8760*time.Hour), tlsSecret("backup-daemon-certificate", "tls.crt", 100*time.Hour)).
+		WithStatusSubresource(cr).Build()
+	recorder := record.NewFakeRecorder(10)
+	roller := &common.CertificateRoller{Client: kubeClient, Recorder: recorder}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: cr.Name}}
+
+	after, err := roller.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, after)
+	assert.Equal(t, "Normal CertificateRenewed TLS secrets of cassandra-backup-daemon changed, pods are restarted", <-recorder.Events)
+	assert.Contains(t, <-recorder.Events, "Warning CertificateExpiring certificate backup-daemon-certificate expires at")
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, cr))
+	assert.Len(t, cr.Status.TLS.Certificates, 2)
+	certificate := cr.Status.TLS.Certificates[0]
+	assert.Equal(t, "backup-daemon-certificate", certificate.SecretName)
+	assert.Equal(t, []string{utils.BackupDaemon}, certificate.Deployments)
+	assert.Equal(t, "CN=backup-daemon-certificate", certificate.Subject)
+	assert.Equal(t, "168h0m0s", certificate.WarnedThreshold)
+	assert.WithinDuration(t, time.Now().Add(100*time.Hour), certificate.NotAfter.Time, 10*time.Minute)
+	assert.Empty(t, cr.Status.TLS.Certificates[1].WarnedThreshold)
+
+	families, err := crmetrics.Registry.Gather()
+	assert.NoError(t, err)
+	var exported []string
+	for _, family := range families {
+		if family.GetName() != "cassandra_services_tls_certificate_not_after_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "secret" {
+					exported = append(exported, label.GetValue())
+				}
+			}
+			assert.InDelta(t, float64(time.Now().Unix()), metric.GetGauge().GetValue(), float64(8760*time.Hour/time.Second)+600)
+		}
+	}
+	assert.ElementsMatch(t, []string{"root-ca", "backup-daemon-certificate"}, exported)
+
+	// the warning is raised once per threshold, the certificates are not checked again until the interval passes
+	checked := cr.ResourceVersion
+	after, err = roller.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+	assert.InDelta(t, 5*time.Minute, after, float64(time.Minute))
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, cr))
+	assert.Equal(t, checked, cr.ResourceVersion)
+
+	cr.Status.TLS.LastCheckTime = &metav1.Time{Time: time.Now().Add(-10 * time.Minute)}
+	assert.NoError(t, kubeClient.Status().Update(context.TODO(), cr))
+	after, err = roller.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+	assert.Equal(t, 5*time.Minute, after)
+	assert.NoError(t, kubeClient.Get(context.TODO(), request.NamespacedName, cr))
+	assert.WithinDuration(t, time.Now(), cr.Status.TLS.LastCheckTime.Time, time.Minute)
+
+	// a replaced secret restarts its consumers, an expired certificate raises one more warning
+	assert.NoError(t, kubeClient.Update(context.TODO(), tlsSecret("backup-daemon-certificate", "tls.crt", -time.Hour)))
+	_, err = roller.Run(context.TODO(), request, core.GetLogger(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "Normal CertificateRenewed TLS secrets of cassandra-backup-daemon changed, pods are restarted", <-recorder.Events)
+	assert.Contains(t, <-recorder.Events, "Warning CertificateExpired certificate backup-daemon-certificate expired at")
+
+	cr.Spec.TLS.ExpiryWarningThresholds = []string{"30d"}
+	err = cr.Validate()
+	assert.Contains(t, err.Error(), "spec.tls.expiryWarningThresholds[0]")
+}
+
+func TestTLSOptional(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "optional TLS", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	server := true
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.Dbaas.TLS = v1.DbaasAdapterTLS{DbaasAdapterCASecretName: "dbaas-adapter-certificate", Server: &server}
+	spec.Spec.Backup.TLS.BackupDaemonCASecretName = "backup-daemon-certificate"
+	spec.Spec.TLS = v1.TLS{Enabled: true, Optional: true, RootCASecretName: "root-ca", RootCAFileName: "ca.crt", SignedCRTFileName: "tls.crt", PrivateKeyFileName: "tls.key"}
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	for _, name := range []string{utils.DbaasName, utils.BackupDaemon} {
+		service := &v1core.Service{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, service))
+		ports := map[string]int32{}
+		for _, port := range service.Spec.Ports {
+			ports[port.Name] = port.Port
+		}
+		assert.Equal(t, map[string]int32{"http": 8443, utils.PlainHTTPPortName: 8080}, ports, name)
+
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		container := deployment.Spec.Template.Spec.Containers[0]
+		assert.Contains(t, container.Ports, v1core.ContainerPort{Name: utils.PlainHTTPPortName, ContainerPort: 8080, Protocol: v1core.ProtocolTCP}, name)
+		assert.Contains(t, container.Env, v1core.EnvVar{Name: "INTERNAL_TLS_OPTIONAL", Value: "true"}, name)
+		assert.Contains(t, container.Env, v1core.EnvVar{Name: "INTERNAL_PLAIN_HTTP_PORT", Value: "8080"}, name)
+		assert.Contains(t, container.Env, v1core.EnvVar{Name: "INTERNAL_TLS_ENABLED", Value: "true"}, name)
+		assert.Equal(t, v1core.URISchemeHTTPS, container.ReadinessProbe.HTTPGet.Scheme, name)
+	}
+
+	assert.Equal(t, map[string]int32{"http": 8443}, utils.HTTPServicePorts(true, v1.TLS{Enabled: true}))
+	assert.Equal(t, map[string]int32{"http": 8080}, utils.HTTPServicePorts(false, v1.TLS{Optional: true}))
+}
+
+func TestKeystores(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "PKCS12 keystores", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Backup.TLS.BackupDaemonCASecretName = "backup-daemon-certificate"
+	spec.Spec.Backup.MountKeystores = true
+	spec.Spec.RobotTests.Install = true
+	spec.Spec.RobotTests.MountKeystores = true
+	spec.Spec.TLS = v1.TLS{Enabled: true, RootCASecretName: "root-ca", RootCAFileName: "ca.crt", SignedCRTFileName: "tls.crt",
+		PrivateKeyFileName: "tls.key", KeystorePass: "changeit", SelfSigned: &v1.TLSSelfSigned{}}
+	assert.NoError(t, spec.Validate())
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	secret := func(name string) *v1core.Secret {
+		secret := &v1core.Secret{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, secret))
+		return secret
+	}
+	ca, err := utils.ParseCertificate(secret("root-ca").Data["ca.crt"])
+	assert.NoError(t, err)
+	keystores := secret("cassandra-tls-keystores")
+	assert.Equal(t, "changeit", string(keystores.Data[utils.KeystorePassKey]))
+	trusted, err := pkcs12.DecodeTrustStore(keystores.Data[utils.TruststoreFileName], "changeit")
+	assert.NoError(t, err)
+	assert.Len(t, trusted, 1)
+	assert.True(t, trusted[0].Equal(ca))
+	_, certificate, chain, err := pkcs12.DecodeChain(keystores.Data[utils.BackupDaemon+".p12"], "changeit")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.BackupDaemon, certificate.Subject.CommonName)
+	assert.Len(t, chain, 1)
+	assert.True(t, chain[0].Equal(ca))
+	// the self-signed CA secret holds no client certificate for the robot tests
+	assert.NotContains(t, keystores.Data, "keystore.p12")
+
+	env := func(name string) map[string]string {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].VolumeMounts,
+			v1core.VolumeMount{Name: "tls-keystores", ReadOnly: true, MountPath: utils.KeystoresPath}, name)
+		values := map[string]string{}
+		for _, envVar := range deployment.Spec.Template.Spec.Containers[0].Env {
+			values[envVar.Name] = envVar.Value
+			if envVar.ValueFrom != nil && envVar.ValueFrom.SecretKeyRef != nil {
+				values[envVar.Name] = envVar.ValueFrom.SecretKeyRef.Name + "/" + envVar.ValueFrom.SecretKeyRef.Key
+			}
+		}
+		return values
+	}
+	backupEnv := env(utils.BackupDaemon)
+	assert.Equal(t, "/keystores/cassandra-backup-daemon.p12", backupEnv["TLS_KEYSTORE"])
+	assert.Equal(t, "/keystores/truststore.p12", backupEnv["TLS_TRUSTSTORE"])
+	assert.Equal(t, "cassandra-tls-keystores/keystore-pass", backupEnv["TLS_KEYSTORE_PASSWORD"])
+	robotEnv := env(utils.Robot)
+	assert.NotContains(t, robotEnv, "TLS_KEYSTORE")
+	assert.Equal(t, "PKCS12", robotEnv["TLS_KEYSTORE_TYPE"])
+
+	// the stores are rebuilt only when the sources or the password change
+	written, err := common.EnsureKeystores(context.TODO(), kubeClient, spec, cs.nameSpace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	spec.Spec.TLS.KeystorePass = "rotated"
+	written, err = common.EnsureKeystores(context.TODO(), kubeClient, spec, cs.nameSpace)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	_, err = pkcs12.DecodeTrustStore(secret("cassandra-tls-keystores").Data[utils.TruststoreFileName], "rotated")
+	assert.NoError(t, err)
+
+	// the secret is removed once no component mounts it
+	spec.Spec.Backup.MountKeystores = false
+	spec.Spec.RobotTests.MountKeystores = false
+	written, err = common.EnsureKeystores(context.TODO(), kubeClient, spec, cs.nameSpace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	err = kubeClient.Get(context.TODO(), types.NamespacedName{Name: "cassandra-tls-keystores", Namespace: cs.nameSpace}, &v1core.Secret{})
+	assert.True(t, errors.IsNotFound(err))
+
+	spec.Spec.RobotTests.MountKeystores = true
+	spec.Spec.TLS.Enabled = false
+	spec.Spec.TLS.KeystorePass = ""
+	err = spec.Validate()
+	assert.Contains(t, err.Error(), "spec.robotTests.mountKeystores: Forbidden")
+	assert.Contains(t, err.Error(), "spec.tls.keystorePass: Required")
+}
+
+// clientCertClusterBuilder records the client certificate passed to the mocked cluster builder
+type clientCertClusterBuilder struct {
+	*cqlMocks.ClusterBuilder
+	certificate []byte
+	key         []byte
+}
+
+func (b *clientCertClusterBuilder) WithClientCertificate(certPEM, keyPEM []byte) cql.ClusterBuilder {
+	b.certificate, b.key = certPEM, keyPEM
+	return b.ClusterBuilder
+}
+
+func TestCassandraClientCertificate(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "Cassandra mutual TLS without password", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.Dbaas.Install = true
+	spec.Spec.RobotTests.Install = true
+	spec.Spec.Backup.TLS.BackupDaemonCASecretName = "backup-daemon-certificate"
+	spec.Spec.Cassandra.SecretName = ""
+	spec.Spec.Cassandra.ClientCertSecretName = "cassandra-client-certificate"
+	spec.Spec.Cassandra.PasswordlessAuth = true
+	spec.Spec.TLS = v1.TLS{Enabled: true, RootCASecretName: "root-ca", RootCAFileName: "ca.crt", SignedCRTFileName: "tls.crt", PrivateKeyFileName: "tls.key"}
+	assert.NoError(t, spec.Validate())
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	assert.NoError(t, kubeClient.Create(context.TODO(), &v1core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandra-client-certificate", Namespace: cs.nameSpace},
+		Data:       map[string][]byte{"tls.crt": []byte("client certificate"), "tls.key": []byte("client key")},
+	}))
+	mockBuilder := cs.ctxToReplaceAfterServiceBuilt[utils.ContextClusterBuilder].(*cqlMocks.ClusterBuilder)
+	builder := &clientCertClusterBuilder{ClusterBuilder: mockBuilder}
+	cs.ctxToReplaceAfterServiceBuilt[utils.ContextClusterBuilder] = builder
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	// the operator presents the certificate and sends no password
+	assert.Equal(t, "client certificate", string(builder.certificate))
+	assert.Equal(t, "client key", string(builder.key))
+	mockBuilder.AssertCalled(t, "WithUser", "")
+
+	for _, name := range []string{utils.DbaasName, utils.BackupDaemon, utils.Robot} {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		container := deployment.Spec.Template.Spec.Containers[0]
+		assert.Contains(t, container.VolumeMounts, v1core.VolumeMount{Name: utils.CassandraClientCerts, ReadOnly: true, MountPath: "/cassandra-client-certs/"}, name)
+		envs := map[string]string{}
+		for _, env := range container.Env {
+			envs[env.Name] = env.Value
+		}
+		assert.Equal(t, "/cassandra-client-certs/tls.crt", envs["TLS_CLIENT_CERTIFICATE_FILENAME"], name)
+		assert.Equal(t, "/cassandra-client-certs/tls.key", envs["TLS_CLIENT_KEY_FILENAME"], name)
+		assert.Equal(t, "certificate", envs["CASSANDRA_AUTH"], name)
+		assert.NotContains(t, envs, "CASSANDRA_PASSWORD", name)
+		assert.NotEmpty(t, deployment.Spec.Template.Annotations[utils.CertificateHashAnnotation], name)
+	}
+
+	// a broken certificate fails the session instead of connecting without it
+	cluster := (&utils.CassandraClusterBuilder{}).WithClientCertificate([]byte("client certificate"), []byte("client key")).
+		WithHost("cassandra").WithTLSEnabled(true).Build()
+	_, err := cluster.CreateSession()
+	assert.ErrorContains(t, err, "invalid Cassandra client certificate")
+	assert.IsType(t, &cql.ClusterImpl{}, (&utils.CassandraClusterBuilder{}).WithUser("admin").WithPassword(func() string { return "admin" }).Build())
+
+	spec.Spec.TLS.Enabled = false
+	spec.Spec.TLS.PrivateKeyFileName = ""
+	err = spec.Validate()
+	assert.Contains(t, err.Error(), "spec.cassandra.clientCertSecretName: Forbidden")
+	assert.Contains(t, err.Error(), "spec.tls.privateKeyFileName: Required")
+
+	spec.Spec.Cassandra.ClientCertSecretName = ""
+	assert.ErrorContains(t, spec.Validate(), "spec.cassandra.clientCertSecretName: Required")
+	// a password is sent along with the certificate unless passwordless authentication is requested
+	spec.Spec.Cassandra.ClientCertSecretName = "cassandra-client-certificate"
+	spec.Spec.Cassandra.PasswordlessAuth = false
+	spec.Spec.TLS.Enabled = true
+	assert.False(t, utils.CassandraPasswordless(spec.Spec))
+}
+
+func TestSecurityProfile(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "restricted security profile", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.SecurityProfile = v1.RestrictedSecurityProfile
+	spec.Spec.RobotTests.Install = true
+	var robotUID int64 = 2000
+	spec.Spec.RobotTests.SecurityContext = &v1core.PodSecurityContext{RunAsUser: &robotUID}
+	assert.NoError(t, spec.Validate())
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	podSpec := func(name string) v1core.PodSpec {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cs.nameSpace}, deployment))
+		return deployment.Spec.Template.Spec
+	}
+	for _, name := range []string{utils.BackupDaemon, utils.Robot} {
+		pod := podSpec(name)
+		assert.Equal(t, v1core.SeccompProfileTypeRuntimeDefault, pod.SecurityContext.SeccompProfile.Type, name)
+		assert.True(t, *pod.SecurityContext.RunAsNonRoot, name)
+		container := pod.Containers[0]
+		assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem, name)
+		assert.False(t, *container.SecurityContext.AllowPrivilegeEscalation, name)
+		assert.Contains(t, container.VolumeMounts, v1core.VolumeMount{Name: "tmp", MountPath: "/tmp"}, name)
+		assert.Contains(t, pod.Volumes, v1core.Volume{Name: "tmp", VolumeSource: v1core.VolumeSource{EmptyDir: &v1core.EmptyDirVolumeSource{}}}, name)
+	}
+	// the component override wins over the shared security context and the profile defaults
+	assert.Equal(t, robotUID, *podSpec(utils.Robot).SecurityContext.RunAsUser)
+	assert.Equal(t, utils.RestrictedUID, *podSpec(utils.BackupDaemon).SecurityContext.RunAsUser)
+
+	spec.Spec.SecurityProfile = "baseline"
+	assert.Error(t, spec.Validate())
+	spec.Spec.SecurityProfile = v1.RestrictedSecurityProfile
+	escalation := true
+	spec.Spec.Backup.ContainerSecurityContext = &v1core.SecurityContext{AllowPrivilegeEscalation: &escalation}
+	err := spec.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "spec.backupDaemon.containerSecurityContext.allowPrivilegeEscalation")
+
+	// capabilities an override drops are completed with ALL
+	spec.Spec.Backup.ContainerSecurityContext = &v1core.SecurityContext{Capabilities: &v1core.Capabilities{Drop: []v1core.Capability{"NET_RAW"}}}
+	assert.NoError(t, spec.Validate())
+	containerContext := utils.ContainerSecurityContext(spec.Spec, utils.BackupDaemon)
+	assert.Equal(t, []v1core.Capability{"NET_RAW", "ALL"}, containerContext.Capabilities.Drop)
+	assert.Equal(t, []v1core.Capability{"NET_RAW"}, spec.Spec.Backup.ContainerSecurityContext.Capabilities.Drop)
+}
+
+func TestComponentServiceAccounts(t *testing.T) {
+	cs := GenerateDefaultCassandraWrapper(nil, "component service accounts", 3, 1)
+	spec := cs.ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	spec.Spec.ServiceAccountName = ""
+	spec.Spec.RobotTests.Install = true
+
+	cs.executor.SetExecutable(cs.builder.Build(cs.ctx))
+	for key, elem := range cs.ctxToReplaceAfterServiceBuilt {
+		cs.ctx.Set(key, elem)
+	}
+	assert.NoError(t, cs.executor.Execute(cs.ctx))
+
+	kubeClient := cs.ctx.Get(constants.ContextClient).(client.Client)
+	key := func(name string) types.NamespacedName {
+		return types.NamespacedName{Name: name, Namespace: cs.nameSpace}
+	}
+	for _, name := range []string{utils.BackupDaemon, utils.Robot} {
+		deployment := &v1app.Deployment{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), key(name), deployment))
+		assert.Equal(t, name, deployment.Spec.Template.Spec.ServiceAccountName)
+		assert.NoError(t, kubeClient.Get(context.TODO(), key(name), &v1core.ServiceAccount{}))
+	}
+	// the backup daemon does not call the Kubernetes API
+	err := kubeClient.Get(context.TODO(), key(utils.BackupDaemon), &rbacv1.Role{})
+	assert.True(t, errors.IsNotFound(err))
+
+	role := &rbacv1.Role{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), key(utils.Robot), role))
+	assert.Contains(t, role.Rules, rbacv1.PolicyRule{
+		APIGroups:     []string{"apps"},
+		Resources:     []string{"deployments/status"},
+		ResourceNames: []string{utils.Robot},
+		Verbs:         []string{"get", "patch", "update"},
+	})
+	binding := &rbacv1.RoleBinding{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), key(utils.Robot), binding))
+	assert.Equal(t, utils.Robot, binding.RoleRef.Name)
+	assert.Equal(t, []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: utils.Robot, Namespace: cs.nameSpace}}, binding.Subjects)
+
+	// the shared account overrides the generated ones, which are removed
+	spec.Spec.ServiceAccountName = "cassandra-operator"
+	assert.NoError(t, common.EnsureServiceAccounts(context.TODO(), kubeClient, spec, cs.nameSpace))
+	for _, obj := range []client.Object{&v1core.ServiceAccount{}, &rbacv1.Role{}, &rbacv1.RoleBinding{}} {
+		err = kubeClient.Get(context.TODO(), key(utils.Robot), obj)
+		assert.True(t, errors.IsNotFound(err))
+	}
+	assert.Equal(t, "cassandra-operator", utils.ServiceAccountName(spec.Spec, utils.Robot))
+
+	spec.Spec.ServiceAccountName = ""
+	assert.NoError(t, common.EnsureServiceAccounts(context.TODO(), kubeClient, spec, cs.nameSpace))
+	account := &v1core.ServiceAccount{}
+	assert.NoError(t, kubeClient.Get(context.TODO(), key(utils.BackupDaemon), account))
+	assert.True(t, metav1.IsControlledBy(account, spec))
+
+	// the shared account is kept even if the operator created it, objects of others are never removed
+	assert.NoError(t, kubeClient.Create(context.TODO(), &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: utils.DbaasName, Namespace: cs.nameSpace}}))
+	spec.Spec.ServiceAccountName = utils.BackupDaemon
+	assert.NoError(t, common.EnsureServiceAccounts(context.TODO(), kubeClient, spec, cs.nameSpace))
+	assert.NoError(t, kubeClient.Get(context.TODO(), key(utils.BackupDaemon), &v1core.ServiceAccount{}))
+	assert.NoError(t, kubeClient.Get(context.TODO(), key(utils.DbaasName), &rbacv1.Role{}))
+	err = kubeClient.Get(context.TODO(), key(utils.Robot), &v1core.ServiceAccount{})
+	assert.True(t, errors.IsNotFound(err))
+
+	// an account of someone else is not taken over
+	spec.Spec.ServiceAccountName = ""
+	spec.Spec.Dbaas.Install = true
+	assert.NoError(t, kubeClient.Create(context.TODO(), &v1core.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: utils.DbaasName, Namespace: cs.nameSpace}}))
+	assert.ErrorContains(t, common.EnsureServiceAccounts(context.TODO(), kubeClient, spec, cs.nameSpace), "is not created by the operator")
+}
@@ -2,6 +2,7 @@ package backup
 
 import (
 	"fmt"
+	"time"
 
 	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
@@ -59,6 +60,7 @@ func (r *BackupBuilder) Build(ctx core.ExecutionContext) core.Executable {
 	pvcContext := fmt.Sprintf(utils.BackupPvcName, 0)
 	nodesContext := fmt.Sprintf(utils.PVNodesFormat, 0)
 
+	var daemon core.ExecutableCompound = &backup
 	if !spec.Spec.Backup.Storage.EmptyDir {
 		pvcStep := &steps.CreatePVCStep{
 			Storage:           storage,
@@ -71,23 +73,35 @@ func (r *BackupBuilder) Build(ctx core.ExecutionContext) core.Executable {
 			WaitTimeout:  spec.Spec.WaitTimeout,
 			Owner:        nil,
 			WaitPVCBound: spec.Spec.Backup.Storage.WaitPVCBound,
+			AccessMode:   BackupVolumeAccessMode(spec),
 		}
 
 		if spec.Spec.DeletePVConUninstall {
 			pvcStep.Owner = spec
 		}
 
-		backup.AddStep(pvcStep)
-		backup.AddStep(&steps.StoreNodesStep{
+		backup.AddStep(&BackupVolumeStep{
+			PVCStep: pvcStep,
+			Timeout: time.Hour,
+		})
+		// the daemon is deployed by the reconcile that finds the volume migrated
+		daemon = &BackupVolumeGate{}
+		backup.AddStep(daemon)
+		daemon.AddStep(pvcStep)
+		daemon.AddStep(&steps.StoreNodesStep{
 			Storage:           storage,
 			ContextVarToStore: nodesContext,
 		})
 	}
 
-	backup.AddStep(&BackupService{})
+	daemon.AddStep(&BackupService{})
+
+	if spec.Spec.Backup.Snapshots.Enabled {
+		daemon.AddStep(&SnapshotRestoreStep{})
+	}
 
 	if spec.Spec.VaultRegistration.Enabled {
-		backup.AddStep(&steps.MoveSecretToVault{
+		daemon.AddStep(&steps.MoveSecretToVault{
 			SecretName:        spec.Spec.Backup.SecretName,
 			PolicyName:        utils.Backup,
 			Policy:            fmt.Sprintf("length = 10\nrule \"charset\" {\n  charset = \"%s\"\n}\n", utils.Charset),
@@ -96,10 +110,19 @@ func (r *BackupBuilder) Build(ctx core.ExecutionContext) core.Executable {
 	}
 
 	if !spec.Spec.AWSKeyspaces.Install {
-		backup.AddStep(&BackupSSHKeyStep{})
+		daemon.AddStep(&BackupSSHKeyStep{})
+		daemon.AddStep(&BackupThrottlingStep{})
 	}
 
-	backup.AddStep(&LegacyBackupDeployment{})
+	daemon.AddStep(&LegacyBackupDeployment{})
+
+	if spec.Spec.Backup.PITR.Enabled && !spec.Spec.AWSKeyspaces.Install {
+		daemon.AddStep(&CommitlogArchivingStep{})
+		daemon.AddStep(&PITRRestoreStep{
+			Timeout:      time.Hour,
+			PollInterval: 10 * time.Second,
+		})
+	}
 
 	return &backup
 }
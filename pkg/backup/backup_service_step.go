@@ -32,7 +32,7 @@ func (r *BackupService) Execute(ctx core.ExecutionContext) error {
 		map[string]string{
 			utils.Name: utils.BackupDaemon,
 		},
-		map[string]int32{"http": utils.GetHTTPPort(spec.Spec.TLS.Enabled)},
+		utils.HTTPServicePorts(spec.Spec.TLS.Enabled, spec.Spec.TLS),
 		request.Namespace)
 
 	// Kubernetes api causes "invalid resourceVersion error" on update. So remove it.
@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// backup catalog entry types
+const (
+	CatalogTypeFull     = "full"
+	CatalogTypeGranular = "granular"
+	CatalogTypeSnapshot = "snapshot"
+)
+
+// CatalogEntry is a value of the backup catalog ConfigMap, keyed by the backup id
+type CatalogEntry struct {
+	Id        string   `json:"id"`
+	Type      string   `json:"type"`
+	Keyspaces []string `json:"keyspaces,omitempty"`
+	Time      string   `json:"time"`
+	Result    string   `json:"result"`
+	Size      string   `json:"size,omitempty"`
+	Location  string   `json:"location"`
+	// the time the backup is deleted by the eviction policy at the latest, empty if it is retained forever
+	Expiry    string `json:"expiry,omitempty"`
+	LegalHold bool   `json:"legalHold,omitempty"`
+}
+
+// LegalHolds returns backup ids and snapshot groups put under legal hold with CR labels
+func LegalHolds(spec *v1.CassandraSupplService) map[string]bool {
+	holds := map[string]bool{}
+	for label, value := range spec.Labels {
+		if strings.HasPrefix(label, utils.LegalHoldLabelPrefix) && value != "false" {
+			holds[strings.TrimPrefix(label, utils.LegalHoldLabelPrefix)] = true
+		}
+	}
+	return holds
+}
+
+// evictionPolicies holds parsed policies of full backups and granular backups per keyspace
+type evictionPolicies struct {
+	full      []utils.EvictionRule
+	granular  []utils.EvictionRule
+	keyspaces map[string][]utils.EvictionRule
+}
+
+func parseEvictionPolicies(backup v1.Backup) (*evictionPolicies, error) {
+	policies := &evictionPolicies{keyspaces: map[string][]utils.EvictionRule{}}
+	var err error
+	if policies.full, err = utils.ParseEvictionPolicy(backup.EvictionPolicy); err != nil {
+		return nil, fmt.Errorf("invalid backupDaemon.evictionPolicy: %w", err)
+	}
+	if policies.granular, err = utils.ParseEvictionPolicy(backup.GranularEvictionPolicy); err != nil {
+		return nil, fmt.Errorf("invalid backupDaemon.granularEvictionPolicy: %w", err)
+	}
+	for keyspace, policy := range backup.Catalog.KeyspaceEvictionPolicies {
+		if policies.keyspaces[keyspace], err = utils.ParseEvictionPolicy(policy); err != nil {
+			return nil, fmt.Errorf("invalid backupDaemon.catalog.keyspaceEvictionPolicies.%s: %w", keyspace, err)
+		}
+	}
+	return policies, nil
+}
+
+func (p *evictionPolicies) forKeyspace(keyspace string) []utils.EvictionRule {
+	if rules, found := p.keyspaces[keyspace]; found {
+		return rules
+	}
+	return p.granular
+}
+
+// expiry is the age at which the policy deletes any item, nil if the policy has no `delete` rule
+func expiry(rules []utils.EvictionRule, t time.Time) *time.Time {
+	for _, rule := range rules {
+		if rule.Delete {
+			expiry := t.Add(rule.Start)
+			return &expiry
+		}
+	}
+	return nil
+}
+
+func (p *evictionPolicies) expiry(backup *DaemonBackup) *time.Time {
+	if !backup.IsGranular {
+		return expiry(p.full, backup.Time())
+	}
+	keyspaces := backup.Keyspaces()
+	if len(keyspaces) == 0 {
+		return expiry(p.granular, backup.Time())
+	}
+	// the backup is retained while any of its keyspaces needs it
+	var latest *time.Time
+	for _, keyspace := range keyspaces {
+		keyspaceExpiry := expiry(p.forKeyspace(keyspace), backup.Time())
+		if keyspaceExpiry == nil {
+			return nil
+		}
+		if latest == nil || keyspaceExpiry.After(*latest) {
+			latest = keyspaceExpiry
+		}
+	}
+	return latest
+}
+
+// evictedBackups returns backups to evict, granular backups are evicted only when policies of all their keyspaces evict them
+func (p *evictionPolicies) evictedBackups(backups []*DaemonBackup, holds map[string]bool, now time.Time) []*DaemonBackup {
+	var full []*DaemonBackup
+	byKeyspace := map[string][]*DaemonBackup{}
+	for _, backup := range backups {
+		if backup.Locked {
+			continue
+		}
+		if !backup.IsGranular {
+			full = append(full, backup)
+			continue
+		}
+		keyspaces := backup.Keyspaces()
+		if len(keyspaces) == 0 {
+			keyspaces = []string{""}
+		}
+		for _, keyspace := range keyspaces {
+			byKeyspace[keyspace] = append(byKeyspace[keyspace], backup)
+		}
+	}
+
+	evictedFor := map[*DaemonBackup]int{}
+	evict := func(candidates []*DaemonBackup, rules []utils.EvictionRule) {
+		times := make([]time.Time, len(candidates))
+		for i, backup := range candidates {
+			times[i] = backup.Time()
+		}
+		for _, index := range utils.EvictedItems(times, rules, now) {
+			evictedFor[candidates[index]]++
+		}
+	}
+	evict(full, p.full)
+	for keyspace, candidates := range byKeyspace {
+		evict(candidates, p.forKeyspace(keyspace))
+	}
+
+	var evicted []*DaemonBackup
+	for _, backup := range backups {
+		count, found := evictedFor[backup]
+		if !found || holds[backup.Id] {
+			continue
+		}
+		if !backup.IsGranular || count == max(len(backup.Keyspaces()), 1) {
+			evicted = append(evicted, backup)
+		}
+	}
+	return evicted
+}
+
+func backupLocation(backup v1.Backup, id string) string {
+	if backup.S3.Enabled {
+		return fmt.Sprintf("s3://%s/%s", backup.S3.BucketName, id)
+	}
+	return fmt.Sprintf("%s:%s/%s", utils.BackupDaemon, backup.StorageDirectory, id)
+}
+
+// syncCatalog evicts backups by the operator policies and stores retained backups and snapshot groups in the catalog ConfigMap.
+// It returns the retained backups.
+func (r *StatusCollector) syncCatalog(ctx context.Context, spec *v1.CassandraSupplService, daemonClient DaemonClient,
+	backups []*DaemonBackup, status *v1.BackupStatus, now time.Time, log *zap.Logger) ([]*DaemonBackup, error) {
+	policies, err := parseEvictionPolicies(spec.Spec.Backup)
+	if err != nil {
+		return nil, err
+	}
+	holds := LegalHolds(spec)
+
+	var lastEvicted []string
+	evicted := map[string]bool{}
+	for _, backup := range policies.evictedBackups(backups, holds, now) {
+		log.Info(fmt.Sprintf("Evicting backup %s", backup.Id))
+		if err := daemonClient.Evict(backup.Id); err != nil {
+			return nil, fmt.Errorf("failed to evict backup %s: %w", backup.Id, err)
+		}
+		evicted[backup.Id] = true
+		lastEvicted = append(lastEvicted, backup.Id)
+	}
+
+	var retained []*DaemonBackup
+	entries := map[string]CatalogEntry{}
+	for _, backup := range backups {
+		if evicted[backup.Id] {
+			continue
+		}
+		retained = append(retained, backup)
+		entry := CatalogEntry{
+			Id:        backup.Id,
+			Type:      CatalogTypeFull,
+			Time:      backup.Time().Format(time.RFC3339),
+			Result:    backupResult(backup),
+			Size:      backup.Size,
+			Location:  backupLocation(spec.Spec.Backup, backup.Id),
+			LegalHold: holds[backup.Id],
+		}
+		if backup.IsGranular {
+			entry.Type = CatalogTypeGranular
+			entry.Keyspaces = backup.Keyspaces()
+		}
+		if backupExpiry := policies.expiry(backup); backupExpiry != nil && !entry.LegalHold {
+			entry.Expiry = backupExpiry.Format(time.RFC3339)
+		}
+		entries[backup.Id] = entry
+	}
+
+	if spec.Spec.Backup.Snapshots.Enabled {
+		groups, err := ListSnapshotGroups(r.Client, spec.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshot groups: %w", err)
+		}
+		for _, group := range groups {
+			entry := CatalogEntry{
+				Id:        group.Name,
+				Type:      CatalogTypeSnapshot,
+				Time:      group.Time.Format(time.RFC3339),
+				Result:    BackupResultSuccessful,
+				Location:  fmt.Sprintf("volumesnapshots:%s=%s", utils.SnapshotGroupLabel, group.Name),
+				LegalHold: holds[group.Name],
+			}
+			if groupExpiry := expiry(policies.full, group.Time); groupExpiry != nil && !entry.LegalHold {
+				entry.Expiry = groupExpiry.Format(time.RFC3339)
+			}
+			entries[CatalogTypeSnapshot+"-"+group.Name] = entry
+		}
+	}
+
+	if err := r.writeCatalog(ctx, spec, entries); err != nil {
+		return nil, fmt.Errorf("failed to update backup catalog: %w", err)
+	}
+
+	var heldIds []string
+	for id := range holds {
+		heldIds = append(heldIds, id)
+	}
+	sort.Strings(heldIds)
+	status.Catalog = &v1.BackupCatalogStatus{
+		ConfigMapName: utils.BackupCatalog,
+		Entries:       len(entries),
+		LegalHolds:    heldIds,
+		LastEvicted:   lastEvicted,
+	}
+	return retained, nil
+}
+
+func (r *StatusCollector) writeCatalog(ctx context.Context, spec *v1.CassandraSupplService, entries map[string]CatalogEntry) error {
+	data := map[string]string{}
+	for key, entry := range entries {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data[key] = string(value)
+	}
+
+	cm := &v12.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: spec.Namespace, Name: utils.BackupCatalog}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		cm.Data = data
+		return controllerutil.SetControllerReference(spec, cm, r.Client.Scheme())
+	})
+	return err
+}
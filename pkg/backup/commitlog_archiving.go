@@ -0,0 +1,482 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultCommitlogArchiveDirectory = "/var/lib/cassandra/data/commitlog_archive"
+	defaultCommitlogShipInterval     = 5 * time.Minute
+	commitlogStorePrefix             = "commitlogs"
+	commitlogRestoreDirectory        = "restore"
+	// the rclone remote of the backup S3 storage or the mount of the backup daemon PVC
+	commitlogStoreRemote = "store"
+	// Cassandra restore_point_in_time format, GMT
+	commitlogRestoreTimeFormat = "2006:01:02 15:04:05"
+)
+
+type archivedSegment struct {
+	Name string
+	Time time.Time
+}
+
+func commitlogArchiveDirectory(pitr v1.BackupPITR) string {
+	return core.OptionalString(pitr.ArchiveDirectory, defaultCommitlogArchiveDirectory)
+}
+
+// commitlogArchivingProperties renders `commitlog_archiving.properties`.
+// If the restore point is set, segments from the restore directory are replayed on the next Cassandra start.
+func commitlogArchivingProperties(pitr v1.BackupPITR, restorePoint *time.Time) string {
+	archiveDir := commitlogArchiveDirectory(pitr)
+	properties := []string{fmt.Sprintf("archive_command=/bin/ln %%path %s/%%name", archiveDir)}
+	if restorePoint != nil {
+		properties = append(properties,
+			"restore_command=cp -f %from %to",
+			fmt.Sprintf("restore_directories=%s/%s", archiveDir, commitlogRestoreDirectory),
+			fmt.Sprintf("restore_point_in_time=%s", restorePoint.UTC().Format(commitlogRestoreTimeFormat)),
+		)
+	}
+	return strings.Join(properties, "\n") + "\n"
+}
+
+// configureCommitlogArchiving keeps the properties in the ConfigMap mounted to Cassandra pods, so that they survive pod restarts
+func configureCommitlogArchiving(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string, restorePoint *time.Time) error {
+	cm := &v12.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: utils.CommitlogArchivingConfig}}
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), kubeClient, cm, func() error {
+		cm.Data = map[string]string{utils.CommitlogArchivingConfigFile: commitlogArchivingProperties(spec.Spec.Backup.PITR, restorePoint)}
+		return controllerutil.SetControllerReference(spec, cm, kubeClient.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s: %w", utils.CommitlogArchivingConfig, err)
+	}
+	return nil
+}
+
+// parseSegmentKey parses `commitlogs/<pod>/<archiving time>_<segment>` keys, the archiving time is encoded
+// into the key by the shipping job to avoid reading the segment metadata from the store
+func parseSegmentKey(key string) (podName string, segment archivedSegment, ok bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != commitlogStorePrefix {
+		return "", segment, false
+	}
+	timeAndName := strings.SplitN(parts[2], "_", 2)
+	if len(timeAndName) != 2 {
+		return "", segment, false
+	}
+	seconds, err := strconv.ParseInt(timeAndName[0], 10, 64)
+	if err != nil {
+		return "", segment, false
+	}
+	return parts[1], archivedSegment{Name: timeAndName[1], Time: time.Unix(seconds, 0)}, true
+}
+
+func listCassandraPods(helper core.KubernetesHelper, namespace string) ([]v12.Pod, error) {
+	pods, err := helper.ListPods(namespace, map[string]string{utils.Service: utils.CassandraCluster})
+	if err != nil {
+		return nil, err
+	}
+	if pods == nil || len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no Cassandra pods found")
+	}
+	return pods.Items, nil
+}
+
+// commitlogStore returns the backup storage the segments are shipped to. Without S3 the jobs on Cassandra nodes
+// mount the backup daemon PVC, so it must be ReadWriteMany.
+func commitlogStore(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (transferRemote, error) {
+	store := transferRemote{Name: commitlogStoreRemote, S3: spec.Spec.Backup.S3}
+	if store.S3.Enabled {
+		return store, nil
+	}
+	pvc, err := ActiveBackupPVC(kubeClient, namespace)
+	if err != nil {
+		return store, err
+	}
+	if pvc == nil {
+		return store, fmt.Errorf("backupDaemon.pitr requires backupDaemon.s3.enabled or the backup daemon PVC")
+	}
+	if !slices.Contains(pvc.Spec.AccessModes, v12.ReadWriteMany) {
+		return store, fmt.Errorf("backup daemon PVC %s is not ReadWriteMany and can not be mounted on Cassandra nodes, "+
+			"migrate it to a storage class supporting ReadWriteMany with backupDaemon.storage.storageClasses", pvc.Name)
+	}
+	store.PVCName = pvc.Name
+	return store, nil
+}
+
+// BackupVolumeAccessMode is ReadWriteMany if commitlog segments are shipped to the backup daemon PVC
+func BackupVolumeAccessMode(spec *v1.CassandraSupplService) v12.PersistentVolumeAccessMode {
+	backup := spec.Spec.Backup
+	if backup.PITR.Enabled && !backup.S3.Enabled && !spec.Spec.AWSKeyspaces.Install {
+		return v12.ReadWriteMany
+	}
+	return v12.ReadWriteOnce
+}
+
+func podSegmentsPath(store transferRemote, podName string) string {
+	return store.path(fmt.Sprintf("%s/%s/", commitlogStorePrefix, podName))
+}
+
+// commitlogTransferJob runs the script on the node of the Cassandra pod with the archive directory volume
+// mounted at the same path. The files are accessed with the pod security context.
+func commitlogTransferJob(spec *v1.CassandraSupplService, store transferRemote, pod v12.Pod, name, script string) (*transferJob, error) {
+	volume, mount, err := podVolumeMount(pod, commitlogArchiveDirectory(spec.Spec.Backup.PITR))
+	if err != nil {
+		return nil, err
+	}
+	return &transferJob{
+		Name:            name,
+		Script:          script,
+		Remotes:         []transferRemote{store},
+		Volumes:         []v12.Volume{volume},
+		Mounts:          []v12.VolumeMount{mount},
+		NodeName:        pod.Spec.NodeName,
+		SecurityContext: pod.Spec.SecurityContext,
+	}, nil
+}
+
+// commitlogShippingScript moves archived segments to the store under keys with the archiving time.
+// The archive directory is created for Cassandra by the first job.
+func commitlogShippingScript(spec *v1.CassandraSupplService, store transferRemote, podName string) string {
+	return fmt.Sprintf(`set -e
+mkdir -p '%[1]s' && cd '%[1]s'
+for segment in CommitLog-*.log; do
+  [ -f "$segment" ] || continue
+  rclone moveto "$segment" '%[2]s'"$(stat -c %%Y "$segment")_$segment"
+done`, commitlogArchiveDirectory(spec.Spec.Backup.PITR), podSegmentsPath(store, podName))
+}
+
+// commitlogStagingScript copies segments archived since the time to the restore directory
+func commitlogStagingScript(store transferRemote, podName, restoreDir string, since time.Time) string {
+	return fmt.Sprintf(`set -e
+rm -rf '%[1]s' && mkdir -p '%[1]s'
+rclone lsf '%[2]s' > /tmp/segments
+while read -r key; do
+  if [ "${key%%%%_*}" -ge %[3]d ]; then rclone copyto '%[2]s'"$key" '%[1]s/'"${key#*_}"; fi
+done < /tmp/segments`, restoreDir, podSegmentsPath(store, podName), since.Unix())
+}
+
+// CommitlogArchivingStep keeps the commitlog archiving ConfigMap of Cassandra pods
+type CommitlogArchivingStep struct {
+	core.DefaultExecutable
+}
+
+func (r *CommitlogArchivingStep) Execute(ctx core.ExecutionContext) error {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+
+	err := configureCommitlogArchiving(kubeClient, spec, request.Namespace, nil)
+	core.PanicError(err, log.Error, "Failed to configure commitlog archiving")
+
+	log.Info(fmt.Sprintf("Commitlog archiving is configured in %s ConfigMap, it is applied after Cassandra restart", utils.CommitlogArchivingConfig))
+	return nil
+}
+
+// CommitlogShipper periodically runs jobs moving archived commitlog segments from Cassandra nodes to the backup storage
+type CommitlogShipper struct {
+	Client       client.Client
+	Helper       core.KubernetesHelper
+	StoreBuilder ObjectStoreBuilder
+}
+
+// Run starts shipping jobs on Cassandra nodes and updates `status.backup.pitr` with the shipped segments.
+// A job still running from the previous run is left to complete.
+// It returns the interval after which it should be called again, zero if PITR is disabled.
+func (r *CommitlogShipper) Run(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+
+	pitr := spec.Spec.Backup.PITR
+	if !spec.Spec.Backup.Install || !pitr.Enabled || spec.Spec.AWSKeyspaces.Install {
+		return 0, nil
+	}
+
+	interval, err := utils.ParseDurationOrDefault(pitr.ShipInterval, defaultCommitlogShipInterval)
+	if err != nil {
+		return defaultCommitlogShipInterval, fmt.Errorf("invalid backupDaemon.pitr.shipInterval: %w", err)
+	}
+	remote, err := commitlogStore(r.Client, spec, request.Namespace)
+	if err != nil {
+		return interval, err
+	}
+
+	now := time.Now()
+	if status := spec.Status.Backup; status != nil && status.PITR != nil && status.PITR.LastShipTime != nil {
+		if due := status.PITR.LastShipTime.Add(interval).Sub(now); due > 0 {
+			return due, nil
+		}
+	}
+
+	store, err := r.StoreBuilder.Build(r.Client, spec, request.Namespace)
+	if err != nil {
+		return interval, fmt.Errorf("failed to access backup storage: %w", err)
+	}
+	pods, err := listCassandraPods(r.Helper, request.Namespace)
+	if err != nil {
+		return interval, err
+	}
+
+	var failures []string
+	for _, pod := range pods {
+		if err := r.ship(spec, remote, pod, request.Namespace, log); err != nil {
+			failures = append(failures, fmt.Sprintf("'%s' pod: %v", pod.Name, err))
+		}
+	}
+
+	keys, err := store.List(commitlogStorePrefix + "/")
+	if err != nil {
+		return interval, err
+	}
+
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	if spec.Status.Backup.PITR == nil {
+		spec.Status.Backup.PITR = &v1.PITRStatus{}
+	}
+	status := spec.Status.Backup.PITR
+	status.LastShipTime = &metav1.Time{Time: now}
+	status.ShippedSegments = len(keys)
+	status.LatestRecoverablePoint = latestRecoverablePoint(keys, pods, spec.Status.Backup.LastFullBackup)
+
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return interval, fmt.Errorf("failed to update PITR status: %w", err)
+	}
+	if len(failures) > 0 {
+		return interval, fmt.Errorf("failed to ship commitlog segments from %s", strings.Join(failures, "; "))
+	}
+	return interval, nil
+}
+
+// ship starts the shipping job of the pod unless the previous one is still running, the failure of the finished job is returned
+func (r *CommitlogShipper) ship(spec *v1.CassandraSupplService, store transferRemote, pod v12.Pod, namespace string, log *zap.Logger) error {
+	name := fmt.Sprintf(utils.CommitlogShippingJob, pod.Name)
+	job, state, message, err := checkTransferJob(r.Client, namespace, name)
+	if err != nil {
+		return err
+	}
+	switch state {
+	case transferRunning:
+		log.Debug(fmt.Sprintf("Commitlog shipping job %s is still running", name))
+		return nil
+	case transferFailed:
+		return fmt.Errorf("job %s failed: %s", name, message)
+	}
+	if job != nil {
+		// the finished job is being removed, the next one is started on the next run
+		return nil
+	}
+
+	shipping, err := commitlogTransferJob(spec, store, pod, name, commitlogShippingScript(spec, store, pod.Name))
+	if err != nil {
+		return err
+	}
+	return startTransferJob(r.Client, spec, shipping.build(spec, namespace))
+}
+
+// latestRecoverablePoint is the oldest of the newest shipped segments across Cassandra pods
+// but not earlier than the last full backup
+func latestRecoverablePoint(keys []string, pods []v12.Pod, lastFullBackup *v1.BackupInfo) *metav1.Time {
+	newest := map[string]time.Time{}
+	for _, key := range keys {
+		podName, segment, ok := parseSegmentKey(key)
+		if ok && segment.Time.After(newest[podName]) {
+			newest[podName] = segment.Time
+		}
+	}
+
+	var point *time.Time
+	for _, pod := range pods {
+		podNewest, found := newest[pod.Name]
+		if !found {
+			continue
+		}
+		if point == nil || podNewest.Before(*point) {
+			point = &podNewest
+		}
+	}
+
+	if lastFullBackup != nil && lastFullBackup.Result == BackupResultSuccessful && lastFullBackup.Time != nil &&
+		(point == nil || lastFullBackup.Time.After(*point)) {
+		point = &lastFullBackup.Time.Time
+	}
+	if point == nil {
+		return nil
+	}
+	return &metav1.Time{Time: *point}
+}
+
+// PITRRestoreStep restores the nearest full backup before `backupDaemon.pitr.restorePointInTime`
+// and replays shipped commitlog segments on top of it
+type PITRRestoreStep struct {
+	core.DefaultExecutable
+	Timeout      time.Duration
+	PollInterval time.Duration
+}
+
+func (r *PITRRestoreStep) Condition(ctx core.ExecutionContext) (bool, error) {
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	point := spec.Spec.Backup.PITR.RestorePointInTime
+	if point == "" {
+		return false, nil
+	}
+	if status := spec.Status.Backup; status != nil && status.PITR != nil && status.PITR.Restore != nil {
+		restore := status.PITR.Restore
+		return restore.PointInTime != point || restore.Result != BackupResultSuccessful, nil
+	}
+	return true, nil
+}
+
+func (r *PITRRestoreStep) Execute(ctx core.ExecutionContext) error {
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+
+	point := spec.Spec.Backup.PITR.RestorePointInTime
+	log.Info(fmt.Sprintf("Restoring Cassandra to %s", point))
+
+	// the status is committed together with the reconcile result
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	if spec.Status.Backup.PITR == nil {
+		spec.Status.Backup.PITR = &v1.PITRStatus{}
+	}
+	restore := &v1.PITRRestoreStatus{PointInTime: point, Time: &metav1.Time{Time: time.Now()}}
+	spec.Status.Backup.PITR.Restore = restore
+
+	err := r.restore(ctx, restore)
+	if err != nil {
+		restore.Result = BackupResultFailed
+		restore.Message = err.Error()
+	}
+	core.PanicError(err, log.Error, fmt.Sprintf("Point-in-time restore to %s failed", point))
+
+	restore.Result = BackupResultSuccessful
+	log.Info(fmt.Sprintf("Cassandra is restored to %s", point))
+	return nil
+}
+
+func (r *PITRRestoreStep) restore(ctx core.ExecutionContext, restore *v1.PITRRestoreStatus) error {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	helperImpl := ctx.Get(utils.KubernetesHelperImpl).(core.KubernetesHelper)
+	kubeConfig := ctx.Get(constants.ContextKubeClient).(*rest.Config)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+	daemonClientBuilder := ctx.Get(utils.ContextBackupDaemonClientBuilder).(DaemonClientBuilder)
+
+	pitr := spec.Spec.Backup.PITR
+	point, err := time.Parse(time.RFC3339, pitr.RestorePointInTime)
+	if err != nil {
+		return fmt.Errorf("invalid backupDaemon.pitr.restorePointInTime: %w", err)
+	}
+	store, err := commitlogStore(kubeClient, spec, request.Namespace)
+	if err != nil {
+		return err
+	}
+
+	daemonClient, err := daemonClientBuilder.Build(kubeClient, spec, request.Namespace)
+	if err != nil {
+		return err
+	}
+	backup, err := nearestFullBackup(daemonClient, point)
+	if err != nil {
+		return err
+	}
+	restore.BackupId = backup.Id
+	log.Info(fmt.Sprintf("Full backup %s made at %s is used for the restore", backup.Id, backup.Time()))
+
+	pods, err := listCassandraPods(helperImpl, request.Namespace)
+	if err != nil {
+		return err
+	}
+
+	// segments archived after the backup are staged on Cassandra nodes for the replay
+	restoreDir := fmt.Sprintf("%s/%s", commitlogArchiveDirectory(pitr), commitlogRestoreDirectory)
+	var jobs []*batchv1.Job
+	for _, pod := range pods {
+		staging, err := commitlogTransferJob(spec, store, pod, fmt.Sprintf(utils.CommitlogStagingJob, pod.Name),
+			commitlogStagingScript(store, pod.Name, restoreDir, backup.Time()))
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, staging.build(spec, request.Namespace))
+	}
+	if err := runTransferJobs(kubeClient, spec, jobs, r.Timeout, r.PollInterval); err != nil {
+		return fmt.Errorf("failed to copy commitlog segments to Cassandra nodes: %w", err)
+	}
+	if err := configureCommitlogArchiving(kubeClient, spec, request.Namespace, &point); err != nil {
+		return err
+	}
+
+	jobId, err := daemonClient.Restore(backup.Id)
+	if err != nil {
+		return err
+	}
+	if err := WaitForDaemonJob(daemonClient, jobId, r.Timeout, r.PollInterval); err != nil {
+		return err
+	}
+
+	// segments are replayed on Cassandra start, restarted pods mount the ConfigMap with the restore point
+	for i := range pods {
+		if err := helperImpl.RestartPod(&pods[i], request.Namespace, spec.Spec.WaitTimeout); err != nil {
+			return err
+		}
+	}
+
+	if err := configureCommitlogArchiving(kubeClient, spec, request.Namespace, nil); err != nil {
+		return err
+	}
+	executor := &PodExecutor{Helper: helperImpl, KubeConfig: kubeConfig, Log: log}
+	for _, pod := range pods {
+		if _, err := executor.Exec(pod, fmt.Sprintf("rm -rf '%s'", restoreDir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nearestFullBackup(daemonClient DaemonClient, point time.Time) (*DaemonBackup, error) {
+	ids, err := daemonClient.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	var nearest *DaemonBackup
+	for _, id := range ids {
+		backup, err := daemonClient.GetBackup(id)
+		if err != nil {
+			return nil, err
+		}
+		if backup.IsGranular || backupResult(backup) != BackupResultSuccessful || backup.Time().After(point) {
+			continue
+		}
+		if nearest == nil || backup.Ts > nearest.Ts {
+			nearest = backup
+		}
+	}
+	if nearest == nil {
+		return nil, fmt.Errorf("no successful full backup found before %s", point)
+	}
+	return nearest, nil
+}
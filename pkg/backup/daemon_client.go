@@ -0,0 +1,268 @@
+package backup
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/vault"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const daemonRequestTimeout = 30 * time.Second
+
+// DaemonHealth is the subset of the backup daemon /health response the operator relies on
+type DaemonHealth struct {
+	Status  string        `json:"status"`
+	Storage DaemonStorage `json:"storage"`
+	// addresses of the clients that recently connected over plain HTTP, reported in the TLS optional mode only
+	PlainHTTPClients []string `json:"plain_http_clients,omitempty"`
+}
+
+type DaemonStorage struct {
+	DumpCount      int               `json:"dump_count"`
+	Size           int64             `json:"size"`
+	TotalSpace     int64             `json:"total_space"`
+	FreeSpace      int64             `json:"free_space"`
+	Last           *DaemonBackupInfo `json:"last,omitempty"`
+	LastSuccessful *DaemonBackupInfo `json:"lastSuccessful,omitempty"`
+}
+
+type DaemonBackupInfo struct {
+	Id     string `json:"id"`
+	Failed bool   `json:"failed"`
+	Locked bool   `json:"locked"`
+	// unix time in milliseconds
+	Ts int64 `json:"ts"`
+}
+
+// DaemonBackup is the backup daemon /listbackups/<id> response
+type DaemonBackup struct {
+	Id         string          `json:"id"`
+	Failed     bool            `json:"failed"`
+	Locked     bool            `json:"locked"`
+	IsGranular bool            `json:"is_granular"`
+	DbList     json.RawMessage `json:"db_list"`
+	Ts         int64           `json:"ts"`
+	Size       string          `json:"size"`
+}
+
+// Keyspaces returns keyspaces of a granular backup. Full backups report db_list as a plain string.
+func (r *DaemonBackup) Keyspaces() []string {
+	var keyspaces []string
+	if err := json.Unmarshal(r.DbList, &keyspaces); err != nil {
+		return nil
+	}
+	return keyspaces
+}
+
+func (r *DaemonBackup) Time() time.Time {
+	return time.UnixMilli(r.Ts).UTC()
+}
+
+// DaemonJobStatus is the backup daemon /jobstatus/<id> response
+type DaemonJobStatus struct {
+	Status string `json:"status"`
+	Type   string `json:"type,omitempty"`
+	Error  string `json:"err,omitempty"`
+}
+
+// backup daemon job statuses
+const (
+	JobStatusSuccessful = "Successful"
+	JobStatusFailed     = "Failed"
+	JobStatusQueued     = "Queued"
+	JobStatusProcessing = "Processing"
+)
+
+type DaemonClient interface {
+	Health() (*DaemonHealth, error)
+	ListBackups() ([]string, error)
+	GetBackup(id string) (*DaemonBackup, error)
+	// Backup starts a full backup and returns its id
+	Backup() (string, error)
+	// Restore starts restoring the backup and returns the job id
+	Restore(id string) (string, error)
+	JobStatus(id string) (*DaemonJobStatus, error)
+	// Evict removes the backup from the storage
+	Evict(id string) error
+}
+
+type DaemonClientBuilder interface {
+	Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (DaemonClient, error)
+}
+
+type DaemonClientBuilderImpl struct {
+}
+
+// Build creates a backup daemon client authorized with `backupDaemon.secretName` credentials
+func (b *DaemonClientBuilderImpl) Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (DaemonClient, error) {
+	secret, err := core.ReadSecret(kubeClient, spec.Spec.Backup.SecretName, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", spec.Spec.Backup.SecretName, err)
+	}
+
+	pass := string(secret.Data[utils.Password])
+	if spec.Spec.VaultRegistration.Enabled {
+		vaultHelper := vault.NewVaulterHelperImpl(vault.NewVaultClientImpl(&spec.Spec.VaultRegistration))
+		if vaultHelper.IsVaultURL(pass) {
+			pass, err = vaultHelper.ResolvePassword(pass)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	httpClient := &http.Client{Timeout: daemonRequestTimeout}
+	if spec.Spec.TLS.Enabled {
+		caSecret, err := core.ReadSecret(kubeClient, spec.Spec.TLS.RootCASecretName, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", spec.Spec.TLS.RootCASecretName, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caSecret.Data[spec.Spec.TLS.RootCAFileName]) {
+			return nil, fmt.Errorf("no CA certificate found in secret %s", spec.Spec.TLS.RootCASecretName)
+		}
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+
+	return &DaemonClientImpl{
+		Address:    utils.BackupDaemonAddress(namespace, spec.Spec.TLS.Enabled),
+		Username:   string(secret.Data[utils.Username]),
+		Password:   pass,
+		HTTPClient: httpClient,
+	}, nil
+}
+
+type DaemonClientImpl struct {
+	Address    string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+}
+
+func (c *DaemonClientImpl) Health() (*DaemonHealth, error) {
+	health := &DaemonHealth{}
+	return health, c.getJSON("/health", health)
+}
+
+func (c *DaemonClientImpl) ListBackups() ([]string, error) {
+	var ids []string
+	return ids, c.getJSON("/listbackups", &ids)
+}
+
+func (c *DaemonClientImpl) GetBackup(id string) (*DaemonBackup, error) {
+	backup := &DaemonBackup{}
+	return backup, c.getJSON("/listbackups/"+id, backup)
+}
+
+func (c *DaemonClientImpl) Backup() (string, error) {
+	data, err := c.do(http.MethodPost, "/backup", nil)
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return "", fmt.Errorf("backup daemon returned empty backup id")
+	}
+	return id, nil
+}
+
+func (c *DaemonClientImpl) Restore(id string) (string, error) {
+	data, err := c.do(http.MethodPost, "/restore/"+id, nil)
+	if err != nil {
+		return "", err
+	}
+	jobId := strings.TrimSpace(string(data))
+	if jobId == "" {
+		return "", fmt.Errorf("backup daemon returned empty restore job id")
+	}
+	return jobId, nil
+}
+
+func (c *DaemonClientImpl) JobStatus(id string) (*DaemonJobStatus, error) {
+	status := &DaemonJobStatus{}
+	return status, c.getJSON("/jobstatus/"+id, status)
+}
+
+func (c *DaemonClientImpl) Evict(id string) error {
+	_, err := c.do(http.MethodPost, "/evict/"+id, nil)
+	return err
+}
+
+func (c *DaemonClientImpl) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Address, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("backup daemon %s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func (c *DaemonClientImpl) getJSON(path string, result interface{}) error {
+	data, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to parse backup daemon %s response: %w", path, err)
+	}
+	return nil
+}
+
+// WaitForDaemonJob waits until the backup daemon job succeeds, fails or the timeout expires
+func WaitForDaemonJob(daemonClient DaemonClient, id string, timeout, pollInterval time.Duration) error {
+	var requestErr error
+	err := wait.PollUntilContextTimeout(context.Background(), pollInterval, timeout, true,
+		func(ctx context.Context) (bool, error) {
+			jobStatus, err := daemonClient.JobStatus(id)
+			if err != nil {
+				// the daemon may be temporarily unavailable
+				requestErr = err
+				return false, nil
+			}
+			switch jobStatus.Status {
+			case JobStatusSuccessful:
+				return true, nil
+			case JobStatusFailed:
+				return false, fmt.Errorf("job %s failed: %s", id, jobStatus.Error)
+			}
+			return false, nil
+		})
+	if wait.Interrupted(err) {
+		if requestErr != nil {
+			return fmt.Errorf("job %s was not completed in %s: %w", id, timeout, requestErr)
+		}
+		return fmt.Errorf("job %s was not completed in %s", id, timeout)
+	}
+	return err
+}
@@ -12,6 +12,7 @@ import (
 	coreUtils "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/utils"
 	"go.uber.org/zap"
 	v12 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -26,6 +27,7 @@ func (r *LegacyBackupDeployment) Execute(ctx core.ExecutionContext) error {
 	helperImpl := ctx.Get(utils.KubernetesHelperImpl).(core.KubernetesHelper)
 	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
 	credsManager := ctx.Get(utils.ContextCredsManager).(utils.CredsManagerI)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
 
 	dcs := spec.Spec.Cassandra.DeploymentSchema.DataCenters
 	var hosts []string
@@ -54,23 +56,33 @@ func (r *LegacyBackupDeployment) Execute(ctx core.ExecutionContext) error {
 		if err != nil {
 			return err
 		}
+		backupSchedule := backup.BackupSchedule
+		if backup.Snapshots.Enabled {
+			// full backups are made as volume snapshots by the operator
+			backupSchedule = "None"
+		}
+		evictionPolicy, granularEvictionPolicy := backup.EvictionPolicy, backup.GranularEvictionPolicy
+		if backup.Catalog.Enabled {
+			// backups are evicted by the operator respecting keyspace policies and legal holds
+			evictionPolicy, granularEvictionPolicy = utils.NoEvictionPolicy, utils.NoEvictionPolicy
+		}
 		envs = append(envs,
 			coreUtils.GetPlainTextEnvVar("CASSANDRA_HOSTS", strings.Join(hosts[:], " ")),
-			coreUtils.GetPlainTextEnvVar("BACKUP_SCHEDULE", backup.BackupSchedule),
+			coreUtils.GetPlainTextEnvVar("BACKUP_SCHEDULE", backupSchedule),
 			coreUtils.GetPlainTextEnvVar("GRANULAR_SCHEDULE", backup.GranularBackupSchedule),
 			coreUtils.GetPlainTextEnvVar("SCHEDULED_DBS", strings.Join(backup.GranularBackupScheduledDbs[:], ",")),
-			coreUtils.GetPlainTextEnvVar("EVICTION_POLICY", backup.EvictionPolicy),
-			coreUtils.GetPlainTextEnvVar("GRANULAR_EVICTION_POLICY", backup.GranularEvictionPolicy),
+			coreUtils.GetPlainTextEnvVar("EVICTION_POLICY", evictionPolicy),
+			coreUtils.GetPlainTextEnvVar("GRANULAR_EVICTION_POLICY", granularEvictionPolicy),
 			coreUtils.GetPlainTextEnvVar("STORAGE", backup.StorageDirectory),
 			coreUtils.GetPlainTextEnvVar("CASSANDRA_MAJOR_VERSION", cm.Data["majorVersion"]),
 			coreUtils.GetSecretEnvVar("SSH_PRIVATE_KEY", utils.SSHSecret, "privateKey"),
-			coreUtils.GetSecretEnvVar("CASSANDRA_USERNAME", spec.Spec.Cassandra.SecretName, utils.Username),
-			coreUtils.GetSecretEnvVar("CASSANDRA_PASSWORD", spec.Spec.Cassandra.SecretName, utils.Password),
 			coreUtils.GetSecretEnvVar("BACKUP_DAEMON_API_CREDENTIALS_USERNAME", backup.SecretName, utils.Username),
 			coreUtils.GetSecretEnvVar("BACKUP_DAEMON_API_CREDENTIALS_PASSWORD", backup.SecretName, utils.Password),
 			coreUtils.GetPlainTextEnvVar("CONNECT_TIMEOUT", fmt.Sprint(spec.Spec.GocqlConnectTimeout)),
 			coreUtils.GetPlainTextEnvVar("REQUEST_TIMEOUT", fmt.Sprint(spec.Spec.GocqlTimeout)),
+			coreUtils.GetPlainTextEnvVar("THROTTLING_CONFIG", utils.BackupThrottlingConfigPath+utils.BackupThrottlingConfigFile),
 		)
+		envs = append(envs, utils.CassandraCredentialEnvs(spec.Spec)...)
 		if backup.S3.Enabled {
 			envs = append(envs,
 				coreUtils.GetPlainTextEnvVar("S3_ENABLED", strconv.FormatBool(backup.S3.Enabled)),
@@ -112,13 +124,57 @@ func (r *LegacyBackupDeployment) Execute(ctx core.ExecutionContext) error {
 		backup.Storage.EmptyDir,
 		utils.GetHTTPPort(spec.Spec.TLS.Enabled))
 
+	utils.HTTPHealthProbes(&dc.Spec.Template.Spec.Containers[0], utils.GetHTTPPort(spec.Spec.TLS.Enabled), backup.Probes)
 
-	err := credsManager.AddCredHashToPodTemplate([]string{spec.Spec.Cassandra.SecretName}, &dc.Spec.Template)
+	err := credsManager.AddCredHashToPodTemplate(utils.CassandraSecretNames(spec.Spec), &dc.Spec.Template)
 	if err != nil {
 		log.Error(fmt.Sprintf("can't add secret HASH to annotations for %s", dc.Name), zap.Error(err))
 		return err
 	}
 
+	if !spec.Spec.AWSKeyspaces.Install {
+		dc.Spec.Template.Spec.Volumes = append(dc.Spec.Template.Spec.Volumes,
+			v12.Volume{
+				Name: "throttling-config",
+				VolumeSource: v12.VolumeSource{
+					ConfigMap: &v12.ConfigMapVolumeSource{
+						LocalObjectReference: v12.LocalObjectReference{
+							Name: utils.BackupThrottlingConfig,
+						},
+					},
+				},
+			},
+		)
+
+		dc.Spec.Template.Spec.Containers[0].VolumeMounts = append(dc.Spec.Template.Spec.Containers[0].VolumeMounts,
+			v12.VolumeMount{
+				Name:      "throttling-config",
+				ReadOnly:  true,
+				MountPath: utils.BackupThrottlingConfigPath,
+			},
+		)
+	}
+
+	if backup.Replication.Enabled && !backup.Replication.S3.Enabled && backup.Replication.PVCName != "" {
+		dc.Spec.Template.Spec.Volumes = append(dc.Spec.Template.Spec.Volumes,
+			v12.Volume{
+				Name: utils.BackupReplica,
+				VolumeSource: v12.VolumeSource{
+					PersistentVolumeClaim: &v12.PersistentVolumeClaimVolumeSource{
+						ClaimName: backup.Replication.PVCName,
+					},
+				},
+			},
+		)
+
+		dc.Spec.Template.Spec.Containers[0].VolumeMounts = append(dc.Spec.Template.Spec.Containers[0].VolumeMounts,
+			v12.VolumeMount{
+				Name:      utils.BackupReplica,
+				MountPath: utils.BackupReplicaPath,
+			},
+		)
+	}
+
 	if backup.S3.SslVerify {
 
 		dc.Spec.Template.Spec.Volumes = append(dc.Spec.Template.Spec.Volumes,
@@ -144,6 +200,12 @@ func (r *LegacyBackupDeployment) Execute(ctx core.ExecutionContext) error {
 	coreUtils.VaultPodSpec(&dc.Spec.Template.Spec, utils.BackupEntrypoint, spec.Spec.VaultRegistration)
 	utils.TLSClientSpecUpdate(&dc.Spec.Template.Spec, utils.RootCertPath, spec.Spec.TLS)
 	utils.TLSServerSpecUpdate(&dc.Spec.Template.Spec, spec.Spec.TLS, spec.Spec.Backup.TLS.BackupDaemonCASecretName, utils.ServerCertsPath)
+	utils.KeystoresSpecUpdate(&dc.Spec.Template.Spec, spec.Spec, utils.BackupDaemon)
+	utils.CassandraClientCertSpecUpdate(&dc.Spec.Template.Spec, spec.Spec)
+	err = utils.AddCertificateHashToPodTemplate(kubeClient, &dc.Spec.Template, spec.Spec, utils.BackupDaemon, request.Namespace)
+	if err != nil {
+		return err
+	}
 
 	err = helperImpl.DeleteDeploymentAndPods(dc.Name, request.Namespace, spec.Spec.WaitTimeout)
 
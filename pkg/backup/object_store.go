@@ -0,0 +1,249 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	// objects read by the operator are kept in memory
+	maxObjectSize = 16 << 20
+)
+
+// ObjectStore is the backup storage for small files the operator keeps itself, e.g. schema versions.
+// Backups and commitlog segments are copied by transfer jobs, the operator only lists and removes them.
+type ObjectStore interface {
+	// List returns keys starting with the prefix
+	List(prefix string) ([]string, error)
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+type ObjectStoreBuilder interface {
+	Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (ObjectStore, error)
+	// BuildReplica builds the secondary storage of `backupDaemon.replication`
+	BuildReplica(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (ObjectStore, error)
+}
+
+// ObjectStoreBuilderImpl builds S3 store if `backupDaemon.s3.enabled` is set, the backup daemon storage otherwise
+type ObjectStoreBuilderImpl struct {
+	Helper     core.KubernetesHelper
+	KubeConfig *rest.Config
+	Log        *zap.Logger
+}
+
+func (b *ObjectStoreBuilderImpl) Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (ObjectStore, error) {
+	backup := spec.Spec.Backup
+	if backup.S3.Enabled {
+		return NewS3ObjectStore(kubeClient, backup.S3, namespace)
+	}
+
+	return b.daemonPodStore(namespace, backup.StorageDirectory)
+}
+
+// BuildReplica builds S3 store if `backupDaemon.replication.s3.enabled` is set, the replica PVC mounted to the backup daemon otherwise
+func (b *ObjectStoreBuilderImpl) BuildReplica(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (ObjectStore, error) {
+	replication := spec.Spec.Backup.Replication
+	if replication.S3.Enabled {
+		return NewS3ObjectStore(kubeClient, replication.S3, namespace)
+	}
+	if replication.PVCName == "" {
+		return nil, fmt.Errorf("backupDaemon.replication requires s3 or pvcName")
+	}
+	return b.daemonPodStore(namespace, utils.BackupReplicaPath)
+}
+
+func (b *ObjectStoreBuilderImpl) daemonPodStore(namespace, root string) (ObjectStore, error) {
+	pods, err := b.Helper.ListPods(namespace, map[string]string{utils.Name: utils.BackupDaemon})
+	if err != nil {
+		return nil, err
+	}
+	if pods == nil || len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no %s pods found", utils.BackupDaemon)
+	}
+	return &PodObjectStore{
+		Executor: &PodExecutor{Helper: b.Helper, KubeConfig: b.KubeConfig, Log: b.Log},
+		Pod:      pods.Items[0],
+		Root:     root,
+	}, nil
+}
+
+// NewS3ObjectStore creates the store for the bucket configured the same way as the backup daemon S3 storage
+func NewS3ObjectStore(kubeClient client.Client, s3Spec v1.S3backup, namespace string) (*S3ObjectStore, error) {
+	secret, err := core.ReadSecret(kubeClient, s3Spec.SecretName, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", s3Spec.SecretName, err)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: !s3Spec.SslVerify}
+	if s3Spec.SslVerify && s3Spec.SslSecretName != "" {
+		caSecret, err := core.ReadSecret(kubeClient, s3Spec.SslSecretName, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", s3Spec.SslSecretName, err)
+		}
+		pool := x509.NewCertPool()
+		for _, data := range caSecret.Data {
+			pool.AppendCertsFromPEM(data)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(s3Spec.EndpointUrl),
+		Region:           aws.String(s3DefaultRegion),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials(string(secret.Data[utils.Username]), string(secret.Data[utils.Password]), ""),
+		HTTPClient: &http.Client{
+			Timeout:   daemonRequestTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3ObjectStore{Client: s3.New(sess), Bucket: s3Spec.BucketName}, nil
+}
+
+type S3ObjectStore struct {
+	Client *s3.S3
+	Bucket string
+}
+
+func (s *S3ObjectStore) List(prefix string) ([]string, error) {
+	var keys []string
+	err := s.Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, aws.StringValue(object.Key))
+		}
+		return true
+	})
+	return keys, err
+}
+
+func (s *S3ObjectStore) Put(key string, data []byte) error {
+	_, err := s.Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *S3ObjectStore) Get(key string) ([]byte, error) {
+	output, err := s.Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(output.Body, maxObjectSize+1))
+	if err == nil && len(data) > maxObjectSize {
+		return nil, fmt.Errorf("object %s exceeds %d bytes", key, maxObjectSize)
+	}
+	return data, err
+}
+
+func (s *S3ObjectStore) Delete(key string) error {
+	_, err := s.Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// PodObjectStore keeps objects as files in a pod, e.g. on the backup daemon storage volume
+type PodObjectStore struct {
+	Executor *PodExecutor
+	Pod      v12.Pod
+	Root     string
+}
+
+func (s *PodObjectStore) path(key string) string {
+	return path.Join(s.Root, key)
+}
+
+func (s *PodObjectStore) List(prefix string) ([]string, error) {
+	out, err := s.Executor.Exec(s.Pod, fmt.Sprintf("cd '%s' 2>/dev/null && find . -type f -path './%s*' | sed 's|^./||'", s.Root, prefix))
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(out), nil
+}
+
+func (s *PodObjectStore) Put(key string, data []byte) error {
+	return s.Executor.WriteFile(s.Pod, s.path(key), data)
+}
+
+func (s *PodObjectStore) Get(key string) ([]byte, error) {
+	return s.Executor.ReadFile(s.Pod, s.path(key))
+}
+
+func (s *PodObjectStore) Delete(key string) error {
+	_, err := s.Executor.Exec(s.Pod, fmt.Sprintf("rm -f '%s'", s.path(key)))
+	return err
+}
+
+// PodExecutor runs shell commands in the first container of a pod
+type PodExecutor struct {
+	Helper     core.KubernetesHelper
+	KubeConfig *rest.Config
+	Log        *zap.Logger
+}
+
+func (e *PodExecutor) Exec(pod v12.Pod, command string) (string, error) {
+	return e.Helper.ExecRemote(e.Log, e.KubeConfig, pod.Name, pod.Namespace, pod.Spec.Containers[0].Name, "bash", []string{command})
+}
+
+func (e *PodExecutor) ReadFile(pod v12.Pod, file string) ([]byte, error) {
+	out, err := e.Exec(pod, fmt.Sprintf("base64 -w0 '%s'", file))
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(out))
+}
+
+// WriteFile passes the data as a quoted here-document on stdin, it is not interpreted by the shell
+func (e *PodExecutor) WriteFile(pod v12.Pod, file string, data []byte) error {
+	_, err := e.Helper.ExecRemote(e.Log, e.KubeConfig, pod.Name, pod.Namespace, pod.Spec.Containers[0].Name, "bash", []string{
+		fmt.Sprintf("mkdir -p '%s' && base64 -d > '%s' <<'EOF'", path.Dir(file), file),
+		base64.StdEncoding.EncodeToString(data),
+		"EOF",
+	})
+	return err
+}
+
+func splitLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
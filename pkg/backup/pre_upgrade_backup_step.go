@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v13 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// PreUpgradePollInterval is how often a running pre-upgrade backup is checked
+const PreUpgradePollInterval = 10 * time.Second
+
+// PreUpgradeBackupStep makes a full backup before components are redeployed with a new version.
+// It must be executed before any component is updated, the components are added to PreUpgradeBackupGate.
+// The step does not wait for the backup, a later reconcile checks it and upgrades the components once it succeeds.
+type PreUpgradeBackupStep struct {
+	core.DefaultExecutable
+	Timeout time.Duration
+}
+
+// PreUpgradeBackupGate runs its steps when no pre-upgrade backup is in progress
+type PreUpgradeBackupGate struct {
+	core.DefaultCompound
+}
+
+func (r *PreUpgradeBackupGate) Condition(ctx core.ExecutionContext) (bool, error) {
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	return !PreUpgradeBackupPending(spec), nil
+}
+
+// PreUpgradeBackupPending reports a pre-upgrade backup the component upgrade waits for
+func PreUpgradeBackupPending(spec *v1.CassandraSupplService) bool {
+	if !spec.Spec.Backup.Install || !spec.Spec.Backup.BackupBeforeUpgrade || spec.Status.Backup == nil {
+		return false
+	}
+	status := spec.Status.Backup.PreUpgradeBackup
+	return status != nil && status.Result == BackupResultInProgress
+}
+
+type componentVersions struct {
+	DeploymentVersion string `json:"deploymentVersion"`
+	BackupImage       string `json:"backupImage"`
+	DbaasImage        string `json:"dbaasImage"`
+	RobotTestsImage   string `json:"robotTestsImage"`
+}
+
+func ComponentVersionsHash(spec *v1.CassandraSupplService) string {
+	data, _ := json.Marshal(componentVersions{
+		DeploymentVersion: spec.Spec.DeploymentVersion,
+		BackupImage:       spec.Spec.Backup.DockerImage,
+		DbaasImage:        spec.Spec.Dbaas.DockerImage,
+		RobotTestsImage:   spec.Spec.RobotTests.DockerImage,
+	})
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Condition is true if the deployment version or images differ from the last ones a pre-upgrade backup was made for.
+// A clean deploy has nothing to back up, its versions are recorded as covered.
+func (r *PreUpgradeBackupStep) Condition(ctx core.ExecutionContext) (bool, error) {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+
+	hash := ComponentVersionsHash(spec)
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	status := spec.Status.Backup
+	if status.BackedUpVersionHash == hash {
+		return false, nil
+	}
+
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.BackupDaemon, Namespace: request.Namespace}, &v13.Deployment{})
+	if errors.IsNotFound(err) {
+		// the status is committed together with the reconcile result
+		log.Info("Backup daemon is not deployed yet, no pre-upgrade backup is needed")
+		status.BackedUpVersionHash = hash
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if previous := status.PreUpgradeBackup; previous != nil && previous.VersionHash == hash && previous.Result != BackupResultSuccessful {
+		log.Info("Previous pre-upgrade backup did not succeed, retrying")
+	}
+	return true, nil
+}
+
+func (r *PreUpgradeBackupStep) Execute(ctx core.ExecutionContext) error {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+	clientBuilder := ctx.Get(utils.ContextBackupDaemonClientBuilder).(DaemonClientBuilder)
+
+	// the status is committed together with the reconcile result
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	daemonClient, err := clientBuilder.Build(kubeClient, spec, request.Namespace)
+
+	status := spec.Status.Backup.PreUpgradeBackup
+	if !PreUpgradeBackupPending(spec) || status.Id == "" {
+		log.Info(fmt.Sprintf("Deployment version or images have changed, making a full backup before upgrading to %s", spec.Spec.DeploymentVersion))
+		status = &v1.PreUpgradeBackupStatus{
+			Time:   &metav1.Time{Time: time.Now()},
+			Result: BackupResultInProgress,
+		}
+		spec.Status.Backup.PreUpgradeBackup = status
+		if err == nil {
+			status.Id, err = daemonClient.Backup()
+		}
+		if err == nil {
+			log.Info(fmt.Sprintf("Pre-upgrade backup %s is started", status.Id))
+		}
+	}
+	// any backup made before the components are updated covers the current versions
+	status.DeploymentVersion = spec.Spec.DeploymentVersion
+	status.VersionHash = ComponentVersionsHash(spec)
+
+	var jobStatus *DaemonJobStatus
+	if err == nil {
+		jobStatus, err = daemonClient.JobStatus(status.Id)
+	}
+	switch {
+	case err == nil && jobStatus.Status == JobStatusSuccessful:
+		status.Result, status.Message = BackupResultSuccessful, ""
+		spec.Status.Backup.BackedUpVersionHash = status.VersionHash
+		log.Info(fmt.Sprintf("Pre-upgrade backup %s is completed", status.Id))
+		return nil
+	case err == nil && jobStatus.Status == JobStatusFailed:
+		err = fmt.Errorf("job %s failed: %s", status.Id, jobStatus.Error)
+	case status.Id != "" && time.Since(status.Time.Time) < r.Timeout:
+		// the daemon may be temporarily unavailable
+		if err != nil {
+			log.Warn(fmt.Sprintf("Failed to check pre-upgrade backup %s: %v", status.Id, err))
+		}
+		log.Info(fmt.Sprintf("Pre-upgrade backup %s is in progress, the components are upgraded once it succeeds", status.Id))
+		// the spec hash is reset, so that the next reconcile runs the upgrade again instead of treating the spec as applied
+		return core.DeleteSpecConfigMap(ctx)
+	case err == nil:
+		err = fmt.Errorf("job %s was not completed in %s", status.Id, r.Timeout)
+	}
+
+	status.Result = BackupResultFailed
+	status.Message = err.Error()
+	core.PanicError(err, log.Error, "Pre-upgrade backup failed, the upgrade is blocked")
+	return nil
+}
@@ -0,0 +1,276 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultReplicationInterval = 10 * time.Minute
+	defaultReplicationMaxLag   = time.Hour
+	replicationPollInterval    = time.Minute
+)
+
+// backup replication states
+const (
+	ReplicationReplicated = "Replicated"
+	ReplicationPending    = "Pending"
+	ReplicationFailed     = "Failed"
+)
+
+// BackupReplicator periodically runs a job copying successful backups from the backup storage to the secondary target.
+// The operator only tracks the replication state, the files are copied by the job.
+type BackupReplicator struct {
+	Client        client.Client
+	ClientBuilder DaemonClientBuilder
+	StoreBuilder  ObjectStoreBuilder
+}
+
+// Run collects the result of the finished replication job, starts the job for the next backup not yet replicated,
+// removes evicted backups from the secondary target and updates `status.backup.replication`.
+// It returns the interval after which it should be called again, zero if the replication is disabled.
+func (r *BackupReplicator) Run(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+
+	replication := spec.Spec.Backup.Replication
+	if !spec.Spec.Backup.Install || !replication.Enabled || spec.Spec.AWSKeyspaces.Install {
+		return 0, nil
+	}
+
+	interval, err := utils.ParseDurationOrDefault(replication.Interval, defaultReplicationInterval)
+	if err != nil {
+		return defaultReplicationInterval, fmt.Errorf("invalid backupDaemon.replication.interval: %w", err)
+	}
+	maxLag, err := utils.ParseDurationOrDefault(replication.MaxLag, defaultReplicationMaxLag)
+	if err != nil {
+		return interval, fmt.Errorf("invalid backupDaemon.replication.maxLag: %w", err)
+	}
+
+	daemonClient, err := r.ClientBuilder.Build(r.Client, spec, request.Namespace)
+	if err != nil {
+		return interval, err
+	}
+	backups, err := listDaemonBackups(daemonClient)
+	if err != nil {
+		return interval, err
+	}
+	target, err := r.StoreBuilder.BuildReplica(r.Client, spec, request.Namespace)
+	if err != nil {
+		return interval, fmt.Errorf("failed to access replication target: %w", err)
+	}
+
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	previous := map[string]v1.BackupReplicationState{}
+	if spec.Status.Backup.Replication != nil {
+		for _, state := range spec.Status.Backup.Replication.Backups {
+			previous[state.Id] = state
+		}
+	}
+
+	now := time.Now()
+	job, jobState, message, err := checkTransferJob(r.Client, request.Namespace, utils.BackupReplicationJob)
+	if err != nil {
+		return interval, err
+	}
+	if job != nil {
+		id := job.Annotations[utils.ReplicatedBackupAnnotation]
+		switch jobState {
+		case transferSucceeded:
+			log.Info(fmt.Sprintf("Backup %s is replicated", id))
+			previous[id] = v1.BackupReplicationState{Id: id, State: ReplicationReplicated, Time: &metav1.Time{Time: now}}
+		case transferFailed:
+			log.Error(fmt.Sprintf("Backup %s replication failed: %s", id, message))
+			previous[id] = v1.BackupReplicationState{Id: id, State: ReplicationFailed, Message: message}
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Ts < backups[j].Ts })
+	var states []v1.BackupReplicationState
+	var oldestPending *time.Time
+	// pending backups are replicated before the failed ones are retried
+	var nextPending, nextFailed string
+	retained := map[string]bool{}
+	for _, backup := range backups {
+		retained[backup.Id] = true
+		if backupResult(backup) != BackupResultSuccessful {
+			continue
+		}
+		state, found := previous[backup.Id]
+		if !found {
+			state = v1.BackupReplicationState{Id: backup.Id, State: ReplicationPending}
+		}
+		if state.State != ReplicationReplicated {
+			if oldestPending == nil {
+				backupTime := backup.Time()
+				oldestPending = &backupTime
+			}
+			if state.State == ReplicationFailed && nextFailed == "" {
+				nextFailed = backup.Id
+			} else if state.State == ReplicationPending && nextPending == "" {
+				nextPending = backup.Id
+			}
+		}
+		states = append(states, state)
+	}
+
+	// one backup is replicated at a time, the finished job is being removed and the next one is started on the next run
+	next := core.OptionalString(nextPending, nextFailed)
+	replicating := job != nil && jobState == transferRunning
+	if job == nil && next != "" {
+		state := v1.BackupReplicationState{Id: next, State: ReplicationPending}
+		if err := r.startReplication(spec, request.Namespace, next); err != nil {
+			log.Error(fmt.Sprintf("Backup %s replication failed: %v", next, err))
+			state = v1.BackupReplicationState{Id: next, State: ReplicationFailed, Message: err.Error()}
+		} else {
+			log.Info(fmt.Sprintf("Replicating backup %s", next))
+			replicating = true
+		}
+		for i := range states {
+			if states[i].Id == next {
+				states[i] = state
+			}
+		}
+	}
+
+	// the secondary target follows the backup storage retention, backups under legal hold are kept
+	holds := LegalHolds(spec)
+	for id, state := range previous {
+		switch {
+		case retained[id]:
+		case holds[id]:
+			states = append(states, state)
+		default:
+			log.Info(fmt.Sprintf("Removing evicted backup %s from replication target", id))
+			if err := deleteReplica(target, id); err != nil {
+				state.Message = fmt.Sprintf("failed to remove evicted backup: %v", err)
+				states = append(states, state)
+			}
+		}
+	}
+	sort.SliceStable(states, func(i, j int) bool { return states[i].Id < states[j].Id })
+
+	status := &v1.ReplicationStatus{
+		Target:       replicationTarget(replication),
+		LastSyncTime: &metav1.Time{Time: now},
+		Backups:      states,
+	}
+	condition := newCondition(ReplicationLagging, false, "ReplicationUpToDate", "", now)
+	if oldestPending != nil {
+		lag := now.Sub(*oldestPending)
+		status.Lag = lag.Round(time.Second).String()
+		if lag > maxLag {
+			condition = newCondition(ReplicationLagging, true, "ReplicationLagging",
+				fmt.Sprintf("the oldest backup not yet replicated was made %s ago, the maximum lag is %s", lag.Round(time.Minute), maxLag), now)
+		}
+	}
+	spec.Status.Backup.Replication = status
+	spec.Status.Backup.Conditions = utils.SetCondition(spec.Status.Backup.Conditions, condition)
+
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return interval, fmt.Errorf("failed to update replication status: %w", err)
+	}
+	// the running job is checked more often than new backups appear
+	if (replicating || job != nil) && replicationPollInterval < interval {
+		return replicationPollInterval, nil
+	}
+	return interval, nil
+}
+
+// startReplication starts the job copying the backup directory with rclone. PVCs are mounted ReadWriteOnce
+// by the backup daemon, so the job runs on its node.
+func (r *BackupReplicator) startReplication(spec *v1.CassandraSupplService, namespace, id string) error {
+	backup := spec.Spec.Backup
+	job := &transferJob{
+		Name:        utils.BackupReplicationJob,
+		Annotations: map[string]string{utils.ReplicatedBackupAnnotation: id},
+	}
+	mountPVC := func(name, claim string, readOnly bool) string {
+		job.Volumes = append(job.Volumes, v12.Volume{
+			Name:         name,
+			VolumeSource: v12.VolumeSource{PersistentVolumeClaim: &v12.PersistentVolumeClaimVolumeSource{ClaimName: claim, ReadOnly: readOnly}},
+		})
+		job.Mounts = append(job.Mounts, v12.VolumeMount{Name: name, MountPath: "/" + name, ReadOnly: readOnly})
+		return fmt.Sprintf("/%s/%s", name, id)
+	}
+
+	var source, target string
+	if backup.S3.Enabled {
+		remote := transferRemote{Name: "source", S3: backup.S3}
+		job.Remotes = append(job.Remotes, remote)
+		source = remote.path(id)
+	} else {
+		pvc, err := ActiveBackupPVC(r.Client, namespace)
+		if err != nil {
+			return err
+		}
+		if pvc == nil {
+			return fmt.Errorf("backup storage is neither S3 nor a PVC")
+		}
+		source = mountPVC("source", pvc.Name, true)
+	}
+	if backup.Replication.S3.Enabled {
+		remote := transferRemote{Name: "replica", S3: backup.Replication.S3}
+		job.Remotes = append(job.Remotes, remote)
+		target = remote.path(id)
+	} else {
+		if backup.Replication.PVCName == "" {
+			return fmt.Errorf("backupDaemon.replication requires s3 or pvcName")
+		}
+		target = mountPVC("replica", backup.Replication.PVCName, false)
+	}
+
+	if len(job.Volumes) > 0 {
+		pods := &v12.PodList{}
+		if err := r.Client.List(context.TODO(), pods, client.InNamespace(namespace), client.MatchingLabels{utils.Name: utils.BackupDaemon}); err != nil {
+			return err
+		}
+		for _, pod := range pods.Items {
+			if pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil {
+				job.NodeName = pod.Spec.NodeName
+				break
+			}
+		}
+		if job.NodeName == "" {
+			return fmt.Errorf("no scheduled %s pod found to share the backup volumes with", utils.BackupDaemon)
+		}
+	}
+
+	job.Script = fmt.Sprintf("rclone copy '%s' '%s'", source, target)
+	return startTransferJob(r.Client, spec, job.build(spec, namespace))
+}
+
+func deleteReplica(target ObjectStore, id string) error {
+	keys, err := target.List(id + "/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := target.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replicationTarget(replication v1.BackupReplication) string {
+	if replication.S3.Enabled {
+		return fmt.Sprintf("s3://%s (%s)", replication.S3.BucketName, replication.S3.EndpointUrl)
+	}
+	return fmt.Sprintf("pvc:%s", replication.PVCName)
+}
@@ -0,0 +1,453 @@
+package backup
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// column kinds of system_schema.columns
+const (
+	ColumnPartitionKey = "partition_key"
+	ColumnClustering   = "clustering"
+	ColumnStatic       = "static"
+	ColumnRegular      = "regular"
+)
+
+var systemKeyspaces = map[string]bool{
+	"system":                  true,
+	"system_auth":             true,
+	"system_schema":           true,
+	"system_distributed":      true,
+	"system_traces":           true,
+	"system_views":            true,
+	"system_virtual_schema":   true,
+	"system_multiregion_info": true,
+}
+
+// IsSystemKeyspace reports keyspaces excluded from schema backups
+func IsSystemKeyspace(name string) bool {
+	return systemKeyspaces[name]
+}
+
+// KeyspaceSchema is the schema of a keyspace as read from system_schema tables
+type KeyspaceSchema struct {
+	Name          string            `json:"name"`
+	DurableWrites bool              `json:"durableWrites"`
+	Replication   map[string]string `json:"replication"`
+	Types         []TypeSchema      `json:"types,omitempty"`
+	Tables        []TableSchema     `json:"tables,omitempty"`
+}
+
+type TypeSchema struct {
+	Name   string         `json:"name"`
+	Fields []ColumnSchema `json:"fields"`
+}
+
+// TableSchema is a table or a materialized view
+type TableSchema struct {
+	Name    string         `json:"name"`
+	Columns []ColumnSchema `json:"columns"`
+	// table options as CQL literals, e.g. `gc_grace_seconds: 864000`
+	Options map[string]string `json:"options,omitempty"`
+	Indexes []IndexSchema     `json:"indexes,omitempty"`
+	View    *ViewSchema       `json:"view,omitempty"`
+}
+
+type ViewSchema struct {
+	BaseTable         string `json:"baseTable"`
+	WhereClause       string `json:"whereClause"`
+	IncludeAllColumns bool   `json:"includeAllColumns,omitempty"`
+}
+
+type ColumnSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Kind string `json:"kind,omitempty"`
+	// position in the partition key or the clustering key
+	Position        int    `json:"position,omitempty"`
+	ClusteringOrder string `json:"clusteringOrder,omitempty"`
+}
+
+type IndexSchema struct {
+	Name    string            `json:"name"`
+	Kind    string            `json:"kind"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+var unquotedIdentifier = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func quoteIdentifier(name string) string {
+	if unquotedIdentifier.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// CQLMap renders a map as a CQL map literal with sorted keys
+func CQLMap(values map[string]string) string {
+	keys := sortedKeys(values)
+	items := make([]string, len(keys))
+	for i, key := range keys {
+		items[i] = fmt.Sprintf("%s: %s", quoteString(key), quoteString(values[key]))
+	}
+	return "{" + strings.Join(items, ", ") + "}"
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SortSchema orders keyspaces and their objects by name and columns the way DESCRIBE does.
+// A user type follows the types it uses, so that the DDL can be replayed.
+func SortSchema(keyspaces []KeyspaceSchema) {
+	sort.Slice(keyspaces, func(i, j int) bool { return keyspaces[i].Name < keyspaces[j].Name })
+	for k := range keyspaces {
+		keyspace := &keyspaces[k]
+		sort.Slice(keyspace.Types, func(i, j int) bool { return keyspace.Types[i].Name < keyspace.Types[j].Name })
+		keyspace.Types = sortTypeDependencies(keyspace.Types)
+		sort.Slice(keyspace.Tables, func(i, j int) bool { return keyspace.Tables[i].Name < keyspace.Tables[j].Name })
+		for t := range keyspace.Tables {
+			table := &keyspace.Tables[t]
+			sort.SliceStable(table.Columns, func(i, j int) bool { return columnLess(table.Columns[i], table.Columns[j]) })
+			sort.Slice(table.Indexes, func(i, j int) bool { return table.Indexes[i].Name < table.Indexes[j].Name })
+		}
+	}
+}
+
+var typeIdentifier = regexp.MustCompile(`"(?:[^"]|"")*"|[A-Za-z_][A-Za-z0-9_]*`)
+
+// typeReferences returns names of the types the CQL type refers to, e.g. `address` of `list<frozen<address>>`
+func typeReferences(cqlType string) []string {
+	var names []string
+	for _, token := range typeIdentifier.FindAllString(cqlType, -1) {
+		if strings.HasPrefix(token, `"`) {
+			token = strings.ReplaceAll(token[1:len(token)-1], `""`, `"`)
+		}
+		names = append(names, token)
+	}
+	return names
+}
+
+// sortTypeDependencies moves every type after the types its fields use, otherwise keeping the order
+func sortTypeDependencies(types []TypeSchema) []TypeSchema {
+	byName := map[string]TypeSchema{}
+	for _, udt := range types {
+		byName[udt.Name] = udt
+	}
+	sorted := make([]TypeSchema, 0, len(types))
+	visited := map[string]bool{}
+	var visit func(udt TypeSchema)
+	visit = func(udt TypeSchema) {
+		// Cassandra rejects cyclic types, so the visited mark only skips types already placed
+		if visited[udt.Name] {
+			return
+		}
+		visited[udt.Name] = true
+		for _, field := range udt.Fields {
+			for _, name := range typeReferences(field.Type) {
+				if dependency, found := byName[name]; found {
+					visit(dependency)
+				}
+			}
+		}
+		sorted = append(sorted, udt)
+	}
+	for _, udt := range types {
+		visit(udt)
+	}
+	return sorted
+}
+
+var columnKindOrder = map[string]int{ColumnPartitionKey: 0, ColumnClustering: 1}
+
+func columnLess(a, b ColumnSchema) bool {
+	aOrder, aKey := columnKindOrder[a.Kind]
+	bOrder, bKey := columnKindOrder[b.Kind]
+	switch {
+	case aKey && bKey && aOrder != bOrder:
+		return aOrder < bOrder
+	case aKey && bKey:
+		return a.Position < b.Position
+	case aKey != bKey:
+		return aKey
+	default:
+		return a.Name < b.Name
+	}
+}
+
+func keyColumns(table TableSchema, kind string) []ColumnSchema {
+	var columns []ColumnSchema
+	for _, column := range table.Columns {
+		if column.Kind == kind {
+			columns = append(columns, column)
+		}
+	}
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i].Position < columns[j].Position })
+	return columns
+}
+
+func primaryKey(table TableSchema) string {
+	var partition, clustering []string
+	for _, column := range keyColumns(table, ColumnPartitionKey) {
+		partition = append(partition, quoteIdentifier(column.Name))
+	}
+	for _, column := range keyColumns(table, ColumnClustering) {
+		clustering = append(clustering, quoteIdentifier(column.Name))
+	}
+	key := "(" + strings.Join(partition, ", ") + ")"
+	if len(clustering) > 0 {
+		key += ", " + strings.Join(clustering, ", ")
+	}
+	return "PRIMARY KEY (" + key + ")"
+}
+
+func tableOptions(table TableSchema) []string {
+	var options []string
+	var order []string
+	for _, column := range keyColumns(table, ColumnClustering) {
+		order = append(order, fmt.Sprintf("%s %s", quoteIdentifier(column.Name), strings.ToUpper(column.ClusteringOrder)))
+	}
+	if len(order) > 0 {
+		options = append(options, "CLUSTERING ORDER BY ("+strings.Join(order, ", ")+")")
+	}
+	for _, name := range sortedKeys(table.Options) {
+		options = append(options, fmt.Sprintf("%s = %s", name, table.Options[name]))
+	}
+	return options
+}
+
+// RenderSchema renders DESCRIBE-equivalent DDL of the keyspaces
+func RenderSchema(keyspaces []KeyspaceSchema) string {
+	var ddl strings.Builder
+	for _, keyspace := range keyspaces {
+		name := quoteIdentifier(keyspace.Name)
+		fmt.Fprintf(&ddl, "CREATE KEYSPACE %s WITH replication = %s AND durable_writes = %t;\n\n",
+			name, CQLMap(keyspace.Replication), keyspace.DurableWrites)
+
+		for _, udt := range keyspace.Types {
+			fields := make([]string, len(udt.Fields))
+			for i, field := range udt.Fields {
+				fields[i] = fmt.Sprintf("    %s %s", quoteIdentifier(field.Name), field.Type)
+			}
+			fmt.Fprintf(&ddl, "CREATE TYPE %s.%s (\n%s\n);\n\n", name, quoteIdentifier(udt.Name), strings.Join(fields, ",\n"))
+		}
+
+		for _, table := range keyspace.Tables {
+			if table.View != nil {
+				continue
+			}
+			var lines []string
+			for _, column := range table.Columns {
+				line := fmt.Sprintf("    %s %s", quoteIdentifier(column.Name), column.Type)
+				if column.Kind == ColumnStatic {
+					line += " static"
+				}
+				lines = append(lines, line)
+			}
+			lines = append(lines, "    "+primaryKey(table))
+			fmt.Fprintf(&ddl, "CREATE TABLE %s.%s (\n%s\n)", name, quoteIdentifier(table.Name), strings.Join(lines, ",\n"))
+			if options := tableOptions(table); len(options) > 0 {
+				fmt.Fprintf(&ddl, " WITH %s", strings.Join(options, "\n    AND "))
+			}
+			ddl.WriteString(";\n\n")
+
+			for _, index := range table.Indexes {
+				target := index.Options["target"]
+				if index.Kind == "CUSTOM" {
+					fmt.Fprintf(&ddl, "CREATE CUSTOM INDEX %s ON %s.%s (%s) USING %s;\n\n", quoteIdentifier(index.Name),
+						name, quoteIdentifier(table.Name), target, quoteString(index.Options["class_name"]))
+					continue
+				}
+				fmt.Fprintf(&ddl, "CREATE INDEX %s ON %s.%s (%s);\n\n", quoteIdentifier(index.Name), name, quoteIdentifier(table.Name), target)
+			}
+		}
+
+		// views go after their base tables
+		for _, view := range keyspace.Tables {
+			if view.View == nil {
+				continue
+			}
+			columns := "*"
+			if !view.View.IncludeAllColumns {
+				var names []string
+				for _, column := range view.Columns {
+					names = append(names, quoteIdentifier(column.Name))
+				}
+				columns = strings.Join(names, ", ")
+			}
+			fmt.Fprintf(&ddl, "CREATE MATERIALIZED VIEW %s.%s AS\n    SELECT %s\n    FROM %s.%s\n    WHERE %s\n    %s",
+				name, quoteIdentifier(view.Name), columns, name, quoteIdentifier(view.View.BaseTable), view.View.WhereClause, primaryKey(view))
+			if options := tableOptions(view); len(options) > 0 {
+				fmt.Fprintf(&ddl, "\n WITH %s", strings.Join(options, "\n    AND "))
+			}
+			ddl.WriteString(";\n\n")
+		}
+	}
+	return ddl.String()
+}
+
+// DiffSchema returns readable changes of the current schema against the previous one
+func DiffSchema(previous, current []KeyspaceSchema) []string {
+	var changes []string
+	before := map[string]KeyspaceSchema{}
+	for _, keyspace := range previous {
+		before[keyspace.Name] = keyspace
+	}
+	after := map[string]KeyspaceSchema{}
+	for _, keyspace := range current {
+		after[keyspace.Name] = keyspace
+	}
+
+	for _, name := range sortedKeys(before) {
+		if _, found := after[name]; !found {
+			changes = append(changes, fmt.Sprintf("keyspace %s dropped", name))
+		}
+	}
+	for _, name := range sortedKeys(after) {
+		keyspace := after[name]
+		old, found := before[name]
+		if !found {
+			changes = append(changes, fmt.Sprintf("keyspace %s created", name))
+			continue
+		}
+		if CQLMap(old.Replication) != CQLMap(keyspace.Replication) {
+			changes = append(changes, fmt.Sprintf("keyspace %s: replication changed from %s to %s", name, CQLMap(old.Replication), CQLMap(keyspace.Replication)))
+		}
+		if old.DurableWrites != keyspace.DurableWrites {
+			changes = append(changes, fmt.Sprintf("keyspace %s: durable_writes changed from %t to %t", name, old.DurableWrites, keyspace.DurableWrites))
+		}
+		changes = append(changes, diffTypes(name, old.Types, keyspace.Types)...)
+		changes = append(changes, diffTables(name, old.Tables, keyspace.Tables)...)
+	}
+	return changes
+}
+
+func diffTypes(keyspace string, previous, current []TypeSchema) []string {
+	var changes []string
+	before := map[string]TypeSchema{}
+	for _, udt := range previous {
+		before[udt.Name] = udt
+	}
+	after := map[string]TypeSchema{}
+	for _, udt := range current {
+		after[udt.Name] = udt
+	}
+	for _, name := range sortedKeys(before) {
+		if _, found := after[name]; !found {
+			changes = append(changes, fmt.Sprintf("type %s.%s dropped", keyspace, name))
+		}
+	}
+	for _, name := range sortedKeys(after) {
+		old, found := before[name]
+		if !found {
+			changes = append(changes, fmt.Sprintf("type %s.%s created", keyspace, name))
+			continue
+		}
+		changes = append(changes, diffColumns(fmt.Sprintf("type %s.%s", keyspace, name), "field", old.Fields, after[name].Fields)...)
+	}
+	return changes
+}
+
+func diffTables(keyspace string, previous, current []TableSchema) []string {
+	var changes []string
+	before := map[string]TableSchema{}
+	for _, table := range previous {
+		before[table.Name] = table
+	}
+	after := map[string]TableSchema{}
+	for _, table := range current {
+		after[table.Name] = table
+	}
+	for _, name := range sortedKeys(before) {
+		if _, found := after[name]; !found {
+			changes = append(changes, fmt.Sprintf("%s %s.%s dropped", tableKind(before[name]), keyspace, name))
+		}
+	}
+	for _, name := range sortedKeys(after) {
+		table := after[name]
+		subject := fmt.Sprintf("%s %s.%s", tableKind(table), keyspace, name)
+		old, found := before[name]
+		if !found {
+			changes = append(changes, subject+" created")
+			continue
+		}
+		if primaryKey(old) != primaryKey(table) {
+			changes = append(changes, fmt.Sprintf("%s: primary key changed from %s to %s", subject, primaryKey(old), primaryKey(table)))
+		}
+		changes = append(changes, diffColumns(subject, "column", old.Columns, table.Columns)...)
+		for _, option := range sortedKeys(table.Options) {
+			if value, found := old.Options[option]; !found || value != table.Options[option] {
+				changes = append(changes, fmt.Sprintf("%s: %s changed from %s to %s", subject, option, optionValue(value), table.Options[option]))
+			}
+		}
+
+		oldIndexes := map[string]bool{}
+		for _, index := range old.Indexes {
+			oldIndexes[index.Name] = true
+		}
+		newIndexes := map[string]bool{}
+		for _, index := range table.Indexes {
+			newIndexes[index.Name] = true
+			if !oldIndexes[index.Name] {
+				changes = append(changes, fmt.Sprintf("%s: index %s created", subject, index.Name))
+			}
+		}
+		for _, index := range old.Indexes {
+			if !newIndexes[index.Name] {
+				changes = append(changes, fmt.Sprintf("%s: index %s dropped", subject, index.Name))
+			}
+		}
+	}
+	return changes
+}
+
+func tableKind(table TableSchema) string {
+	if table.View != nil {
+		return "materialized view"
+	}
+	return "table"
+}
+
+// optionValue renders an absent option
+func optionValue(value string) string {
+	if value == "" {
+		return "unset"
+	}
+	return value
+}
+
+func diffColumns(subject, kind string, previous, current []ColumnSchema) []string {
+	var changes []string
+	before := map[string]ColumnSchema{}
+	for _, column := range previous {
+		before[column.Name] = column
+	}
+	after := map[string]bool{}
+	for _, column := range current {
+		after[column.Name] = true
+		old, found := before[column.Name]
+		switch {
+		case !found:
+			changes = append(changes, fmt.Sprintf("%s: %s %s %s added", subject, kind, column.Name, column.Type))
+		case old.Type != column.Type:
+			changes = append(changes, fmt.Sprintf("%s: %s %s type changed from %s to %s", subject, kind, column.Name, old.Type, column.Type))
+		}
+	}
+	for _, column := range previous {
+		if !after[column.Name] {
+			changes = append(changes, fmt.Sprintf("%s: %s %s dropped", subject, kind, column.Name))
+		}
+	}
+	return changes
+}
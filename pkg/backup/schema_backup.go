@@ -0,0 +1,456 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-cql-driver"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"github.com/gocql/gocql"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultSchemaBackupInterval = time.Hour
+	defaultSchemaKeepVersions   = 10
+	// the number of changes listed in the status and events
+	maxReportedSchemaChanges = 50
+)
+
+// `backupDaemon.schema.target` values
+const (
+	SchemaTargetConfigMap     = "ConfigMap"
+	SchemaTargetBackupStorage = "BackupStorage"
+)
+
+// files of a schema version
+const (
+	schemaDDLFile     = "schema.cql"
+	schemaJSONFile    = "schema.json"
+	schemaChangesFile = "changes.txt"
+)
+
+// SchemaReader reads the schema of non-system keyspaces
+type SchemaReader interface {
+	ReadSchema(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) ([]KeyspaceSchema, error)
+}
+
+// CQLSchemaReader reads system_schema tables with the Cassandra admin credentials
+type CQLSchemaReader struct{}
+
+func (r *CQLSchemaReader) ReadSchema(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) ([]KeyspaceSchema, error) {
+	cluster, err := utils.NewCassandraCluster(kubeClient, spec, namespace, "system_schema", gocql.LocalOne)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyspaces []KeyspaceSchema
+	err = cql.ExecInAutoCloseSession(cluster, func(session cql.Session) error {
+		var readErr error
+		keyspaces, readErr = readSchema(session)
+		return readErr
+	})
+	return keyspaces, err
+}
+
+func readSchema(session cql.Session) ([]KeyspaceSchema, error) {
+	keyspaces := map[string]*KeyspaceSchema{}
+	tables := map[string]*TableSchema{}
+	var tableKeys []string
+	tableKey := func(keyspace, table string) string { return keyspace + "." + table }
+
+	var keyspaceName, name string
+	var durableWrites bool
+	var replication map[string]string
+	iter := session.Query("SELECT keyspace_name, durable_writes, replication FROM system_schema.keyspaces").Iter()
+	for iter.Scan(&keyspaceName, &durableWrites, &replication) {
+		if !IsSystemKeyspace(keyspaceName) {
+			keyspaces[keyspaceName] = &KeyspaceSchema{Name: keyspaceName, DurableWrites: durableWrites, Replication: replication}
+		}
+		replication = nil
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read keyspaces: %w", err)
+	}
+
+	var fieldNames, fieldTypes []string
+	iter = session.Query("SELECT keyspace_name, type_name, field_names, field_types FROM system_schema.types").Iter()
+	for iter.Scan(&keyspaceName, &name, &fieldNames, &fieldTypes) {
+		if keyspace, found := keyspaces[keyspaceName]; found {
+			udt := TypeSchema{Name: name}
+			for i := range fieldNames {
+				udt.Fields = append(udt.Fields, ColumnSchema{Name: fieldNames[i], Type: fieldTypes[i]})
+			}
+			keyspace.Types = append(keyspace.Types, udt)
+		}
+		fieldNames, fieldTypes = nil, nil
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read types: %w", err)
+	}
+
+	var comment string
+	var ttl, gcGrace int
+	var compaction, compression, caching map[string]string
+	options := func() map[string]string {
+		return map[string]string{
+			"comment":              quoteString(comment),
+			"default_time_to_live": fmt.Sprint(ttl),
+			"gc_grace_seconds":     fmt.Sprint(gcGrace),
+			"compaction":           CQLMap(compaction),
+			"compression":          CQLMap(compression),
+			"caching":              CQLMap(caching),
+		}
+	}
+	iter = session.Query("SELECT keyspace_name, table_name, comment, default_time_to_live, gc_grace_seconds, compaction, compression, caching FROM system_schema.tables").Iter()
+	for iter.Scan(&keyspaceName, &name, &comment, &ttl, &gcGrace, &compaction, &compression, &caching) {
+		if _, found := keyspaces[keyspaceName]; found {
+			tables[tableKey(keyspaceName, name)] = &TableSchema{Name: name, Options: options()}
+			tableKeys = append(tableKeys, tableKey(keyspaceName, name))
+		}
+		compaction, compression, caching = nil, nil, nil
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+
+	var baseTable, whereClause string
+	var includeAllColumns bool
+	iter = session.Query("SELECT keyspace_name, view_name, base_table_name, where_clause, include_all_columns, comment, default_time_to_live, gc_grace_seconds, compaction, compression, caching FROM system_schema.views").Iter()
+	for iter.Scan(&keyspaceName, &name, &baseTable, &whereClause, &includeAllColumns, &comment, &ttl, &gcGrace, &compaction, &compression, &caching) {
+		if _, found := keyspaces[keyspaceName]; found {
+			tables[tableKey(keyspaceName, name)] = &TableSchema{
+				Name:    name,
+				Options: options(),
+				View:    &ViewSchema{BaseTable: baseTable, WhereClause: whereClause, IncludeAllColumns: includeAllColumns},
+			}
+			tableKeys = append(tableKeys, tableKey(keyspaceName, name))
+		}
+		compaction, compression, caching = nil, nil, nil
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read materialized views: %w", err)
+	}
+
+	var tableName, columnType, kind, clusteringOrder string
+	var position int
+	iter = session.Query("SELECT keyspace_name, table_name, column_name, type, kind, position, clustering_order FROM system_schema.columns").Iter()
+	for iter.Scan(&keyspaceName, &tableName, &name, &columnType, &kind, &position, &clusteringOrder) {
+		if table, found := tables[tableKey(keyspaceName, tableName)]; found {
+			table.Columns = append(table.Columns, ColumnSchema{Name: name, Type: columnType, Kind: kind, Position: position, ClusteringOrder: clusteringOrder})
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	var indexOptions map[string]string
+	iter = session.Query("SELECT keyspace_name, table_name, index_name, kind, options FROM system_schema.indexes").Iter()
+	for iter.Scan(&keyspaceName, &tableName, &name, &kind, &indexOptions) {
+		if table, found := tables[tableKey(keyspaceName, tableName)]; found {
+			table.Indexes = append(table.Indexes, IndexSchema{Name: name, Kind: kind, Options: indexOptions})
+		}
+		indexOptions = nil
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+
+	for _, key := range tableKeys {
+		keyspaceName := strings.SplitN(key, ".", 2)[0]
+		keyspaces[keyspaceName].Tables = append(keyspaces[keyspaceName].Tables, *tables[key])
+	}
+	var result []KeyspaceSchema
+	for _, keyspace := range keyspaces {
+		result = append(result, *keyspace)
+	}
+	SortSchema(result)
+	return result, nil
+}
+
+// SchemaStore keeps schema versions, versions are sortable timestamps
+type SchemaStore interface {
+	Versions() ([]string, error)
+	Load(version string) ([]KeyspaceSchema, error)
+	Save(version string, files map[string]string) error
+	Delete(version string) error
+	Location(version string) string
+}
+
+// configMapSchemaStore keeps every version in its own ConfigMap owned by the CR
+type configMapSchemaStore struct {
+	client client.Client
+	spec   *v1.CassandraSupplService
+}
+
+func schemaConfigMapName(version string) string {
+	return fmt.Sprintf("%s-%s", utils.SchemaBackup, version)
+}
+
+func (s *configMapSchemaStore) Versions() ([]string, error) {
+	list := &v12.ConfigMapList{}
+	err := s.client.List(context.TODO(), list, client.InNamespace(s.spec.Namespace), client.MatchingLabels{utils.Name: utils.SchemaBackup})
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, cm := range list.Items {
+		versions = append(versions, cm.Labels[utils.SchemaVersionLabel])
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+func (s *configMapSchemaStore) Load(version string) ([]KeyspaceSchema, error) {
+	cm := &v12.ConfigMap{}
+	if err := s.client.Get(context.TODO(), client.ObjectKey{Namespace: s.spec.Namespace, Name: schemaConfigMapName(version)}, cm); err != nil {
+		return nil, err
+	}
+	var keyspaces []KeyspaceSchema
+	err := json.Unmarshal([]byte(cm.Data[schemaJSONFile]), &keyspaces)
+	return keyspaces, err
+}
+
+func (s *configMapSchemaStore) Save(version string, files map[string]string) error {
+	cm := &v12.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.spec.Namespace,
+			Name:      schemaConfigMapName(version),
+			Labels: map[string]string{
+				utils.Name:               utils.SchemaBackup,
+				utils.SchemaVersionLabel: version,
+			},
+		},
+		Data: files,
+	}
+	if err := controllerutil.SetControllerReference(s.spec, cm, s.client.Scheme()); err != nil {
+		return err
+	}
+	return s.client.Create(context.TODO(), cm)
+}
+
+func (s *configMapSchemaStore) Delete(version string) error {
+	cm := &v12.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: s.spec.Namespace, Name: schemaConfigMapName(version)}}
+	return client.IgnoreNotFound(s.client.Delete(context.TODO(), cm))
+}
+
+func (s *configMapSchemaStore) Location(version string) string {
+	return "configmap:" + schemaConfigMapName(version)
+}
+
+// objectSchemaStore keeps versions as `schema/<version>/` files of the backup storage
+type objectSchemaStore struct {
+	store ObjectStore
+}
+
+const schemaPrefix = "schema/"
+
+func (s *objectSchemaStore) Versions() ([]string, error) {
+	keys, err := s.store.List(schemaPrefix)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	for _, key := range keys {
+		if parts := strings.SplitN(strings.TrimPrefix(key, schemaPrefix), "/", 2); len(parts) == 2 {
+			found[parts[0]] = true
+		}
+	}
+	return sortedKeys(found), nil
+}
+
+func (s *objectSchemaStore) Load(version string) ([]KeyspaceSchema, error) {
+	data, err := s.store.Get(schemaPrefix + version + "/" + schemaJSONFile)
+	if err != nil {
+		return nil, err
+	}
+	var keyspaces []KeyspaceSchema
+	err = json.Unmarshal(data, &keyspaces)
+	return keyspaces, err
+}
+
+func (s *objectSchemaStore) Save(version string, files map[string]string) error {
+	// the JSON file goes last, it marks the version complete for Load
+	for _, name := range []string{schemaDDLFile, schemaChangesFile, schemaJSONFile} {
+		if err := s.store.Put(schemaPrefix+version+"/"+name, []byte(files[name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *objectSchemaStore) Delete(version string) error {
+	keys, err := s.store.List(schemaPrefix + version + "/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *objectSchemaStore) Location(version string) string {
+	return "backup-storage:" + schemaPrefix + version + "/"
+}
+
+// SchemaBackuper periodically exports DDL of non-system keyspaces and stores a new version when the schema changes
+type SchemaBackuper struct {
+	Client       client.Client
+	Reader       SchemaReader
+	StoreBuilder ObjectStoreBuilder
+	Recorder     record.EventRecorder
+}
+
+// Run exports the schema when it is due, reports changes against the previous version in events and `status.backup.schema`.
+// It returns the interval after which it should be called again, zero if schema backups are disabled.
+func (r *SchemaBackuper) Run(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+
+	schema := spec.Spec.Backup.Schema
+	if !schema.Enabled || spec.Spec.AWSKeyspaces.Install {
+		return 0, nil
+	}
+	interval, err := utils.ParseDurationOrDefault(schema.Interval, defaultSchemaBackupInterval)
+	if err != nil {
+		return defaultSchemaBackupInterval, fmt.Errorf("invalid backupDaemon.schema.interval: %w", err)
+	}
+
+	now := time.Now()
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	status := spec.Status.Backup.Schema
+	if status == nil {
+		status = &v1.SchemaBackupStatus{}
+	}
+	if status.LastExportTime != nil {
+		if due := status.LastExportTime.Add(interval).Sub(now); due > 0 {
+			return due, nil
+		}
+	}
+
+	exportErr := r.export(spec, request.Namespace, status, now, log)
+	status.LastExportTime = &metav1.Time{Time: now}
+	status.Result, status.Message = BackupResultSuccessful, ""
+	if exportErr != nil {
+		status.Result, status.Message = BackupResultFailed, exportErr.Error()
+	}
+	spec.Status.Backup.Schema = status
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return interval, fmt.Errorf("failed to update schema backup status: %w", err)
+	}
+	return interval, exportErr
+}
+
+func (r *SchemaBackuper) schemaStore(spec *v1.CassandraSupplService, namespace string) (SchemaStore, error) {
+	switch target := core.OptionalString(spec.Spec.Backup.Schema.Target, SchemaTargetConfigMap); target {
+	case SchemaTargetConfigMap:
+		return &configMapSchemaStore{client: r.Client, spec: spec}, nil
+	case SchemaTargetBackupStorage:
+		if !spec.Spec.Backup.Install {
+			return nil, fmt.Errorf("backupDaemon.schema.target %s requires backupDaemon.install", target)
+		}
+		store, err := r.StoreBuilder.Build(r.Client, spec, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access backup storage: %w", err)
+		}
+		return &objectSchemaStore{store: store}, nil
+	default:
+		return nil, fmt.Errorf("invalid backupDaemon.schema.target %q", target)
+	}
+}
+
+func (r *SchemaBackuper) export(spec *v1.CassandraSupplService, namespace string, status *v1.SchemaBackupStatus, now time.Time, log *zap.Logger) error {
+	store, err := r.schemaStore(spec, namespace)
+	if err != nil {
+		return err
+	}
+	keyspaces, err := r.Reader.ReadSchema(r.Client, spec, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+	versions, err := store.Versions()
+	if err != nil {
+		return fmt.Errorf("failed to list schema versions: %w", err)
+	}
+	status.Keyspaces = len(keyspaces)
+
+	var changes []string
+	if len(versions) > 0 {
+		previous, err := store.Load(versions[len(versions)-1])
+		if err != nil {
+			return fmt.Errorf("failed to load schema version %s: %w", versions[len(versions)-1], err)
+		}
+		if changes = DiffSchema(previous, keyspaces); len(changes) == 0 {
+			log.Debug("Schema has not changed since version " + versions[len(versions)-1])
+			status.Versions = versions
+			return nil
+		}
+	}
+
+	version := now.UTC().Format("20060102-150405")
+	data, err := json.Marshal(keyspaces)
+	if err != nil {
+		return err
+	}
+	files := map[string]string{
+		schemaDDLFile:     RenderSchema(keyspaces),
+		schemaJSONFile:    string(data),
+		schemaChangesFile: strings.Join(changes, "\n"),
+	}
+	if err := store.Save(version, files); err != nil {
+		return fmt.Errorf("failed to store schema version %s: %w", version, err)
+	}
+	log.Info(fmt.Sprintf("Schema version %s is stored to %s", version, store.Location(version)))
+	versions = append(versions, version)
+
+	status.LastVersion = version
+	status.Location = store.Location(version)
+	status.Changes = reportedChanges(changes)
+	if len(versions) == 1 {
+		r.Recorder.Eventf(spec, v12.EventTypeNormal, "SchemaExported", "Initial schema version %s with %d keyspaces is stored to %s",
+			version, len(keyspaces), status.Location)
+	} else {
+		r.Recorder.Eventf(spec, v12.EventTypeNormal, "SchemaChanged", "Schema version %s: %s",
+			version, strings.Join(status.Changes, "; "))
+	}
+
+	keep := spec.Spec.Backup.Schema.KeepVersions
+	if keep == 0 {
+		keep = defaultSchemaKeepVersions
+	}
+	for len(versions) > keep {
+		if err := store.Delete(versions[0]); err != nil {
+			return fmt.Errorf("failed to remove schema version %s: %w", versions[0], err)
+		}
+		versions = versions[1:]
+	}
+	status.Versions = versions
+	return nil
+}
+
+func reportedChanges(changes []string) []string {
+	if len(changes) <= maxReportedSchemaChanges {
+		return changes
+	}
+	reported := append([]string{}, changes[:maxReportedSchemaChanges]...)
+	return append(reported, fmt.Sprintf("... and %d more changes", len(changes)-maxReportedSchemaChanges))
+}
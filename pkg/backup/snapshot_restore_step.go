@@ -0,0 +1,56 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SnapshotRestoreStep provisions Cassandra data PVCs from `backupDaemon.snapshots.restoreFromGroup`
+type SnapshotRestoreStep struct {
+	core.DefaultExecutable
+}
+
+func (r *SnapshotRestoreStep) Condition(ctx core.ExecutionContext) (bool, error) {
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	group := spec.Spec.Backup.Snapshots.RestoreFromGroup
+	if group == "" {
+		return false, nil
+	}
+	if status := spec.Status.Backup; status != nil && status.Snapshots != nil && status.Snapshots.Restore != nil {
+		restore := status.Snapshots.Restore
+		return restore.Group != group || restore.Result != BackupResultSuccessful, nil
+	}
+	return true, nil
+}
+
+func (r *SnapshotRestoreStep) Execute(ctx core.ExecutionContext) error {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+
+	group := spec.Spec.Backup.Snapshots.RestoreFromGroup
+	log.Info(fmt.Sprintf("Restoring Cassandra PVCs from snapshot group %s", group))
+
+	// the status is committed together with the reconcile result
+	restore := &v1.SnapshotOperationStatus{Group: group, Time: &metav1.Time{Time: time.Now()}}
+	snapshotStatus(spec).Restore = restore
+
+	err := RestoreSnapshotGroup(kubeClient, request.Namespace, group, log)
+	if err != nil {
+		restore.Result = BackupResultFailed
+		restore.Message = err.Error()
+	}
+	core.PanicError(err, log.Error, fmt.Sprintf("Restore from snapshot group %s failed", group))
+
+	restore.Result = BackupResultSuccessful
+	return nil
+}
@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/Netcracker/qubership-cql-driver"
 	"github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-cql-driver"
 
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
@@ -36,9 +36,6 @@ func (r *BackupSSHKeyStep) Execute(ctx core.ExecutionContext) error {
 	tries := core.MaxInt(ctx.Get(utils.TriesCount).(int), 1)
 	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
 
-	secret, secErr := core.ReadSecret(client, spec.Spec.Cassandra.SecretName, request.Namespace)
-	core.PanicError(secErr, log.Error, fmt.Sprintf("Failed to read secret %s", spec.Spec.Cassandra.SecretName))
-
 	log.Info("SSH Key Step for Backup started")
 
 	var dcToReplicasReplication []string
@@ -52,18 +49,27 @@ func (r *BackupSSHKeyStep) Execute(ctx core.ExecutionContext) error {
 	}
 	var replication string = strings.Join(dcToReplicasReplication[:], ",")
 
-	pass := string(secret.Data[utils.Password])
-	var vaultErr error
-	if spec.Spec.VaultRegistration.Enabled {
-		vaultHelper := ctx.Get(constants.ContextVault).(vault.VaultHelper)
-		pass, vaultErr = vaultHelper.ResolvePassword(pass)
-		if vaultErr != nil {
-			return vaultErr
+	// in the password-less mode Cassandra authenticates the operator by the client certificate
+	var user, pass string
+	if !utils.CassandraPasswordless(spec.Spec) {
+		secret, secErr := core.ReadSecret(client, spec.Spec.Cassandra.SecretName, request.Namespace)
+		core.PanicError(secErr, log.Error, fmt.Sprintf("Failed to read secret %s", spec.Spec.Cassandra.SecretName))
+		user, pass = string(secret.Data[utils.Username]), string(secret.Data[utils.Password])
+		var vaultErr error
+		if spec.Spec.VaultRegistration.Enabled {
+			vaultHelper := ctx.Get(constants.ContextVault).(vault.VaultHelper)
+			pass, vaultErr = vaultHelper.ResolvePassword(pass)
+			if vaultErr != nil {
+				return vaultErr
+			}
 		}
 	}
 
+	clusterBuilder, certErr := utils.WithCassandraClientCertificate(clusterBuilder, client, spec.Spec, request.Namespace)
+	core.PanicError(certErr, log.Error, "Failed to set up Cassandra client certificate")
+
 	cluster := clusterBuilder.WithHost(core.OptionalString(spec.Spec.Cassandra.Host, fmt.Sprintf("%s.%s", utils.Cassandra, request.Namespace))).
-		WithUser(string(secret.Data[utils.Username])).
+		WithUser(user).
 		WithPassword(func() string { return pass }).
 		WithRootCertPath(utils.RootCertPath + spec.Spec.TLS.RootCAFileName).
 		WithTLSEnabled(spec.Spec.TLS.Enabled).
@@ -0,0 +1,216 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/types"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultStatusCollectionInterval = 5 * time.Minute
+	defaultBackupStaleThreshold     = 25 * time.Hour
+)
+
+// status.backup condition types
+const (
+	BackupOutdated          = "BackupOutdated"
+	BackupDaemonUnavailable = "BackupDaemonUnavailable"
+	ReplicationLagging      = "ReplicationLagging"
+)
+
+const (
+	BackupResultSuccessful = "Successful"
+	BackupResultFailed     = "Failed"
+	BackupResultInProgress = "In Progress"
+)
+
+// StatusCollector periodically stores the backup daemon state in `status.backup`
+type StatusCollector struct {
+	Client        client.Client
+	ClientBuilder DaemonClientBuilder
+}
+
+// Collect queries the backup daemon and updates the CR status.
+// It returns the interval after which the collection should be repeated, zero if the collection is disabled.
+func (r *StatusCollector) Collect(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+
+	collector := spec.Spec.Backup.StatusCollector
+	if !spec.Spec.Backup.Install || !collector.Enabled {
+		return 0, nil
+	}
+
+	interval, err := utils.ParseDurationOrDefault(collector.Interval, defaultStatusCollectionInterval)
+	if err != nil {
+		return defaultStatusCollectionInterval, fmt.Errorf("invalid backupDaemon.statusCollector.interval: %w", err)
+	}
+	threshold, err := utils.ParseDurationOrDefault(collector.StaleThreshold, defaultBackupStaleThreshold)
+	if err != nil {
+		return interval, fmt.Errorf("invalid backupDaemon.statusCollector.staleThreshold: %w", err)
+	}
+
+	now := time.Now()
+	status := spec.Status.Backup
+	if status == nil {
+		status = &v1.BackupStatus{}
+	}
+	if status.LastCollectionTime != nil {
+		if due := status.LastCollectionTime.Add(interval).Sub(now); due > 0 {
+			return due, nil
+		}
+	}
+	previous := status.DeepCopy()
+
+	var newestSuccessful *time.Time
+	daemonClient, err := r.ClientBuilder.Build(r.Client, spec, request.Namespace)
+	var backups []*DaemonBackup
+	if err == nil {
+		backups, err = listDaemonBackups(daemonClient)
+	}
+	if err == nil && spec.Spec.Backup.Catalog.Enabled {
+		backups, err = r.syncCatalog(ctx, spec, daemonClient, backups, status, now, log)
+	}
+	if err == nil {
+		newestSuccessful, err = fillBackupStatus(daemonClient, backups, status)
+	}
+
+	if err != nil {
+		log.Warn(fmt.Sprintf("Failed to collect backup daemon status: %v", err))
+		status.Conditions = utils.SetCondition(status.Conditions,
+			newCondition(BackupDaemonUnavailable, true, "BackupDaemonRequestFailed", err.Error(), now))
+	} else {
+		status.LastCollectionTime = &metav1.Time{Time: now}
+		status.Conditions = utils.SetCondition(status.Conditions,
+			newCondition(BackupDaemonUnavailable, false, "BackupDaemonResponded", "", now))
+		status.Conditions = utils.SetCondition(status.Conditions, staleCondition(newestSuccessful, threshold, now))
+	}
+
+	// every status write triggers a reconcile, so an unchanged status is not written
+	if equality.Semantic.DeepEqual(previous, status) {
+		return interval, nil
+	}
+	spec.Status.Backup = status
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return interval, fmt.Errorf("failed to update backup status: %w", err)
+	}
+
+	return interval, nil
+}
+
+func listDaemonBackups(daemonClient DaemonClient) ([]*DaemonBackup, error) {
+	ids, err := daemonClient.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	var backups []*DaemonBackup
+	for _, id := range ids {
+		backup, err := daemonClient.GetBackup(id)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+func fillBackupStatus(daemonClient DaemonClient, backups []*DaemonBackup, status *v1.BackupStatus) (*time.Time, error) {
+	health, err := daemonClient.Health()
+	if err != nil {
+		return nil, err
+	}
+	status.Storage = &v1.BackupStorageStatus{
+		TotalBytes: health.Storage.TotalSpace,
+		FreeBytes:  health.Storage.FreeSpace,
+		UsedBytes:  health.Storage.Size,
+	}
+	status.PlainHTTPClients = health.PlainHTTPClients
+
+	var lastFull *DaemonBackup
+	var newestSuccessful *time.Time
+	lastGranular := map[string]*DaemonBackup{}
+	for _, backup := range backups {
+		if backupResult(backup) == BackupResultSuccessful {
+			if ts := backup.Time(); newestSuccessful == nil || ts.After(*newestSuccessful) {
+				newestSuccessful = &ts
+			}
+		}
+
+		if backup.IsGranular {
+			for _, keyspace := range backup.Keyspaces() {
+				if current := lastGranular[keyspace]; current == nil || backup.Ts > current.Ts {
+					lastGranular[keyspace] = backup
+				}
+			}
+		} else if lastFull == nil || backup.Ts > lastFull.Ts {
+			lastFull = backup
+		}
+	}
+
+	status.RetainedBackups = len(backups)
+	status.LastFullBackup = nil
+	if lastFull != nil {
+		info := backupInfo(lastFull)
+		status.LastFullBackup = &info
+	}
+	status.LastGranularBackups = nil
+	if len(lastGranular) > 0 {
+		status.LastGranularBackups = map[string]v1.BackupInfo{}
+		for keyspace, backup := range lastGranular {
+			status.LastGranularBackups[keyspace] = backupInfo(backup)
+		}
+	}
+
+	return newestSuccessful, nil
+}
+
+func backupResult(backup *DaemonBackup) string {
+	switch {
+	case backup.Locked:
+		return BackupResultInProgress
+	case backup.Failed:
+		return BackupResultFailed
+	default:
+		return BackupResultSuccessful
+	}
+}
+
+func backupInfo(backup *DaemonBackup) v1.BackupInfo {
+	return v1.BackupInfo{
+		Id:     backup.Id,
+		Time:   &metav1.Time{Time: backup.Time()},
+		Result: backupResult(backup),
+	}
+}
+
+func staleCondition(newestSuccessful *time.Time, threshold time.Duration, now time.Time) types.ServiceStatusCondition {
+	if newestSuccessful == nil {
+		return newCondition(BackupOutdated, true, "NoSuccessfulBackups", "backup daemon has no successful backups", now)
+	}
+	if age := now.Sub(*newestSuccessful); age > threshold {
+		return newCondition(BackupOutdated, true, "BackupOutdated",
+			fmt.Sprintf("the newest successful backup was made %s ago, the threshold is %s", age.Round(time.Minute), threshold), now)
+	}
+	return newCondition(BackupOutdated, false, "BackupUpToDate", "", now)
+}
+
+func newCondition(conditionType string, status bool, reason string, message string, now time.Time) types.ServiceStatusCondition {
+	return types.ServiceStatusCondition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Time{Time: now},
+	}
+}
@@ -78,7 +78,7 @@ func BackupDeploymentTemplate(spec *v1nc.CassandraSupplService, namespace string
 					},
 				},
 				Spec: v1.PodSpec{
-					ServiceAccountName: spec.Spec.ServiceAccountName,
+					ServiceAccountName: utils.ServiceAccountName(spec.Spec, utils.BackupDaemon),
 					SecurityContext:    spec.Spec.PodSecurityContext,
 					PriorityClassName:  spec.Spec.Backup.PriorityClassName,
 					Containers:         containers,
@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// the period schedules are checked against blackout windows, long enough for monthly schedules
+const blackoutValidationPeriod = 31 * 24 * time.Hour
+
+// ThrottlingConfig is mounted to the backup daemon as `throttling.json`
+type ThrottlingConfig struct {
+	BandwidthLimitBytesPerSecond int64                `json:"bandwidth_limit_bytes_per_second,omitempty"`
+	MaxConcurrentNodesPerDC      int                  `json:"max_concurrent_nodes_per_dc,omitempty"`
+	BlackoutWindows              []ThrottlingBlackout `json:"blackout_windows,omitempty"`
+}
+
+type ThrottlingBlackout struct {
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	TimeZone string   `json:"timezone"`
+}
+
+// NewThrottlingConfig validates throttling settings of the backup spec and converts them to the daemon config
+func NewThrottlingConfig(backup v1.Backup, now time.Time) (*ThrottlingConfig, error) {
+	config := &ThrottlingConfig{MaxConcurrentNodesPerDC: backup.MaxConcurrentNodesPerDC}
+	if backup.MaxConcurrentNodesPerDC < 0 {
+		return nil, fmt.Errorf("backupDaemon.maxConcurrentNodesPerDC must not be negative")
+	}
+	if backup.BandwidthLimit != "" {
+		limit, err := resource.ParseQuantity(backup.BandwidthLimit)
+		if err != nil || limit.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid backupDaemon.bandwidthLimit %q, expected a positive quantity, e.g. 50Mi", backup.BandwidthLimit)
+		}
+		config.BandwidthLimitBytesPerSecond = limit.Value()
+	}
+
+	var windows []*utils.BlackoutWindow
+	for i, spec := range backup.BlackoutWindows {
+		timeZone := core.OptionalString(spec.TimeZone, "UTC")
+		window, err := utils.ParseBlackoutWindow(spec.Days, spec.Start, spec.End, timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backupDaemon.blackoutWindows[%d]: %w", i, err)
+		}
+		windows = append(windows, window)
+		config.BlackoutWindows = append(config.BlackoutWindows, ThrottlingBlackout{Days: spec.Days, Start: spec.Start, End: spec.End, TimeZone: timeZone})
+	}
+	if len(windows) == 0 {
+		return config, nil
+	}
+
+	var schedule *utils.CronSchedule
+	if backup.BackupSchedule != "" && backup.BackupSchedule != "None" {
+		var err error
+		if schedule, err = utils.ParseCronSchedule(backup.BackupSchedule); err != nil {
+			return nil, fmt.Errorf("invalid backupDaemon.backupSchedule: %w", err)
+		}
+	}
+
+	scheduled, deferred := 0, 0
+	start := now.UTC().Truncate(time.Minute)
+	for t := start; t.Before(start.Add(blackoutValidationPeriod)); t = t.Add(time.Minute) {
+		inWindow := -1
+		for i, window := range windows {
+			if !window.Contains(t) {
+				continue
+			}
+			if inWindow >= 0 {
+				return nil, fmt.Errorf("backupDaemon.blackoutWindows[%d] and backupDaemon.blackoutWindows[%d] overlap at %s", inWindow, i, t.Format(time.RFC3339))
+			}
+			inWindow = i
+		}
+		// the daemon runs schedules in UTC
+		if schedule != nil && schedule.Matches(t) {
+			scheduled++
+			if inWindow >= 0 {
+				deferred++
+			}
+		}
+	}
+	if scheduled > 0 && scheduled == deferred {
+		return nil, fmt.Errorf("every run of backupDaemon.backupSchedule %q falls into backupDaemon.blackoutWindows", backup.BackupSchedule)
+	}
+	return config, nil
+}
+
+// BackupThrottlingStep renders the backup daemon throttling config map
+type BackupThrottlingStep struct {
+	core.DefaultExecutable
+}
+
+func (r *BackupThrottlingStep) Validate(ctx core.ExecutionContext) error {
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	_, err := NewThrottlingConfig(spec.Spec.Backup, time.Now())
+	return err
+}
+
+func (r *BackupThrottlingStep) Execute(ctx core.ExecutionContext) error {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+
+	config, err := NewThrottlingConfig(spec.Spec.Backup, time.Now())
+	core.PanicError(err, log.Error, "Backup throttling configuration is invalid")
+	data, err := json.Marshal(config)
+	core.PanicError(err, log.Error, "Backup throttling configuration serialization failed")
+
+	cm := &v12.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: request.Namespace,
+			Name:      utils.BackupThrottlingConfig,
+		},
+		Data: map[string]string{
+			utils.BackupThrottlingConfigFile: string(data),
+		},
+	}
+	err = utils.CreateRuntimeObjectContextWrapper(ctx, cm, cm.ObjectMeta, utils.BasicLabels{})
+	core.PanicError(err, log.Error, "Backup throttling config map creation failed")
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	coreUtils "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/utils"
+	batchv1 "k8s.io/api/batch/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	defaultTransferImage  = "rclone/rclone:1.68.2"
+	transferCertsPath     = "/s3Certs"
+	transferBackoffLimit  = 2
+	transferContainerName = "transfer"
+)
+
+// transfer job states
+const (
+	transferRunning   = "Running"
+	transferSucceeded = "Succeeded"
+	transferFailed    = "Failed"
+)
+
+// transferRemote is an S3 bucket the transfer job accesses as the rclone remote of the same name,
+// or a PVC mounted to the job at `/<name>` if S3 is not enabled
+type transferRemote struct {
+	Name    string
+	S3      v1.S3backup
+	PVCName string
+}
+
+// path returns the rclone path of the key in the bucket or on the volume
+func (r transferRemote) path(key string) string {
+	if !r.S3.Enabled {
+		return fmt.Sprintf("/%s/%s", r.Name, key)
+	}
+	return fmt.Sprintf("%s:%s/%s", r.Name, r.S3.BucketName, key)
+}
+
+// transferJob copies backup files with rclone next to the data, so that the files never go through the operator.
+// ReadWriteOnce volumes are shared with the pod already running on the node.
+type transferJob struct {
+	Name    string
+	Script  string
+	Remotes []transferRemote
+	Volumes []v12.Volume
+	Mounts  []v12.VolumeMount
+	// the node of the pod that mounts the volumes
+	NodeName string
+	// the security context of the pod that owns the files, the backup daemon one is used if nil
+	SecurityContext *v12.PodSecurityContext
+	Annotations     map[string]string
+}
+
+func (t *transferJob) build(spec *v1.CassandraSupplService, namespace string) *batchv1.Job {
+	var backoffLimit int32 = transferBackoffLimit
+	labels := map[string]string{
+		utils.Name:         t.Name,
+		utils.AppName:      utils.BackupTransferJob,
+		utils.AppComponent: "backend",
+		utils.AppManagedBy: spec.Spec.ManagedBy,
+	}
+
+	envs := []v12.EnvVar{coreUtils.GetPlainTextEnvVar("RCLONE_CACHE_DIR", "/tmp/rclone")}
+	volumes, mounts := t.Volumes, t.Mounts
+	var caCerts []string
+	insecure := false
+	for _, remote := range t.Remotes {
+		if !remote.S3.Enabled {
+			volumes = append(volumes, v12.Volume{
+				Name:         remote.Name,
+				VolumeSource: v12.VolumeSource{PersistentVolumeClaim: &v12.PersistentVolumeClaimVolumeSource{ClaimName: remote.PVCName}},
+			})
+			mounts = append(mounts, v12.VolumeMount{Name: remote.Name, MountPath: "/" + remote.Name})
+			continue
+		}
+		prefix := fmt.Sprintf("RCLONE_CONFIG_%s_", strings.ToUpper(remote.Name))
+		envs = append(envs,
+			coreUtils.GetPlainTextEnvVar(prefix+"TYPE", "s3"),
+			coreUtils.GetPlainTextEnvVar(prefix+"PROVIDER", "Other"),
+			coreUtils.GetPlainTextEnvVar(prefix+"ENDPOINT", remote.S3.EndpointUrl),
+			coreUtils.GetPlainTextEnvVar(prefix+"REGION", s3DefaultRegion),
+			coreUtils.GetPlainTextEnvVar(prefix+"FORCE_PATH_STYLE", "true"),
+			coreUtils.GetSecretEnvVar(prefix+"ACCESS_KEY_ID", remote.S3.SecretName, utils.Username),
+			coreUtils.GetSecretEnvVar(prefix+"SECRET_ACCESS_KEY", remote.S3.SecretName, utils.Password),
+		)
+		if !remote.S3.SslVerify {
+			insecure = true
+			continue
+		}
+		if remote.S3.SslSecretName != "" {
+			volumeName := remote.Name + "-ssl-certs"
+			volumes = append(volumes, v12.Volume{
+				Name:         volumeName,
+				VolumeSource: v12.VolumeSource{Secret: &v12.SecretVolumeSource{SecretName: remote.S3.SslSecretName}},
+			})
+			mounts = append(mounts, v12.VolumeMount{Name: volumeName, MountPath: path.Join(transferCertsPath, remote.Name), ReadOnly: true})
+			caCerts = append(caCerts, path.Join(transferCertsPath, remote.Name, "ca.crt"))
+		}
+	}
+	if insecure {
+		envs = append(envs, coreUtils.GetPlainTextEnvVar("RCLONE_NO_CHECK_CERTIFICATE", "true"))
+	}
+	if len(caCerts) > 0 {
+		envs = append(envs, coreUtils.GetPlainTextEnvVar("RCLONE_CA_CERT", strings.Join(caCerts, ",")))
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: t.Name, Namespace: namespace, Labels: labels, Annotations: t.Annotations},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v12.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v12.PodSpec{
+					RestartPolicy:      v12.RestartPolicyNever,
+					ServiceAccountName: utils.ServiceAccountName(spec.Spec, utils.BackupTransferJob),
+					NodeName:           t.NodeName,
+					Containers: []v12.Container{
+						{
+							Name:            transferContainerName,
+							Image:           core.OptionalString(spec.Spec.Backup.TransferImage, defaultTransferImage),
+							ImagePullPolicy: spec.Spec.ImagePullPolicy,
+							Command:         []string{"sh", "-c", t.Script},
+							Env:             envs,
+							VolumeMounts:    mounts,
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+	utils.SecurityContextSpecUpdate(&job.Spec.Template.Spec, spec.Spec, utils.BackupTransferJob)
+	if t.SecurityContext != nil {
+		// the files are written with the owner of the volume
+		job.Spec.Template.Spec.SecurityContext = t.SecurityContext
+	}
+	return job
+}
+
+// startTransferJob creates the job owned by the CR
+func startTransferJob(kubeClient client.Client, spec *v1.CassandraSupplService, job *batchv1.Job) error {
+	if err := controllerutil.SetControllerReference(spec, job, kubeClient.Scheme()); err != nil {
+		return err
+	}
+	if err := kubeClient.Create(context.TODO(), job); err != nil {
+		return fmt.Errorf("failed to create job %s: %w", job.Name, err)
+	}
+	return nil
+}
+
+// checkTransferJob returns the job with its state and the failure message, nil if the job does not exist.
+// A finished job is removed, so that the next transfer starts from scratch.
+func checkTransferJob(kubeClient client.Client, namespace, name string) (*batchv1.Job, string, string, error) {
+	job := &batchv1.Job{}
+	err := kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: name}, job)
+	if errors.IsNotFound(err) {
+		return nil, "", "", nil
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	state, message := transferRunning, ""
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == v12.ConditionTrue {
+			state, message = transferFailed, condition.Message
+		}
+	}
+	if state == transferRunning && job.Status.Succeeded > 0 {
+		state = transferSucceeded
+	}
+	if state != transferRunning {
+		if err := kubeClient.Delete(context.TODO(), job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return job, state, message, err
+		}
+	}
+	return job, state, message, nil
+}
+
+// runTransferJobs starts the jobs and waits for all of them. A running job left by an interrupted run is waited for,
+// a finished one is replaced.
+func runTransferJobs(kubeClient client.Client, spec *v1.CassandraSupplService, jobs []*batchv1.Job, timeout, pollInterval time.Duration) error {
+	for _, job := range jobs {
+		existing, state, _, err := checkTransferJob(kubeClient, job.Namespace, job.Name)
+		if err != nil {
+			return err
+		}
+		if existing == nil || state != transferRunning {
+			if err := startTransferJob(kubeClient, spec, job); err != nil {
+				return err
+			}
+		}
+	}
+
+	var failures []string
+	pending := jobs
+	err := wait.PollUntilContextTimeout(context.TODO(), pollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		var running []*batchv1.Job
+		for _, job := range pending {
+			existing, state, message, err := checkTransferJob(kubeClient, job.Namespace, job.Name)
+			switch {
+			case err != nil:
+				return false, err
+			case existing == nil:
+				failures = append(failures, fmt.Sprintf("job %s is removed", job.Name))
+			case state == transferFailed:
+				failures = append(failures, fmt.Sprintf("job %s failed: %s", job.Name, message))
+			case state == transferRunning:
+				running = append(running, job)
+			}
+		}
+		pending = running
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("transfer jobs are not completed: %w", err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// podVolumeMount returns the persistent volume of the pod the directory is on and its mount,
+// a transfer job mounts it at the same path
+func podVolumeMount(pod v12.Pod, dir string) (v12.Volume, v12.VolumeMount, error) {
+	var mount *v12.VolumeMount
+	if len(pod.Spec.Containers) > 0 {
+		for i, candidate := range pod.Spec.Containers[0].VolumeMounts {
+			mountPath := strings.TrimSuffix(candidate.MountPath, "/")
+			if (dir == mountPath || strings.HasPrefix(dir, mountPath+"/")) && (mount == nil || len(mountPath) > len(mount.MountPath)) {
+				mount = &pod.Spec.Containers[0].VolumeMounts[i]
+			}
+		}
+	}
+	if mount != nil {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Name == mount.Name && volume.PersistentVolumeClaim != nil {
+				return volume, v12.VolumeMount{Name: mount.Name, MountPath: mount.MountPath, SubPath: mount.SubPath}, nil
+			}
+		}
+	}
+	return v12.Volume{}, v12.VolumeMount{}, fmt.Errorf("%s of '%s' pod is not on a persistent volume", dir, pod.Name)
+}
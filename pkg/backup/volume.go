@@ -0,0 +1,394 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/steps"
+	coreUtils "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/utils"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// BackupVolume expands the backup daemon PVC and migrates it to another storage class
+type BackupVolume struct {
+	Client client.Client
+	Spec   *v1.CassandraSupplService
+	// set if PVCs are removed together with the CR
+	Owner metav1.Object
+	Log   *zap.Logger
+	// the copy job is failed by Kubernetes when it runs longer
+	Timeout time.Duration
+}
+
+// BackupVolumePollInterval is how often a running backup volume copy is checked
+const BackupVolumePollInterval = 10 * time.Second
+
+// ActiveBackupPVC returns the PVC mounted to the backup daemon, nil if it is not created yet
+func ActiveBackupPVC(kubeClient client.Client, namespace string) (*v12.PersistentVolumeClaim, error) {
+	pvcList := &v12.PersistentVolumeClaimList{}
+	err := kubeClient.List(context.TODO(), pvcList, client.InNamespace(namespace), client.MatchingLabels{utils.Name: utils.BackupDaemon})
+	if err != nil || len(pvcList.Items) == 0 {
+		return nil, err
+	}
+	// an interrupted migration may leave both PVCs labeled, the target one wins
+	migratedFrom := map[string]bool{}
+	for _, pvc := range pvcList.Items {
+		migratedFrom[pvc.Annotations[utils.MigratedFromAnnotation]] = true
+	}
+	for i := range pvcList.Items {
+		if !migratedFrom[pvcList.Items[i].Name] {
+			return &pvcList.Items[i], nil
+		}
+	}
+	return &pvcList.Items[0], nil
+}
+
+// Reconcile brings the active backup PVC to the requested storage class and size and returns it
+func (r *BackupVolume) Reconcile(namespace string) (*v12.PersistentVolumeClaim, error) {
+	pvc, err := ActiveBackupPVC(r.Client, namespace)
+	if err != nil || pvc == nil {
+		return pvc, err
+	}
+	storage := r.Spec.Spec.Backup.Storage
+
+	status := backupVolumeStatus(r.Spec)
+	status.Message = ""
+	if len(storage.StorageClasses) > 0 && storage.StorageClasses[0] != storageClassName(pvc) {
+		migration := status.Migration
+		// a migration in progress is continued, its time is kept for the status
+		if migration == nil || migration.Result != BackupResultInProgress || migration.From != pvc.Name {
+			migration = &v1.BackupVolumeMigrationStatus{
+				From:         pvc.Name,
+				To:           nextBackupPVCName(pvc.Name),
+				StorageClass: storage.StorageClasses[0],
+				Time:         &metav1.Time{Time: time.Now()},
+			}
+			status.Migration = migration
+		}
+		target, err := r.migrate(pvc, migration.To)
+		if err != nil {
+			migration.Result = BackupResultFailed
+			migration.Message = err.Error()
+			return nil, err
+		}
+		if target == nil {
+			// the daemon is switched by the reconcile that finds the copy completed
+			migration.Result = BackupResultInProgress
+			return nil, nil
+		}
+		pvc = target
+		migration.Result = BackupResultSuccessful
+	} else if status.Migration != nil && status.Migration.Result == BackupResultInProgress {
+		// the storage class is reverted while copying, the daemon stays on the source volume
+		if err := r.cancelCopy(namespace); err != nil {
+			return nil, err
+		}
+		status.Migration.Result = BackupResultFailed
+		status.Migration.Message = "the migration is cancelled, the storage class is changed back"
+	}
+
+	if len(storage.Size) > 0 {
+		if err := r.expand(pvc, storage.Size[0]); err != nil {
+			// expansion is retried on the next reconcile, the daemon keeps working on the current volume
+			r.Log.Warn(fmt.Sprintf("Backup volume %s is not expanded: %v", pvc.Name, err))
+			status.Message = err.Error()
+		}
+		status.RequestedSize = storage.Size[0]
+	}
+
+	status.PVCName = pvc.Name
+	status.StorageClass = storageClassName(pvc)
+	status.Capacity = ""
+	if capacity, found := pvc.Status.Capacity[v12.ResourceStorage]; found {
+		status.Capacity = capacity.String()
+	}
+	return pvc, nil
+}
+
+// expand requests the new size, the API server rejects it if the storage class does not allow volume expansion
+func (r *BackupVolume) expand(pvc *v12.PersistentVolumeClaim, size string) error {
+	requested, err := resource.ParseQuantity(size)
+	if err != nil {
+		return fmt.Errorf("invalid backupDaemon.storage.size %q: %w", size, err)
+	}
+	current := pvc.Spec.Resources.Requests[v12.ResourceStorage]
+	switch requested.Cmp(current) {
+	case 0:
+		return nil
+	case -1:
+		return fmt.Errorf("backup volume can not be shrunk from %s to %s", current.String(), requested.String())
+	}
+
+	r.Log.Info(fmt.Sprintf("Expanding backup volume %s from %s to %s", pvc.Name, current.String(), requested.String()))
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = v12.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[v12.ResourceStorage] = requested
+	if err := r.Client.Update(context.TODO(), pvc); err != nil {
+		if errors.IsForbidden(err) || errors.IsInvalid(err) {
+			return fmt.Errorf("storage class %q does not allow volume expansion: %w", storageClassName(pvc), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// migrate copies the backups to a new PVC of the requested storage class and switches the backup daemon to it.
+// It returns nil while the copy job is running, the daemon is kept stopped until then.
+// The source PVC is retained with the `netcracker.com/migrated-to` annotation and should be removed manually.
+func (r *BackupVolume) migrate(source *v12.PersistentVolumeClaim, targetName string) (*v12.PersistentVolumeClaim, error) {
+	namespace := source.Namespace
+	storage := *r.Spec.Spec.Backup.Storage
+	// the target volume is provisioned by the storage class
+	storage.Volumes, storage.MatchLabelSelectors = nil, nil
+	r.Log.Info(fmt.Sprintf("Migrating backup volume %s to %s of storage class %s", source.Name, targetName, storage.StorageClasses[0]))
+
+	target := &v12.PersistentVolumeClaim{}
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: targetName}, target)
+	if errors.IsNotFound(err) {
+		target = coreUtils.PVCTemplate(storage, 0, targetName, nil, namespace, BackupVolumeAccessMode(r.Spec))
+		target.Annotations[utils.MigratedFromAnnotation] = source.Name
+		// the target must hold all the backups of the source
+		if sourceSize, found := source.Spec.Resources.Requests[v12.ResourceStorage]; found && sourceSize.Cmp(target.Spec.Resources.Requests[v12.ResourceStorage]) > 0 {
+			target.Spec.Resources.Requests[v12.ResourceStorage] = sourceSize
+		}
+		if r.Owner != nil {
+			if err := controllerutil.SetControllerReference(r.Owner, target, r.Client.Scheme()); err != nil {
+				return nil, err
+			}
+		}
+		err = r.Client.Create(context.TODO(), target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PVC %s: %w", targetName, err)
+	}
+
+	// the volume must be released by the backup daemon, the deployment is recreated later by the reconcile
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: utils.BackupDaemon}}
+	if err := r.Client.Delete(context.TODO(), deployment, client.PropagationPolicy(metav1.DeletePropagationForeground)); client.IgnoreNotFound(err) != nil {
+		return nil, fmt.Errorf("failed to stop the backup daemon: %w", err)
+	}
+
+	if copied, err := r.copyData(source.Name, target.Name, namespace); err != nil || !copied {
+		return nil, err
+	}
+
+	// label the target first, an interrupted switch is completed by ActiveBackupPVC
+	if target.Labels == nil {
+		target.Labels = map[string]string{}
+	}
+	target.Labels[utils.Name] = utils.BackupDaemon
+	if err := r.Client.Update(context.TODO(), target); err != nil {
+		return nil, fmt.Errorf("failed to switch to PVC %s: %w", target.Name, err)
+	}
+	delete(source.Labels, utils.Name)
+	if source.Annotations == nil {
+		source.Annotations = map[string]string{}
+	}
+	source.Annotations[utils.MigratedToAnnotation] = target.Name
+	if err := r.Client.Update(context.TODO(), source); err != nil {
+		return nil, fmt.Errorf("failed to detach PVC %s: %w", source.Name, err)
+	}
+	r.Log.Info(fmt.Sprintf("Backup volume is migrated to %s, %s is retained", target.Name, source.Name))
+	return target, nil
+}
+
+// copyData starts the copy job or checks the running one and reports if the data is copied.
+// The job left by an interrupted migration is reused, the finished one is removed so that a failed copy is retried from scratch.
+func (r *BackupVolume) copyData(source, target, namespace string) (bool, error) {
+	existing, state, message, err := checkTransferJob(r.Client, namespace, utils.BackupVolumeMigrationJob)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the backup volume copy job: %w", err)
+	}
+	switch {
+	case existing == nil:
+		job := backupVolumeMigrationJob(r.Spec, namespace, source, target, r.Timeout)
+		if err = controllerutil.SetControllerReference(r.Spec, job, r.Client.Scheme()); err == nil {
+			err = r.Client.Create(context.TODO(), job)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to create the backup volume copy job: %w", err)
+		}
+		r.Log.Info(fmt.Sprintf("Backup volume copy job is started, %s is copied to %s", source, target))
+		return false, nil
+	case state == transferFailed:
+		return false, fmt.Errorf("backup volume copy job failed: %s", message)
+	case state == transferSucceeded:
+		return true, nil
+	}
+	r.Log.Info(fmt.Sprintf("Backup volume copy job is running, %s is copied to %s", source, target))
+	return false, nil
+}
+
+// cancelCopy removes the copy job of a migration that is no longer requested
+func (r *BackupVolume) cancelCopy(namespace string) error {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: utils.BackupVolumeMigrationJob}}
+	if err := r.Client.Delete(context.TODO(), job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to remove the backup volume copy job: %w", err)
+	}
+	return nil
+}
+
+func backupVolumeMigrationJob(spec *v1.CassandraSupplService, namespace, source, target string, timeout time.Duration) *batchv1.Job {
+	var backoffLimit int32 = 2
+	deadline := int64(timeout.Seconds())
+	allowPrivilegeEscalation := false
+	labels := map[string]string{
+		utils.Name:         utils.BackupVolumeMigrationJob,
+		utils.AppName:      utils.BackupDaemon,
+		utils.AppComponent: "backend",
+		utils.AppManagedBy: spec.Spec.ManagedBy,
+	}
+	volume := func(name, claim string) v12.Volume {
+		return v12.Volume{
+			Name:         name,
+			VolumeSource: v12.VolumeSource{PersistentVolumeClaim: &v12.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		}
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: utils.BackupVolumeMigrationJob, Namespace: namespace, Labels: labels},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: v12.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v12.PodSpec{
+					RestartPolicy:      v12.RestartPolicyNever,
+					ServiceAccountName: utils.ServiceAccountName(spec.Spec, utils.BackupVolumeMigrationJob),
+					SecurityContext:    spec.Spec.PodSecurityContext,
+					Containers: []v12.Container{
+						{
+							Name:            "copy",
+							Image:           spec.Spec.Backup.DockerImage,
+							ImagePullPolicy: spec.Spec.ImagePullPolicy,
+							Command:         []string{"sh", "-c", "cp -a /source/. /target/"},
+							SecurityContext: &v12.SecurityContext{
+								Capabilities:             &v12.Capabilities{Drop: []v12.Capability{"ALL"}},
+								AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+							},
+							VolumeMounts: []v12.VolumeMount{
+								{Name: "source", MountPath: "/source", ReadOnly: true},
+								{Name: "target", MountPath: "/target"},
+							},
+						},
+					},
+					Volumes: []v12.Volume{volume("source", source), volume("target", target)},
+				},
+			},
+		},
+	}
+	utils.SecurityContextSpecUpdate(&job.Spec.Template.Spec, spec.Spec, utils.BackupVolumeMigrationJob)
+	return job
+}
+
+func storageClassName(pvc *v12.PersistentVolumeClaim) string {
+	if pvc.Spec.StorageClassName != nil {
+		return *pvc.Spec.StorageClassName
+	}
+	return pvc.Annotations["volume.beta.kubernetes.io/storage-class"]
+}
+
+// nextBackupPVCName numbers migrated PVCs: backup-data-0, backup-data-0-1, backup-data-0-2, ...
+func nextBackupPVCName(current string) string {
+	base := fmt.Sprintf(utils.BackupPvcName, 0)
+	generation, err := strconv.Atoi(strings.TrimPrefix(current, base+"-"))
+	if err != nil {
+		generation = 0
+	}
+	return fmt.Sprintf("%s-%d", base, generation+1)
+}
+
+func backupVolumeStatus(spec *v1.CassandraSupplService) *v1.BackupVolumeStatus {
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	if spec.Status.Backup.Volume == nil {
+		spec.Status.Backup.Volume = &v1.BackupVolumeStatus{}
+	}
+	return spec.Status.Backup.Volume
+}
+
+// BackupVolumeStep expands and migrates the existing backup PVC before `CreatePVCStep` renders it.
+// The steps that deploy the backup daemon are added to BackupVolumeGate, they wait for the migration copy.
+type BackupVolumeStep struct {
+	core.DefaultExecutable
+	PVCStep *steps.CreatePVCStep
+	Timeout time.Duration
+}
+
+// BackupVolumeGate runs its steps when no backup volume migration is in progress
+type BackupVolumeGate struct {
+	core.DefaultCompound
+}
+
+func (r *BackupVolumeGate) Condition(ctx core.ExecutionContext) (bool, error) {
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	return !BackupVolumeMigrationPending(spec), nil
+}
+
+// BackupVolumeMigrationPending reports a backup volume copy the backup daemon waits for
+func BackupVolumeMigrationPending(spec *v1.CassandraSupplService) bool {
+	if !spec.Spec.Backup.Install || spec.Status.Backup == nil || spec.Status.Backup.Volume == nil {
+		return false
+	}
+	migration := spec.Status.Backup.Volume.Migration
+	return migration != nil && migration.Result == BackupResultInProgress
+}
+
+func (r *BackupVolumeStep) Validate(ctx core.ExecutionContext) error {
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	storage := spec.Spec.Backup.Storage
+	if storage != nil && len(storage.Size) > 0 {
+		if _, err := resource.ParseQuantity(storage.Size[0]); err != nil {
+			return fmt.Errorf("invalid backupDaemon.storage.size %q: %w", storage.Size[0], err)
+		}
+	}
+	return nil
+}
+
+func (r *BackupVolumeStep) Execute(ctx core.ExecutionContext) error {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+
+	volume := &BackupVolume{
+		Client:  kubeClient,
+		Spec:    spec,
+		Log:     log,
+		Timeout: r.Timeout,
+	}
+	if spec.Spec.DeletePVConUninstall {
+		volume.Owner = spec
+	}
+
+	pvc, err := volume.Reconcile(request.Namespace)
+	core.PanicError(err, log.Error, "Backup volume processing failed")
+
+	// the PVC step keeps rendering the active PVC after a migration
+	if pvc != nil {
+		r.PVCStep.NameFormat = pvc.Name
+	}
+	if BackupVolumeMigrationPending(spec) {
+		// the next reconcile checks the copy job again
+		return core.DeleteSpecConfigMap(ctx)
+	}
+	return nil
+}
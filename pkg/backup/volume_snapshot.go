@@ -0,0 +1,441 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const defaultSnapshotInterval = 24 * time.Hour
+
+const snapshotRetryInterval = 10 * time.Minute
+
+// snapshotPollInterval is how often a new group is checked until all its snapshots are ready to use
+const snapshotPollInterval = 30 * time.Second
+
+const snapshotReadyTimeout = time.Hour
+
+const snapshotGroupTimeFormat = "20060102-150405"
+
+var VolumeSnapshotGVK = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+// SnapshotGroup is a set of VolumeSnapshots of all Cassandra data PVCs made at the same time
+type SnapshotGroup struct {
+	Name      string
+	Time      time.Time
+	Snapshots []unstructured.Unstructured
+}
+
+// sourcePVC keeps the snapshotted PVC parameters required to provision it again
+type sourcePVC struct {
+	Name             string                           `json:"name"`
+	Labels           map[string]string                `json:"labels,omitempty"`
+	StorageClassName *string                          `json:"storageClassName,omitempty"`
+	AccessModes      []v12.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	Size             string                           `json:"size"`
+}
+
+// ListSnapshotGroups returns snapshot groups from the newest to the oldest
+func ListSnapshotGroups(kubeClient client.Client, namespace string) ([]SnapshotGroup, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(VolumeSnapshotGVK.GroupVersion().WithKind(VolumeSnapshotGVK.Kind + "List"))
+	if err := kubeClient.List(context.TODO(), list, client.InNamespace(namespace), client.HasLabels{utils.SnapshotGroupLabel}); err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*SnapshotGroup{}
+	for _, snapshot := range list.Items {
+		name := snapshot.GetLabels()[utils.SnapshotGroupLabel]
+		group, found := groups[name]
+		if !found {
+			group = &SnapshotGroup{Name: name, Time: snapshotGroupTime(name, snapshot)}
+			groups[name] = group
+		}
+		group.Snapshots = append(group.Snapshots, snapshot)
+	}
+
+	var result []SnapshotGroup
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Time.After(result[j].Time) })
+	return result, nil
+}
+
+// snapshotGroupTime returns the time encoded in the group name, the snapshot creation time for foreign groups
+func snapshotGroupTime(group string, snapshot unstructured.Unstructured) time.Time {
+	if groupTime, err := time.Parse(snapshotGroupTimeFormat, group); err == nil {
+		return groupTime
+	}
+	return snapshot.GetCreationTimestamp().Time
+}
+
+func newVolumeSnapshot(group string, pvc *v12.PersistentVolumeClaim, className string) (*unstructured.Unstructured, error) {
+	source, err := json.Marshal(sourcePVC{
+		Name:             pvc.Name,
+		Labels:           pvc.Labels,
+		StorageClassName: pvc.Spec.StorageClassName,
+		AccessModes:      pvc.Spec.AccessModes,
+		Size:             pvc.Spec.Resources.Requests.Storage().String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(VolumeSnapshotGVK)
+	snapshot.SetName(fmt.Sprintf("%s-%s", pvc.Name, group))
+	snapshot.SetNamespace(pvc.Namespace)
+	snapshot.SetLabels(map[string]string{
+		utils.SnapshotGroupLabel: group,
+		utils.AppManagedBy:       "cassandra-services",
+	})
+	snapshot.SetAnnotations(map[string]string{utils.SnapshotSourcePVCAnnotation: string(source)})
+
+	specSource := map[string]interface{}{"persistentVolumeClaimName": pvc.Name}
+	spec := map[string]interface{}{"source": specSource}
+	if className != "" {
+		spec["volumeSnapshotClassName"] = className
+	}
+	snapshot.Object["spec"] = spec
+	return snapshot, nil
+}
+
+// SnapshotBackuper periodically makes CSI VolumeSnapshots of Cassandra data volumes
+type SnapshotBackuper struct {
+	Client     client.Client
+	KubeConfig *rest.Config
+	Helper     core.KubernetesHelper
+}
+
+// Run creates a new snapshot group if the newest one is older than the interval and evicts old groups.
+// It returns the time after which it should be called again, zero if snapshots are disabled.
+func (r *SnapshotBackuper) Run(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+
+	snapshots := spec.Spec.Backup.Snapshots
+	if !spec.Spec.Backup.Install || !snapshots.Enabled {
+		return 0, nil
+	}
+
+	interval, err := utils.ParseDurationOrDefault(snapshots.Interval, defaultSnapshotInterval)
+	if err != nil {
+		return defaultSnapshotInterval, fmt.Errorf("invalid backupDaemon.snapshots.interval: %w", err)
+	}
+
+	groups, err := ListSnapshotGroups(r.Client, request.Namespace)
+	if err != nil {
+		return interval, fmt.Errorf("failed to list snapshot groups: %w", err)
+	}
+	now := time.Now()
+	status := snapshotStatus(spec)
+	if last := status.LastGroup; last != nil && last.Result == BackupResultInProgress {
+		return r.awaitGroup(ctx, spec, groups, interval, now, log)
+	}
+	if len(groups) > 0 && now.Sub(groups[0].Time) < interval {
+		return interval - now.Sub(groups[0].Time), nil
+	}
+
+	group := now.UTC().Format(snapshotGroupTimeFormat)
+	log.Info(fmt.Sprintf("Creating snapshot group %s", group))
+	status.LastGroup = &v1.SnapshotOperationStatus{Group: group, Time: &metav1.Time{Time: now}}
+	if err = r.createGroup(request.Namespace, group, snapshots.VolumeSnapshotClassName, log); err != nil {
+		r.failGroup(request.Namespace, status.LastGroup, err, log)
+		// do not wait for the whole interval to retry
+		interval = min(interval, snapshotRetryInterval)
+	} else {
+		// the group succeeds once every snapshot is ready to use
+		status.LastGroup.Result = BackupResultInProgress
+		interval = snapshotPollInterval
+		log.Info(fmt.Sprintf("Snapshot group %s is created, waiting for the snapshots to become ready", group))
+	}
+
+	retained, evictErr := r.evict(request.Namespace, spec.Spec.Backup.EvictionPolicy, LegalHolds(spec), now, log)
+	if evictErr != nil {
+		log.Error(fmt.Sprintf("Snapshot eviction failed: %v", evictErr))
+	} else {
+		status.RetainedGroups = retained
+	}
+
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return interval, fmt.Errorf("failed to update snapshot status: %w", err)
+	}
+	return interval, nil
+}
+
+// awaitGroup checks the snapshots of the last created group and completes the group
+// once all of them are ready to use or any of them has failed
+func (r *SnapshotBackuper) awaitGroup(ctx context.Context, spec *v1.CassandraSupplService, groups []SnapshotGroup, interval time.Duration, now time.Time, log *zap.Logger) (time.Duration, error) {
+	last := spec.Status.Backup.Snapshots.LastGroup
+	var snapshots []unstructured.Unstructured
+	for _, group := range groups {
+		if group.Name == last.Group {
+			snapshots = group.Snapshots
+		}
+	}
+
+	var failure error
+	ready := len(snapshots) > 0
+	if !ready {
+		failure = fmt.Errorf("snapshots of group %s are not found", last.Group)
+	}
+	for _, snapshot := range snapshots {
+		snapshotReady, message := snapshotState(snapshot)
+		if message != "" {
+			failure = fmt.Errorf("VolumeSnapshot %s failed: %s", snapshot.GetName(), message)
+			break
+		}
+		ready = ready && snapshotReady
+	}
+	if failure == nil && !ready {
+		if now.Sub(last.Time.Time) < snapshotReadyTimeout {
+			return snapshotPollInterval, nil
+		}
+		failure = fmt.Errorf("snapshots of group %s are not ready in %s", last.Group, snapshotReadyTimeout)
+	}
+
+	next := interval - now.Sub(last.Time.Time)
+	if failure != nil {
+		r.failGroup(spec.Namespace, last, failure, log)
+		next = snapshotRetryInterval
+	} else {
+		last.Result = BackupResultSuccessful
+		log.Info(fmt.Sprintf("Snapshot group %s is ready", last.Group))
+	}
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return snapshotPollInterval, fmt.Errorf("failed to update snapshot status: %w", err)
+	}
+	return max(next, snapshotPollInterval), nil
+}
+
+func (r *SnapshotBackuper) failGroup(namespace string, last *v1.SnapshotOperationStatus, err error, log *zap.Logger) {
+	last.Result = BackupResultFailed
+	last.Message = err.Error()
+	log.Error(fmt.Sprintf("Snapshot group %s failed: %v", last.Group, err))
+	if cleanupErr := r.deleteGroup(namespace, last.Group); cleanupErr != nil {
+		log.Error(fmt.Sprintf("Failed to remove incomplete snapshot group %s: %v", last.Group, cleanupErr))
+	}
+}
+
+// snapshotState returns if the VolumeSnapshot is ready to use and the error message the snapshot controller reported
+func snapshotState(snapshot unstructured.Unstructured) (bool, string) {
+	ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message")
+	if !found {
+		if _, failed, _ := unstructured.NestedMap(snapshot.Object, "status", "error"); failed {
+			message = "unknown error"
+		}
+	}
+	return ready, message
+}
+
+// createGroup flushes memtables on all Cassandra pods and snapshots their data PVCs
+func (r *SnapshotBackuper) createGroup(namespace, group, className string, log *zap.Logger) error {
+	pods, err := r.Helper.ListPods(namespace, map[string]string{utils.Service: utils.CassandraCluster})
+	if err != nil {
+		return err
+	}
+	if pods == nil || len(pods.Items) == 0 {
+		return fmt.Errorf("no Cassandra pods found")
+	}
+
+	for _, pod := range pods.Items {
+		if _, err := r.Helper.ExecRemote(log, r.KubeConfig, pod.Name, pod.Namespace, pod.Spec.Containers[0].Name,
+			"bash", []string{"nodetool flush"}); err != nil {
+			return fmt.Errorf("nodetool flush failed on %s pod: %w", pod.Name, err)
+		}
+		log.Debug(fmt.Sprintf("Memtables are flushed on %s pod", pod.Name))
+	}
+
+	created := 0
+	for _, pod := range pods.Items {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
+			}
+			pvc := &v12.PersistentVolumeClaim{}
+			if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: volume.PersistentVolumeClaim.ClaimName}, pvc); err != nil {
+				return err
+			}
+			snapshot, err := newVolumeSnapshot(group, pvc, className)
+			if err != nil {
+				return err
+			}
+			if err := r.Client.Create(context.TODO(), snapshot); err != nil {
+				return fmt.Errorf("failed to create VolumeSnapshot %s: %w", snapshot.GetName(), err)
+			}
+			created++
+		}
+	}
+	if created == 0 {
+		return fmt.Errorf("no data PVCs found on Cassandra pods")
+	}
+	return nil
+}
+
+// evict removes snapshot groups according to the eviction policy except ones under legal hold and returns the retained group names
+func (r *SnapshotBackuper) evict(namespace, policy string, holds map[string]bool, now time.Time, log *zap.Logger) ([]string, error) {
+	rules, err := utils.ParseEvictionPolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backupDaemon.evictionPolicy: %w", err)
+	}
+	groups, err := ListSnapshotGroups(r.Client, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var times []time.Time
+	for _, group := range groups {
+		times = append(times, group.Time)
+	}
+	evicted := map[int]bool{}
+	for _, index := range utils.EvictedItems(times, rules, now) {
+		evicted[index] = true
+	}
+
+	var retained []string
+	for index, group := range groups {
+		if !evicted[index] || holds[group.Name] {
+			retained = append(retained, group.Name)
+			continue
+		}
+		log.Info(fmt.Sprintf("Evicting snapshot group %s", group.Name))
+		if err := r.deleteSnapshots(group.Snapshots); err != nil {
+			return nil, err
+		}
+	}
+	return retained, nil
+}
+
+func (r *SnapshotBackuper) deleteGroup(namespace, name string) error {
+	groups, err := ListSnapshotGroups(r.Client, namespace)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if group.Name == name {
+			return r.deleteSnapshots(group.Snapshots)
+		}
+	}
+	return nil
+}
+
+func (r *SnapshotBackuper) deleteSnapshots(snapshots []unstructured.Unstructured) error {
+	for i := range snapshots {
+		if err := core.DeleteRuntimeObject(r.Client, &snapshots[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSnapshotGroup provisions the snapshotted PVCs from the group snapshots.
+// PVCs which were already provisioned from the group are skipped.
+func RestoreSnapshotGroup(kubeClient client.Client, namespace, group string, log *zap.Logger) error {
+	groups, err := ListSnapshotGroups(kubeClient, namespace)
+	if err != nil {
+		return err
+	}
+	var snapshots []unstructured.Unstructured
+	for _, g := range groups {
+		if g.Name == group {
+			snapshots = g.Snapshots
+		}
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("snapshot group %s is not found", group)
+	}
+
+	for _, snapshot := range snapshots {
+		ready, message := snapshotState(snapshot)
+		if message != "" {
+			return fmt.Errorf("VolumeSnapshot %s failed: %s", snapshot.GetName(), message)
+		}
+		if !ready {
+			return fmt.Errorf("VolumeSnapshot %s is not ready to use yet", snapshot.GetName())
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		source := sourcePVC{}
+		if err := json.Unmarshal([]byte(snapshot.GetAnnotations()[utils.SnapshotSourcePVCAnnotation]), &source); err != nil {
+			return fmt.Errorf("VolumeSnapshot %s has no valid %s annotation: %w", snapshot.GetName(), utils.SnapshotSourcePVCAnnotation, err)
+		}
+		size, err := resource.ParseQuantity(source.Size)
+		if err != nil {
+			return fmt.Errorf("VolumeSnapshot %s has invalid PVC size: %w", snapshot.GetName(), err)
+		}
+
+		existing := &v12.PersistentVolumeClaim{}
+		err = kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: source.Name}, existing)
+		if err == nil {
+			if existing.Spec.DataSource != nil && existing.Spec.DataSource.Name == snapshot.GetName() {
+				continue
+			}
+			return fmt.Errorf("PVC %s exists, scale Cassandra down and remove its PVCs before the restore", source.Name)
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		apiGroup := VolumeSnapshotGVK.Group
+		pvc := &v12.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      source.Name,
+				Namespace: namespace,
+				Labels:    source.Labels,
+			},
+			Spec: v12.PersistentVolumeClaimSpec{
+				StorageClassName: source.StorageClassName,
+				AccessModes:      source.AccessModes,
+				Resources: v12.VolumeResourceRequirements{
+					Requests: v12.ResourceList{v12.ResourceStorage: size},
+				},
+				DataSource: &v12.TypedLocalObjectReference{
+					APIGroup: &apiGroup,
+					Kind:     VolumeSnapshotGVK.Kind,
+					Name:     snapshot.GetName(),
+				},
+			},
+		}
+		if err := kubeClient.Create(context.TODO(), pvc); err != nil {
+			return fmt.Errorf("failed to create PVC %s: %w", pvc.Name, err)
+		}
+		log.Info(fmt.Sprintf("PVC %s is provisioned from VolumeSnapshot %s", pvc.Name, snapshot.GetName()))
+	}
+	return nil
+}
+
+func snapshotStatus(spec *v1.CassandraSupplService) *v1.SnapshotBackupStatus {
+	if spec.Status.Backup == nil {
+		spec.Status.Backup = &v1.BackupStatus{}
+	}
+	if spec.Status.Backup.Snapshots == nil {
+		spec.Status.Backup.Snapshots = &v1.SnapshotBackupStatus{}
+	}
+	return spec.Status.Backup.Snapshots
+}
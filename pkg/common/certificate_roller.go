@@ -0,0 +1,272 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	v13 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const certificateCheckInterval = 5 * time.Minute
+
+var defaultExpiryWarningThresholds = []string{"720h", "168h", "24h"}
+
+var certificateNotAfter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cassandra_services_tls_certificate_not_after_seconds",
+	Help: "Expiry time of the certificate in a TLS secret mounted into the component pods, in seconds since the epoch",
+}, []string{"namespace", "secret"})
+
+func init() {
+	metrics.Registry.MustRegister(certificateNotAfter)
+}
+
+// CertificateRoller checks the certificates mounted into the component pods and restarts the deployments
+// whose TLS secrets have changed, the components read the certificates on startup only.
+// Self-signed certificates and the PKCS12 keystores are renewed by the roller itself.
+type CertificateRoller struct {
+	Client   client.Client
+	Recorder record.EventRecorder
+}
+
+// Run returns the interval after which the certificates should be checked again, zero if none are mounted
+func (r *CertificateRoller) Run(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+
+	var errs []error
+	if spec.Spec.TLS.SelfSigned != nil {
+		written, err := EnsureSelfSignedCertificates(ctx, r.Client, spec, request.Namespace)
+		for _, secretName := range written {
+			log.Info(fmt.Sprintf("Self-signed certificate %s is renewed", secretName))
+		}
+		if err != nil {
+			r.Recorder.Event(spec, v12.EventTypeWarning, "CertificateNotRenewed", err.Error())
+			errs = append(errs, err)
+		}
+	}
+
+	if spec.Spec.TLS.Enabled {
+		written, err := EnsureKeystores(ctx, r.Client, spec, request.Namespace)
+		if written {
+			log.Info(fmt.Sprintf("Keystores secret %s is rebuilt", utils.KeystoresSecretName(spec.Spec.TLS)))
+		}
+		if err != nil {
+			r.Recorder.Event(spec, v12.EventTypeWarning, "KeystoresNotGenerated", err.Error())
+			errs = append(errs, err)
+		}
+	}
+
+	consumers := utils.TLSConsumers(spec.Spec)
+	if len(consumers) == 0 {
+		certificateNotAfter.DeletePartialMatch(prometheus.Labels{"namespace": request.Namespace})
+		if spec.Status.TLS != nil {
+			spec.Status.TLS = nil
+			if err := r.Client.Status().Update(ctx, spec); err != nil {
+				return 0, fmt.Errorf("failed to update TLS status: %w", err)
+			}
+		}
+		return 0, nil
+	}
+
+	changed := false
+	for _, deployment := range slices.Sorted(maps.Keys(consumers)) {
+		rolled, err := r.roll(ctx, spec, deployment, consumers[deployment], request.Namespace, log)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		changed = changed || rolled
+	}
+
+	after := certificateCheckInterval
+	secrets, deployments := certificateSecrets(consumers)
+	// a changed secret is checked at once, the others on the interval
+	if due := checkDue(spec.Status.TLS, secrets); due > 0 && !changed {
+		after = due
+	} else if err := r.check(ctx, spec, secrets, deployments, request.Namespace); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return after, fmt.Errorf("certificate check failed: %v", errs)
+	}
+	return after, nil
+}
+
+// certificateSecrets returns the secrets with a certificate and the deployments each of them is mounted into
+func certificateSecrets(consumers map[string][]utils.TLSSecret) (map[string]utils.TLSSecret, map[string][]string) {
+	secrets := map[string]utils.TLSSecret{}
+	deployments := map[string][]string{}
+	for deployment, consumed := range consumers {
+		for _, secret := range consumed {
+			// the keystores are built from the certificates listed anyway
+			if secret.CertificateKey == "" {
+				continue
+			}
+			secrets[secret.Name] = secret
+			deployments[secret.Name] = append(deployments[secret.Name], deployment)
+		}
+	}
+	return secrets, deployments
+}
+
+// checkDue returns the time left until the next certificate check, zero if the check is due
+// or the mounted secrets differ from the checked ones
+func checkDue(status *v1.TLSStatus, secrets map[string]utils.TLSSecret) time.Duration {
+	if status == nil || status.LastCheckTime == nil || len(status.Certificates) != len(secrets) {
+		return 0
+	}
+	for _, certificate := range status.Certificates {
+		if _, ok := secrets[certificate.SecretName]; !ok {
+			return 0
+		}
+	}
+	return max(status.LastCheckTime.Add(certificateCheckInterval).Sub(time.Now()), 0)
+}
+
+// roll restarts the deployment if its TLS secrets have changed and reports if it has
+func (r *CertificateRoller) roll(ctx context.Context, spec *v1.CassandraSupplService, name string, secrets []utils.TLSSecret, namespace string, log *zap.Logger) (bool, error) {
+	deployment := &v13.Deployment{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, deployment); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	hash, err := utils.TLSSecretsHash(r.Client, secrets, namespace)
+	if err != nil {
+		return false, err
+	}
+	if deployment.Spec.Template.Annotations[utils.CertificateHashAnnotation] == hash {
+		return false, nil
+	}
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[utils.CertificateHashAnnotation] = hash
+	if err := r.Client.Update(ctx, deployment); err != nil {
+		return false, fmt.Errorf("failed to restart %s: %w", name, err)
+	}
+	log.Info(fmt.Sprintf("TLS secrets of %s changed, pods are restarted", name))
+	r.Recorder.Event(spec, v12.EventTypeNormal, "CertificateRenewed",
+		fmt.Sprintf("TLS secrets of %s changed, pods are restarted", name))
+	return true, nil
+}
+
+// check exports the expiry of every mounted certificate and raises a Warning event
+// each time a certificate crosses one more expiry warning threshold
+func (r *CertificateRoller) check(ctx context.Context, spec *v1.CassandraSupplService, secrets map[string]utils.TLSSecret, deployments map[string][]string, namespace string) error {
+	thresholds, err := expiryWarningThresholds(spec.Spec.TLS)
+	if err != nil {
+		return err
+	}
+	previous := map[string]v1.CertificateStatus{}
+	if spec.Status.TLS != nil {
+		for _, certificate := range spec.Status.TLS.Certificates {
+			previous[certificate.SecretName] = certificate
+		}
+	}
+
+	certificateNotAfter.DeletePartialMatch(prometheus.Labels{"namespace": namespace})
+	now := time.Now()
+	status := &v1.TLSStatus{LastCheckTime: &metav1.Time{Time: now}}
+	for _, name := range slices.Sorted(maps.Keys(secrets)) {
+		certificate := v1.CertificateStatus{SecretName: name, Deployments: slices.Sorted(slices.Values(deployments[name]))}
+		notAfter, subject, err := r.readCertificate(ctx, secrets[name], namespace)
+		if err != nil {
+			certificate.Message = err.Error()
+			status.Certificates = append(status.Certificates, certificate)
+			continue
+		}
+		certificate.Subject = subject
+		certificate.NotAfter = &metav1.Time{Time: notAfter}
+		certificateNotAfter.WithLabelValues(namespace, name).Set(float64(notAfter.Unix()))
+
+		remaining := notAfter.Sub(now)
+		crossed, warned := crossedThreshold(remaining, thresholds)
+		if warned {
+			certificate.WarnedThreshold = crossed.String()
+			last, lastErr := time.ParseDuration(previous[name].WarnedThreshold)
+			if previous[name].WarnedThreshold == "" || lastErr != nil || crossed < last {
+				r.warn(spec, certificate, remaining)
+			}
+		}
+		status.Certificates = append(status.Certificates, certificate)
+	}
+
+	// a secret rewritten with the same certificate leaves the status as it is until the check is due
+	if spec.Status.TLS != nil && equality.Semantic.DeepEqual(spec.Status.TLS.Certificates, status.Certificates) && checkDue(spec.Status.TLS, secrets) > 0 {
+		return nil
+	}
+	spec.Status.TLS = status
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return fmt.Errorf("failed to update TLS status: %w", err)
+	}
+	return nil
+}
+
+func (r *CertificateRoller) readCertificate(ctx context.Context, tlsSecret utils.TLSSecret, namespace string) (time.Time, string, error) {
+	secret := &v12.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: tlsSecret.Name, Namespace: namespace}, secret); err != nil {
+		return time.Time{}, "", err
+	}
+	certificate, err := utils.ParseCertificate(secret.Data[tlsSecret.CertificateKey])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid certificate %s: %w", tlsSecret.CertificateKey, err)
+	}
+	return certificate.NotAfter, certificate.Subject.String(), nil
+}
+
+func (r *CertificateRoller) warn(spec *v1.CassandraSupplService, certificate v1.CertificateStatus, remaining time.Duration) {
+	if remaining <= 0 {
+		r.Recorder.Event(spec, v12.EventTypeWarning, "CertificateExpired",
+			fmt.Sprintf("certificate %s expired at %s", certificate.SecretName, certificate.NotAfter.UTC().Format(time.RFC3339)))
+		return
+	}
+	r.Recorder.Event(spec, v12.EventTypeWarning, "CertificateExpiring",
+		fmt.Sprintf("certificate %s expires at %s, in less than %s", certificate.SecretName, certificate.NotAfter.UTC().Format(time.RFC3339), certificate.WarnedThreshold))
+}
+
+// crossedThreshold returns the smallest threshold above the remaining validity, an expired certificate crosses zero
+func crossedThreshold(remaining time.Duration, thresholds []time.Duration) (time.Duration, bool) {
+	if remaining <= 0 {
+		return 0, true
+	}
+	var crossed time.Duration
+	warned := false
+	for _, threshold := range thresholds {
+		if remaining < threshold && (!warned || threshold < crossed) {
+			crossed, warned = threshold, true
+		}
+	}
+	return crossed, warned
+}
+
+func expiryWarningThresholds(tls v1.TLS) ([]time.Duration, error) {
+	values := tls.ExpiryWarningThresholds
+	if len(values) == 0 {
+		values = defaultExpiryWarningThresholds
+	}
+	var thresholds []time.Duration
+	for _, value := range values {
+		threshold, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls.expiryWarningThresholds: %w", err)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
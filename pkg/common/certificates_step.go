@@ -0,0 +1,61 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// CertificatesStep requests the backup daemon and DBaaS adapter server certificates from the `tls.issuer`
+// and waits until cert-manager stores them, so the components start with their certificates.
+// In the `tls.selfSigned` mode the operator generates the certificates itself.
+type CertificatesStep struct {
+	core.DefaultExecutable
+}
+
+func (r *CertificatesStep) Execute(ctx core.ExecutionContext) error {
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+
+	if spec.Spec.TLS.SelfSigned != nil {
+		written, err := EnsureSelfSignedCertificates(context.TODO(), kubeClient, spec, request.Namespace)
+		core.PanicError(err, log.Error, "Self-signed certificates processing failed")
+		for _, secretName := range written {
+			log.Info("Self-signed certificate " + secretName + " has been generated")
+		}
+		return nil
+	}
+
+	components := utils.TLSComponents(spec.Spec)
+	for _, component := range components {
+		template := utils.CertificateTemplate(component, request.Namespace, *spec.Spec.TLS.Issuer)
+		certificate := &unstructured.Unstructured{}
+		certificate.SetGroupVersionKind(utils.CertificateGVK)
+		certificate.SetName(template.GetName())
+		certificate.SetNamespace(template.GetNamespace())
+		_, err := controllerutil.CreateOrUpdate(context.TODO(), kubeClient, certificate, func() error {
+			certificate.Object["spec"] = template.Object["spec"]
+			return nil
+		})
+		core.PanicError(err, log.Error, "Certificate "+template.GetName()+" processing failed")
+	}
+
+	for _, component := range components {
+		log.Debug("Waiting for certificate secret " + component.SecretName)
+		_, err := utils.WaitForCertificateSecret(kubeClient, component.SecretName, request.Namespace, time.Duration(spec.Spec.WaitTimeout)*time.Second)
+		core.PanicError(err, log.Error, "Certificate of "+component.Name+" is not issued")
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"slices"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// KeystoresStep builds the PKCS12 keystores and truststore before the components that mount them are deployed
+type KeystoresStep struct {
+	core.DefaultExecutable
+}
+
+func (r *KeystoresStep) Execute(ctx core.ExecutionContext) error {
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+
+	written, err := EnsureKeystores(context.TODO(), kubeClient, spec, request.Namespace)
+	core.PanicError(err, log.Error, "Keystores processing failed")
+	if written {
+		log.Info("Keystores secret " + utils.KeystoresSecretName(spec.Spec.TLS) + " has been generated")
+	}
+	return nil
+}
+
+// EnsureKeystores stores the truststore of the root CA and the keystores of the components that mount them
+// to the keystores secret. The stores are rebuilt only when the source TLS secrets or the password change,
+// the secret is removed when no component mounts it. It reports if the secret was written.
+func EnsureKeystores(ctx context.Context, kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (bool, error) {
+	tls := spec.Spec.TLS
+	secretName := utils.KeystoresSecretName(tls)
+	consumers := utils.KeystoreConsumers(spec.Spec)
+	if len(consumers) == 0 {
+		secret := &v12.Secret{}
+		secret.Name = secretName
+		secret.Namespace = namespace
+		return false, client.IgnoreNotFound(kubeClient.Delete(ctx, secret))
+	}
+
+	keystores := map[string]utils.Keystore{}
+	sources := []utils.TLSSecret{{Name: tls.RootCASecretName}}
+	for _, keystore := range consumers {
+		if keystore.FileName != "" {
+			keystores[keystore.FileName] = keystore
+			sources = append(sources, utils.TLSSecret{Name: keystore.SecretName})
+		}
+	}
+	sourcesHash, err := utils.TLSSecretsHash(kubeClient, sources, namespace)
+	if err != nil {
+		return false, err
+	}
+	fileNames := slices.Sorted(maps.Keys(keystores))
+	hash := fmt.Sprintf("%x", sha256.Sum256(fmt.Appendf(nil, "%s/%s/%v", sourcesHash, tls.KeystorePass, fileNames)))
+
+	secret := &v12.Secret{}
+	err = kubeClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if err == nil && secret.Annotations[utils.KeystoresSourceHashAnnotation] == hash {
+		return false, nil
+	}
+
+	rootCA, err := readSecret(ctx, kubeClient, tls.RootCASecretName, namespace)
+	if err != nil {
+		return false, err
+	}
+	caPEM := rootCA.Data[tls.RootCAFileName]
+	truststore, err := utils.EncodeTruststore(caPEM, tls.KeystorePass)
+	if err != nil {
+		return false, fmt.Errorf("failed to build truststore from secret %s: %w", tls.RootCASecretName, err)
+	}
+	data := map[string][]byte{
+		utils.TruststoreFileName: truststore,
+		utils.KeystorePassKey:    []byte(tls.KeystorePass),
+	}
+	for _, fileName := range fileNames {
+		source, err := readSecret(ctx, kubeClient, keystores[fileName].SecretName, namespace)
+		if err != nil {
+			return false, err
+		}
+		keystore, err := utils.EncodeKeystore(source.Data[tls.SignedCRTFileName], source.Data[tls.PrivateKeyFileName], caPEM, tls.KeystorePass)
+		if err != nil {
+			return false, fmt.Errorf("failed to build keystore %s from secret %s: %w", fileName, source.Name, err)
+		}
+		data[fileName] = keystore
+	}
+
+	secret = &v12.Secret{}
+	secret.Name = secretName
+	secret.Namespace = namespace
+	_, err = controllerutil.CreateOrUpdate(ctx, kubeClient, secret, func() error {
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[utils.KeystoresSourceHashAnnotation] = hash
+		secret.Data = data
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to store keystores: %w", err)
+	}
+	return true, nil
+}
+
+func readSecret(ctx context.Context, kubeClient client.Client, name, namespace string) (*v12.Secret, error) {
+	secret := &v12.Secret{}
+	if err := kubeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to read TLS secret %s: %w", name, err)
+	}
+	return secret, nil
+}
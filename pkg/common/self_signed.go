@@ -0,0 +1,126 @@
+package common
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const selfSignedCAName = "cassandra-services-ca"
+
+// EnsureSelfSignedCertificates generates the `tls.selfSigned` CA and the component server certificates
+// when they are missing, expire within the renewal window or are not signed by the current CA.
+// It returns the names of the secrets that were written.
+func EnsureSelfSignedCertificates(ctx context.Context, kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) ([]string, error) {
+	caDuration, duration, renewBefore, err := utils.SelfSignedDurations(*spec.Spec.TLS.SelfSigned)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls.selfSigned durations: %w", err)
+	}
+	now := time.Now()
+	var written []string
+
+	caSecret, caRenewed, err := ensureSelfSignedCA(ctx, kubeClient, spec, namespace, caDuration, renewBefore, now)
+	if err != nil {
+		return written, err
+	}
+	if caRenewed {
+		written = append(written, caSecret.Name)
+	}
+	caCertPEM := caSecret.Data[v12.ServiceAccountRootCAKey]
+	ca, err := utils.ParseCertificate(caCertPEM)
+	if err != nil {
+		return written, fmt.Errorf("invalid CA certificate in secret %s: %w", caSecret.Name, err)
+	}
+
+	for _, component := range utils.TLSComponents(spec.Spec) {
+		dnsNames := utils.ServiceDNSNames(component.Name, namespace)
+		secret := &v12.Secret{}
+		err := kubeClient.Get(ctx, types.NamespacedName{Name: component.SecretName, Namespace: namespace}, secret)
+		if err != nil && !errors.IsNotFound(err) {
+			return written, err
+		}
+		if !serverCertificateDue(secret.Data[v12.TLSCertKey], ca, dnsNames, renewBefore, now) {
+			continue
+		}
+
+		certPEM, keyPEM, err := utils.GenerateServerCertificate(caCertPEM, caSecret.Data[utils.CAKeyFileName], component.Name, dnsNames, duration)
+		if err != nil {
+			return written, fmt.Errorf("failed to generate certificate of %s: %w", component.Name, err)
+		}
+		secret = &v12.Secret{}
+		secret.Name = component.SecretName
+		secret.Namespace = namespace
+		_, err = controllerutil.CreateOrUpdate(ctx, kubeClient, secret, func() error {
+			secret.Data = map[string][]byte{
+				v12.ServiceAccountRootCAKey: caCertPEM,
+				v12.TLSCertKey:              certPEM,
+				v12.TLSPrivateKeyKey:        keyPEM,
+			}
+			return nil
+		})
+		if err != nil {
+			return written, fmt.Errorf("failed to store certificate of %s: %w", component.Name, err)
+		}
+		written = append(written, component.SecretName)
+	}
+	return written, nil
+}
+
+// ensureSelfSignedCA returns the CA secret, the CA is generated when the secret does not exist or it is due to renewal.
+// A secret without the CA private key is not issued by the operator and is never overwritten.
+func ensureSelfSignedCA(ctx context.Context, kubeClient client.Client, spec *v1.CassandraSupplService, namespace string, caDuration, renewBefore time.Duration, now time.Time) (*v12.Secret, bool, error) {
+	secret := &v12.Secret{}
+	err := kubeClient.Get(ctx, types.NamespacedName{Name: spec.Spec.TLS.RootCASecretName, Namespace: namespace}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, false, err
+	}
+	if err == nil {
+		if len(secret.Data[utils.CAKeyFileName]) == 0 {
+			return nil, false, fmt.Errorf("secret %s holds no %s, the CA is not managed by the operator", secret.Name, utils.CAKeyFileName)
+		}
+		if !utils.CertificateDue(secret.Data[v12.ServiceAccountRootCAKey], renewBefore, now) {
+			return secret, false, nil
+		}
+	}
+
+	certPEM, keyPEM, err := utils.GenerateCA(selfSignedCAName, caDuration)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate CA: %w", err)
+	}
+	secret = &v12.Secret{}
+	secret.Name = spec.Spec.TLS.RootCASecretName
+	secret.Namespace = namespace
+	_, err = controllerutil.CreateOrUpdate(ctx, kubeClient, secret, func() error {
+		secret.Data = map[string][]byte{
+			v12.ServiceAccountRootCAKey: certPEM,
+			utils.CAKeyFileName:         keyPEM,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to store CA: %w", err)
+	}
+	return secret, true, nil
+}
+
+// serverCertificateDue reports if the certificate is due to renewal, is not signed by the CA or has other DNS names
+func serverCertificateDue(certPEM []byte, ca *x509.Certificate, dnsNames []string, renewBefore time.Duration, now time.Time) bool {
+	if utils.CertificateDue(certPEM, renewBefore, now) {
+		return true
+	}
+	certificate, err := utils.ParseCertificate(certPEM)
+	if err != nil || certificate.CheckSignatureFrom(ca) != nil {
+		return true
+	}
+	return !slices.Equal(certificate.DNSNames, dnsNames)
+}
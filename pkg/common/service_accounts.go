@@ -0,0 +1,166 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ServiceAccountsStep creates a ServiceAccount for every installed component, bound to a Role with the API access
+// the component needs. The accounts are removed when the shared `serviceAccountName` is set or the component is uninstalled.
+type ServiceAccountsStep struct {
+	core.DefaultExecutable
+}
+
+func (r *ServiceAccountsStep) Execute(ctx core.ExecutionContext) error {
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+
+	err := EnsureServiceAccounts(context.TODO(), kubeClient, spec, request.Namespace)
+	core.PanicError(err, log.Error, "Component service accounts processing failed")
+	return nil
+}
+
+// ComponentRoleRules returns the API access of the component, nil if it does not call the Kubernetes API
+func ComponentRoleRules(component string) []rbacv1.PolicyRule {
+	switch component {
+	case utils.Robot:
+		return []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"apps"},
+				Resources: []string{"deployments", "statefulsets"},
+				Verbs:     []string{"get", "list"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+				Verbs:     []string{"get", "list"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps"},
+				// CONFIG_NAME and SUPPLEMENTARY_CONFIG_NAME of the tests
+				ResourceNames: []string{"cassandra-tests-config", "supplementary-tests-config"},
+				Verbs:         []string{"get"},
+			},
+			{
+				// STATUS_CUSTOM_RESOURCE_PATH, the tests report the result to their own deployment
+				APIGroups:     []string{"apps"},
+				Resources:     []string{"deployments/status"},
+				ResourceNames: []string{utils.Robot},
+				Verbs:         []string{"get", "patch", "update"},
+			},
+		}
+	}
+	return nil
+}
+
+// EnsureServiceAccounts keeps the ServiceAccount, Role and RoleBinding of every component in line with the spec.
+// The objects are controlled by the CR, objects of the same name created by others are never updated or removed.
+func EnsureServiceAccounts(ctx context.Context, kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) error {
+	owner := metav1.NewControllerRef(spec, v1.GroupVersion.WithKind("CassandraSupplService"))
+	installed := utils.InstalledComponents(spec.Spec)
+	for _, component := range []string{utils.BackupDaemon, utils.DbaasName, utils.Robot} {
+		generated := spec.Spec.ServiceAccountName == "" && slices.Contains(installed, component)
+		rules := ComponentRoleRules(component)
+		labels := map[string]string{
+			utils.Name:         component,
+			utils.AppName:      component,
+			utils.AppManagedBy: spec.Spec.ManagedBy,
+		}
+
+		account := &v12.ServiceAccount{}
+		account.Name = component
+		account.Namespace = namespace
+		role := &rbacv1.Role{}
+		role.Name = component
+		role.Namespace = namespace
+		binding := &rbacv1.RoleBinding{}
+		binding.Name = component
+		binding.Namespace = namespace
+
+		if !generated || rules == nil {
+			for _, obj := range []client.Object{binding, role} {
+				if err := removeControlled(ctx, kubeClient, spec, obj); err != nil {
+					return fmt.Errorf("failed to remove %T %s: %w", obj, component, err)
+				}
+			}
+		}
+		if !generated {
+			if err := removeControlled(ctx, kubeClient, spec, account); err != nil {
+				return fmt.Errorf("failed to remove service account %s: %w", component, err)
+			}
+			continue
+		}
+
+		err := applyControlled(ctx, kubeClient, spec, owner, account, func() {
+			account.Labels = labels
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create service account %s: %w", component, err)
+		}
+		if rules == nil {
+			continue
+		}
+		err = applyControlled(ctx, kubeClient, spec, owner, role, func() {
+			role.Labels = labels
+			role.Rules = rules
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create role %s: %w", component, err)
+		}
+		err = applyControlled(ctx, kubeClient, spec, owner, binding, func() {
+			binding.Labels = labels
+			binding.Subjects = []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: component, Namespace: namespace}}
+			binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: component}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create role binding %s: %w", component, err)
+		}
+	}
+	return nil
+}
+
+// applyControlled creates or updates the object controlled by the CR, an existing object of someone else is left intact
+func applyControlled(ctx context.Context, kubeClient client.Client, spec *v1.CassandraSupplService, owner *metav1.OwnerReference,
+	obj client.Object, mutate func()) error {
+	_, err := controllerutil.CreateOrUpdate(ctx, kubeClient, obj, func() error {
+		if obj.GetResourceVersion() == "" {
+			obj.SetOwnerReferences(append(obj.GetOwnerReferences(), *owner))
+		} else if !metav1.IsControlledBy(obj, spec) {
+			return fmt.Errorf("%s exists and is not created by the operator, remove it or set serviceAccountName", obj.GetName())
+		}
+		mutate()
+		return nil
+	})
+	return err
+}
+
+// removeControlled deletes the object if it is controlled by the CR. The shared `serviceAccountName` is never removed.
+func removeControlled(ctx context.Context, kubeClient client.Client, spec *v1.CassandraSupplService, obj client.Object) error {
+	if obj.GetName() == spec.Spec.ServiceAccountName {
+		return nil
+	}
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, spec) {
+		return nil
+	}
+	uid := obj.GetUID()
+	return client.IgnoreNotFound(kubeClient.Delete(ctx, obj, client.Preconditions{UID: &uid}))
+}
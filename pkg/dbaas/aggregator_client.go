@@ -0,0 +1,177 @@
+package dbaas
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/vault"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const aggregatorRequestTimeout = 30 * time.Second
+
+// the database type the adapter registers physical databases with
+const physicalDatabaseType = "cassandra"
+
+// PhysicalDatabase is a physical database registered in DBaaS aggregator
+type PhysicalDatabase struct {
+	AdapterID        string            `json:"adapterId,omitempty"`
+	AdapterAddress   string            `json:"adapterAddress,omitempty"`
+	SupportedVersion string            `json:"supportedVersion,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+}
+
+// registeredPhysicalDatabases is the aggregator /physical_databases response
+type registeredPhysicalDatabases struct {
+	Identified map[string]PhysicalDatabase `json:"identified"`
+}
+
+type AggregatorClient interface {
+	// PhysicalDatabase returns the registration of the physical database, found is false when it is not registered
+	PhysicalDatabase(identifier string) (database *PhysicalDatabase, found bool, err error)
+	// Deregister removes the physical database, a database that is not registered is not an error
+	Deregister(identifier string) error
+}
+
+type AggregatorClientBuilder interface {
+	Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (AggregatorClient, error)
+}
+
+type AggregatorClientBuilderImpl struct {
+}
+
+// Build creates a DBaaS aggregator client authorized with `dbaas.aggregator.secretName` credentials
+func (b *AggregatorClientBuilderImpl) Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (AggregatorClient, error) {
+	aggregator := spec.Spec.Dbaas.Aggregator
+	if aggregator == nil || aggregator.DbaasAggregatorRegistrationAddress == "" {
+		return nil, fmt.Errorf("dbaas.aggregator.dbaasAggregatorRegistrationAddress is not set")
+	}
+	secret, err := core.ReadSecret(kubeClient, aggregator.SecretName, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", aggregator.SecretName, err)
+	}
+
+	pass := string(secret.Data[utils.Password])
+	if spec.Spec.VaultRegistration.Enabled {
+		vaultHelper := vault.NewVaulterHelperImpl(vault.NewVaultClientImpl(&spec.Spec.VaultRegistration))
+		if vaultHelper.IsVaultURL(pass) {
+			pass, err = vaultHelper.ResolvePassword(pass)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	httpClient := &http.Client{Timeout: aggregatorRequestTimeout}
+	if utils.DbaasClientTLS(spec.Spec) {
+		tlsConfig, err := aggregatorTLSConfig(kubeClient, spec, namespace)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	return &AggregatorClientImpl{
+		Address:    aggregator.DbaasAggregatorRegistrationAddress,
+		Username:   string(secret.Data[utils.Username]),
+		Password:   pass,
+		HTTPClient: httpClient,
+	}, nil
+}
+
+// aggregatorTLSConfig trusts the CA of `tls.rootCASecretName`, the system trust store without it,
+// and presents the `dbaas.tls.clientCertSecretName` certificate
+func aggregatorTLSConfig(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if spec.Spec.TLS.RootCASecretName != "" {
+		caSecret, err := core.ReadSecret(kubeClient, spec.Spec.TLS.RootCASecretName, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", spec.Spec.TLS.RootCASecretName, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caSecret.Data[spec.Spec.TLS.RootCAFileName]) {
+			return nil, fmt.Errorf("no CA certificate found in secret %s", spec.Spec.TLS.RootCASecretName)
+		}
+	}
+
+	if secretName := spec.Spec.Dbaas.TLS.ClientCertSecretName; secretName != "" {
+		certSecret, err := core.ReadSecret(kubeClient, secretName, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", secretName, err)
+		}
+		certificate, err := tls.X509KeyPair(certSecret.Data[spec.Spec.TLS.SignedCRTFileName], certSecret.Data[spec.Spec.TLS.PrivateKeyFileName])
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate in secret %s: %w", secretName, err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+type AggregatorClientImpl struct {
+	Address    string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+}
+
+func (c *AggregatorClientImpl) PhysicalDatabase(identifier string) (*PhysicalDatabase, bool, error) {
+	data, _, err := c.do(http.MethodGet, physicalDatabasesPath(""))
+	if err != nil {
+		return nil, false, err
+	}
+	databases := &registeredPhysicalDatabases{}
+	if err := json.Unmarshal(data, databases); err != nil {
+		return nil, false, fmt.Errorf("failed to parse DBaaS aggregator physical databases response: %w", err)
+	}
+	database, found := databases.Identified[identifier]
+	return &database, found, nil
+}
+
+func (c *AggregatorClientImpl) Deregister(identifier string) error {
+	_, status, err := c.do(http.MethodDelete, physicalDatabasesPath(identifier))
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func physicalDatabasesPath(identifier string) string {
+	path := fmt.Sprintf("/api/v3/dbaas/%s/physical_databases", physicalDatabaseType)
+	if identifier != "" {
+		path += "/" + identifier
+	}
+	return path
+}
+
+func (c *AggregatorClientImpl) do(method, path string) ([]byte, int, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Address, "/")+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, fmt.Errorf("DBaaS aggregator %s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, resp.StatusCode, nil
+}
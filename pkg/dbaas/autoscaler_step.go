@@ -0,0 +1,43 @@
+package dbaas
+
+import (
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DbaasAutoscaler creates the adapter HorizontalPodAutoscaler and removes it when `dbaas.autoscaling` is disabled
+type DbaasAutoscaler struct {
+	core.DefaultExecutable
+}
+
+func (r *DbaasAutoscaler) Execute(ctx core.ExecutionContext) error {
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+	spec := ctx.Get(constants.ContextSpec).(*v2.CassandraSupplService)
+
+	if !spec.Spec.Dbaas.Autoscaling.Enabled {
+		err := core.DeleteRuntimeObject(kubeClient, &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: utils.DbaasName, Namespace: request.Namespace},
+		})
+		core.PanicError(err, log.Error, "Dbaas HorizontalPodAutoscaler deletion failed")
+		return nil
+	}
+
+	template, err := DbaasAutoscalerTemplate(request.Namespace, spec.Spec.Dbaas.Autoscaling)
+	core.PanicError(err, log.Error, "Dbaas HorizontalPodAutoscaler template failed")
+
+	err = utils.CreateRuntimeObjectContextWrapper(ctx, template, template.ObjectMeta, utils.BasicLabels{})
+	core.PanicError(err, log.Error, "Dbaas HorizontalPodAutoscaler processing failed")
+
+	log.Debug("Dbaas HorizontalPodAutoscaler has been created")
+
+	return nil
+}
@@ -37,7 +37,10 @@ func (r *DbaasBuilder) Build(ctx core.ExecutionContext) core.Executable {
 		})
 	}
 
+	dbaas.AddStep(&DbaasLabelsStep{})
 	dbaas.AddStep(&DbaasDeployment{})
+	dbaas.AddStep(&DbaasPodDisruptionBudget{})
+	dbaas.AddStep(&DbaasAutoscaler{})
 
 	return &dbaas
 }
@@ -32,7 +32,7 @@ func (r *DbaasService) Execute(ctx core.ExecutionContext) error {
 		map[string]string{
 			utils.Name: utils.DbaasName,
 		},
-		map[string]int32{"http": utils.GetHTTPPort(utils.IsTLSEnableForDBAAS(spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress, spec.Spec.TLS.Enabled))}, request.Namespace)
+		utils.HTTPServicePorts(utils.DbaasServerTLS(spec.Spec), spec.Spec.TLS), request.Namespace)
 	// Kubernetes api causes "invalid resourceVersion error" on update. So remove it.
 	core.DeleteRuntimeObject(kubeClient, &v12.Service{
 		ObjectMeta: template.ObjectMeta,
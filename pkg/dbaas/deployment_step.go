@@ -1,9 +1,11 @@
 package dbaas
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
@@ -11,7 +13,11 @@ import (
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
 	coreUtils "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/utils"
 	"go.uber.org/zap"
+	v13 "k8s.io/api/apps/v1"
 	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -26,7 +32,8 @@ func (r *DbaasDeployment) Execute(ctx core.ExecutionContext) error {
 	helperImpl := ctx.Get(utils.KubernetesHelperImpl).(core.KubernetesHelper)
 	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
 	credsManager := ctx.Get(utils.ContextCredsManager).(utils.CredsManagerI)
-	tlsEnabled := utils.IsTLSEnableForDBAAS(spec.Spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress, spec.Spec.TLS.Enabled)
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	tlsEnabled := utils.DbaasServerTLS(spec.Spec)
 
 	// Environment variable Start
 	envs := []v12.EnvVar{
@@ -40,15 +47,23 @@ func (r *DbaasDeployment) Execute(ctx core.ExecutionContext) error {
 		},
 	}
 
+	if spec.Spec.AWSKeyspaces.Install {
+		// the adapter signs requests with SigV4 instead of the Cassandra role credentials
+		envs = append(envs, utils.AWSKeyspacesEnvs(spec.Spec.AWSKeyspaces, "CASSANDRA_HOSTNAME", "CASSANDRA_PORT")...)
+		envs = append(envs, coreUtils.GetPlainTextEnvVar("TLS_ENABLED", "true"))
+	} else {
+		envs = append(envs,
+			coreUtils.GetPlainTextEnvVar("CASSANDRA_HOSTNAME", core.OptionalString(spec.Spec.Cassandra.Host, fmt.Sprintf("%s.%s", utils.Cassandra, request.Namespace))),
+			coreUtils.GetPlainTextEnvVar("CASSANDRA_PORT", core.OptionalString(strconv.Itoa(spec.Spec.Cassandra.Port), "9042")),
+			coreUtils.GetPlainTextEnvVar("TLS_ENABLED", strconv.FormatBool(spec.Spec.Cassandra.TLS)),
+		)
+		envs = append(envs, utils.CassandraCredentialEnvs(spec.Spec)...)
+	}
+
 	envs = append(envs,
-		coreUtils.GetPlainTextEnvVar("CASSANDRA_HOSTNAME", core.OptionalString(spec.Spec.Cassandra.Host, fmt.Sprintf("%s.%s", utils.Cassandra, request.Namespace))),
-		coreUtils.GetPlainTextEnvVar("CASSANDRA_PORT", core.OptionalString(strconv.Itoa(spec.Spec.Cassandra.Port), "9042")),
-		coreUtils.GetSecretEnvVar("CASSANDRA_USERNAME", spec.Spec.Cassandra.SecretName, utils.Username),
-		coreUtils.GetSecretEnvVar("CASSANDRA_PASSWORD", spec.Spec.Cassandra.SecretName, utils.Password),
 		coreUtils.GetPlainTextEnvVar("GOCQL_DEFAULT_KEYSPACE", core.OptionalString(spec.Spec.Cassandra.DefaultKeyspace, "system")),
-		coreUtils.GetPlainTextEnvVar("GOCQL_CONSISTENCY", core.OptionalString(spec.Spec.Cassandra.Consistency, "QUORUM")),
-		coreUtils.GetPlainTextEnvVar("TLS_ENABLED", strconv.FormatBool(spec.Spec.Cassandra.TLS)),
-		coreUtils.GetPlainTextEnvVar("DBAAS_AGGREGATOR_PHYSICAL_DATABASE_IDENTIFIER", core.OptionalString(dbaas.Aggregator.PhysicalDatabaseIdentifier, request.Namespace)),
+		coreUtils.GetPlainTextEnvVar("GOCQL_CONSISTENCY", utils.CassandraConsistency(spec.Spec)),
+		coreUtils.GetPlainTextEnvVar("DBAAS_AGGREGATOR_PHYSICAL_DATABASE_IDENTIFIER", PhysicalDatabaseIdentifier(spec, request.Namespace)),
 		coreUtils.GetPlainTextEnvVar("DBAAS_ADAPTER_ADDRESS", fmt.Sprintf("%s://%s.%s:%d", utils.GetHTTPProtocol(tlsEnabled), utils.DbaasName, request.Namespace, utils.GetHTTPPort(tlsEnabled))),
 		coreUtils.GetPlainTextEnvVar("DBAAS_AGGREGATOR_REGISTRATION_ADDRESS", dbaas.Aggregator.DbaasAggregatorRegistrationAddress),
 		coreUtils.GetPlainTextEnvVar("PORT", fmt.Sprint(utils.GetHTTPPort(tlsEnabled))),
@@ -71,6 +86,21 @@ func (r *DbaasDeployment) Execute(ctx core.ExecutionContext) error {
 		coreUtils.GetSecretEnvVar("DBAAS_STREAMING_ROLE_PERMISSIONS", utils.DbaasAdminRoleCreds, utils.Roles),
 	)
 
+	if dbaas.StreamingRole.Managed {
+		// the key appears once the operator has created the role
+		optional := true
+		envs = append(envs, v12.EnvVar{
+			Name: "DBAAS_STREAMING_ROLE_PASSWORD",
+			ValueFrom: &v12.EnvVarSource{
+				SecretKeyRef: &v12.SecretKeySelector{
+					LocalObjectReference: v12.LocalObjectReference{Name: utils.DbaasAdminRoleCreds},
+					Key:                  utils.Password,
+					Optional:             &optional,
+				},
+			},
+		})
+	}
+
 	if spec.Spec.Backup.Install {
 		envs = append(envs,
 			coreUtils.GetSecretEnvVar("BACKUP_DAEMON_API_CREDENTIALS_USERNAME", spec.Spec.Backup.SecretName, utils.Username),
@@ -80,15 +110,27 @@ func (r *DbaasDeployment) Execute(ctx core.ExecutionContext) error {
 	}
 	// Environment variable End
 
+	replicas := currentDbaasReplicas(kubeClient, request.Namespace, spec)
+
 	dc := DbaasDeploymentTemplate(
 		request.Namespace,
 		dbaas.DockerImage,
 		dbaas.NodeLabels,
 		*dbaas.Resources,
 		envs,
-		utils.GetHTTPPort(tlsEnabled))
+		utils.GetHTTPPort(tlsEnabled),
+		replicas)
+
+	utils.HTTPHealthProbes(&dc.Spec.Template.Spec.Containers[0], utils.GetHTTPPort(tlsEnabled), dbaas.Probes)
 
-	err := credsManager.AddCredHashToPodTemplate([]string{spec.Spec.Cassandra.SecretName}, &dc.Spec.Template)
+	physicalLabels, err := physicalDatabaseLabels(spec)
+	core.PanicError(err, log.Error, "Dbaas physical database labels serialization failed")
+	if dc.Spec.Template.Annotations == nil {
+		dc.Spec.Template.Annotations = map[string]string{}
+	}
+	dc.Spec.Template.Annotations[utils.DbaasPhysicalDatabasesLabelsHash] = physicalDatabaseLabelsHash(physicalLabels)
+
+	err = credsManager.AddCredHashToPodTemplate(utils.CassandraSecretNames(spec.Spec), &dc.Spec.Template)
 	if err != nil {
 		log.Error(fmt.Sprintf("can't add secret HASH to annotations for %s", dc.Name), zap.Error(err))
 		return err
@@ -98,12 +140,23 @@ func (r *DbaasDeployment) Execute(ctx core.ExecutionContext) error {
 	utils.TLSClientSpecUpdate(&dc.Spec.Template.Spec, utils.RootCertPath, spec.Spec.TLS)
 
 	if tlsEnabled {
-		utils.TLSServerSpecUpdate(&dc.Spec.Template.Spec, spec.Spec.TLS, spec.Spec.Dbaas.TLS.DbaasAdapterCASecretName, utils.ServerCertsPath)
+		// server TLS of the adapter does not depend on TLS of the other components
+		serverTLS := spec.Spec.TLS
+		serverTLS.Enabled = true
+		utils.TLSServerSpecUpdate(&dc.Spec.Template.Spec, serverTLS, spec.Spec.Dbaas.TLS.DbaasAdapterCASecretName, utils.ServerCertsPath)
 	}
+	utils.DbaasAggregatorTLSSpecUpdate(&dc.Spec.Template.Spec, spec.Spec)
+	utils.CassandraClientCertSpecUpdate(&dc.Spec.Template.Spec, spec.Spec)
+	err = utils.AddCertificateHashToPodTemplate(kubeClient, &dc.Spec.Template, spec.Spec, utils.DbaasName, request.Namespace)
+	core.PanicError(err, log.Error, "Dbaas certificate reading failed")
 
-	err = helperImpl.DeleteDeploymentAndPods(dc.Name, request.Namespace, spec.Spec.WaitTimeout)
+	// several pods are rolled one by one, a single pod is recreated as the other deployments are
+	rolling := replicas > 1 && dbaasDeploymentExists(kubeClient, request.Namespace)
+	if !rolling {
+		err = helperImpl.DeleteDeploymentAndPods(dc.Name, request.Namespace, spec.Spec.WaitTimeout)
 
-	core.PanicError(err, log.Error, "Dbaas deployment deletion failed")
+		core.PanicError(err, log.Error, "Dbaas deployment deletion failed")
+	}
 
 	labels := utils.BasicLabels{
 		AppName:       utils.DbaasName,
@@ -114,16 +167,67 @@ func (r *DbaasDeployment) Execute(ctx core.ExecutionContext) error {
 	err = utils.CreateRuntimeObjectContextWrapper(ctx, dc, dc.ObjectMeta, labels)
 	core.PanicError(err, log.Error, "Dbaas deployment config processing failed")
 
+	if rolling {
+		log.Debug("Waiting for dbaas rollout")
+		err = waitForDbaasRollout(kubeClient, request.Namespace, spec.Spec.WaitTimeout)
+		core.PanicError(err, log.Error, "Dbaas rollout waiting failed")
+	}
+
 	log.Debug("Waiting for dbaas is ready")
 	err = helperImpl.WaitForPodsReady(
 		map[string]string{
 			utils.Name: utils.DbaasName,
 		},
 		request.Namespace,
-		1,
+		int(replicas),
 		spec.Spec.WaitTimeout)
 
 	core.PanicError(err, log.Error, "Dbaas Pod Ready status waiting failed")
 
 	return nil
 }
+
+// DbaasReplicas returns the number of adapter pods, custom resources created before the field existed have one.
+// The autoscaler minimum is returned while autoscaling is enabled.
+func DbaasReplicas(spec *v1.CassandraSupplService) int32 {
+	if autoscaling := spec.Spec.Dbaas.Autoscaling; autoscaling.Enabled {
+		return max(autoscaling.MinReplicas, 1)
+	}
+	if spec.Spec.Dbaas.Replicas < 1 {
+		return 1
+	}
+	return int32(spec.Spec.Dbaas.Replicas)
+}
+
+// currentDbaasReplicas keeps the replica count chosen by the autoscaler, so a redeploy does not scale the adapter down
+func currentDbaasReplicas(kubeClient client.Client, namespace string, spec *v1.CassandraSupplService) int32 {
+	replicas := DbaasReplicas(spec)
+	if !spec.Spec.Dbaas.Autoscaling.Enabled {
+		return replicas
+	}
+	deployment := &v13.Deployment{}
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: namespace}, deployment)
+	if err != nil || deployment.Spec.Replicas == nil {
+		return replicas
+	}
+	return min(max(*deployment.Spec.Replicas, replicas), max(spec.Spec.Dbaas.Autoscaling.MaxReplicas, replicas))
+}
+
+func dbaasDeploymentExists(kubeClient client.Client, namespace string) bool {
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Name: utils.DbaasName, Namespace: namespace}, &v13.Deployment{})
+	return err == nil
+}
+
+// waitForDbaasRollout waits until the deployment controller has replaced all the pods with the updated template
+func waitForDbaasRollout(kubeClient client.Client, namespace string, waitSeconds int) error {
+	return wait.PollUntilContextTimeout(context.TODO(), time.Second, time.Duration(waitSeconds)*time.Second, true, func(ctx context.Context) (bool, error) {
+		deployment := &v13.Deployment{}
+		if err := kubeClient.Get(ctx, types.NamespacedName{Name: utils.DbaasName, Namespace: namespace}, deployment); err != nil {
+			return false, err
+		}
+		status := deployment.Status
+		return status.ObservedGeneration >= deployment.Generation &&
+			status.UpdatedReplicas == status.Replicas &&
+			status.AvailableReplicas == status.Replicas, nil
+	})
+}
@@ -0,0 +1,63 @@
+package dbaas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// physicalDatabaseLabels returns the labels file content read by the adapter on registration, keys are sorted
+func physicalDatabaseLabels(spec *v1.CassandraSupplService) (string, error) {
+	labels := spec.Spec.Dbaas.PhysicalDatabaseLabels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	data, err := json.Marshal(labels)
+	return string(data), err
+}
+
+// physicalDatabaseLabelsHash is put to the adapter pod template, so changed labels roll the adapter
+func physicalDatabaseLabelsHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// DbaasLabelsStep renders the physical database labels config map mounted to the adapter
+type DbaasLabelsStep struct {
+	core.DefaultExecutable
+}
+
+func (r *DbaasLabelsStep) Execute(ctx core.ExecutionContext) error {
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+
+	data, err := physicalDatabaseLabels(spec)
+	core.PanicError(err, log.Error, "Dbaas physical database labels serialization failed")
+
+	cm := &v12.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: request.Namespace,
+			Name:      utils.DbaasPhysicalDatabasesLabels,
+			Labels: map[string]string{
+				utils.Name: utils.DbaasName,
+			},
+		},
+		Data: map[string]string{
+			utils.DbaasPhysicalDatabasesLabelsKey: data,
+		},
+	}
+	err = utils.CreateRuntimeObjectContextWrapper(ctx, cm, cm.ObjectMeta, utils.BasicLabels{})
+	core.PanicError(err, log.Error, "Dbaas physical database labels config map creation failed")
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package dbaas
+
+import (
+	"context"
+
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DbaasPodDisruptionBudget keeps the adapter PodDisruptionBudget in line with the replica count
+// and removes it when `dbaas.pdb` is disabled. A single pod gets no budget, it would block node drains.
+type DbaasPodDisruptionBudget struct {
+	core.DefaultExecutable
+}
+
+func (r *DbaasPodDisruptionBudget) Execute(ctx core.ExecutionContext) error {
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
+	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
+	log := ctx.Get(constants.ContextLogger).(*zap.Logger)
+	spec := ctx.Get(constants.ContextSpec).(*v2.CassandraSupplService)
+
+	template := DbaasPodDisruptionBudgetTemplate(request.Namespace)
+	budget := &policyv1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: template.Name, Namespace: template.Namespace}}
+
+	if !spec.Spec.Dbaas.PDB || DbaasReplicas(spec) < 2 {
+		err := core.DeleteRuntimeObject(kubeClient, budget)
+		core.PanicError(err, log.Error, "Dbaas PodDisruptionBudget deletion failed")
+		return nil
+	}
+
+	// the budget is updated in place, so that the pods are never left without it
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), kubeClient, budget, func() error {
+		if budget.Labels == nil {
+			budget.Labels = map[string]string{}
+		}
+		for key, value := range template.Labels {
+			budget.Labels[key] = value
+		}
+		budget.Spec.Selector = template.Spec.Selector
+		budget.Spec.MinAvailable = template.Spec.MinAvailable
+		budget.Spec.MaxUnavailable = template.Spec.MaxUnavailable
+		return nil
+	})
+	core.PanicError(err, log.Error, "Dbaas PodDisruptionBudget processing failed")
+
+	log.Debug("Dbaas PodDisruptionBudget has been updated")
+
+	return nil
+}
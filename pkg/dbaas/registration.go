@@ -0,0 +1,243 @@
+package dbaas
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"go.uber.org/zap"
+	v13 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	registrationCheckInterval = 5 * time.Minute
+	// a deleted custom resource is released with a Warning event if the physical database is not deregistered by then
+	deregistrationTimeout = 30 * time.Minute
+)
+
+// RegistrationFinalizer holds the custom resource until the physical database is removed from DBaaS aggregator
+const RegistrationFinalizer = "netcracker.com/dbaas-registration"
+
+// SkipDeregistrationAnnotation set to "true" releases a deleted custom resource without deregistering the physical database
+const SkipDeregistrationAnnotation = "netcracker.com/skip-dbaas-deregistration"
+
+// PhysicalDatabaseIdentifier returns the identifier the adapter registers the physical database with
+func PhysicalDatabaseIdentifier(spec *v1.CassandraSupplService, namespace string) string {
+	if spec.Spec.Dbaas.Aggregator == nil {
+		return namespace
+	}
+	return core.OptionalString(spec.Spec.Dbaas.Aggregator.PhysicalDatabaseIdentifier, namespace)
+}
+
+// RegistrationManager tracks the physical database registration the adapter makes in DBaaS aggregator.
+// It deregisters the physical database when dbaas is uninstalled, the custom resource is deleted
+// or the physical database identifier changes.
+type RegistrationManager struct {
+	Client        client.Client
+	ClientBuilder AggregatorClientBuilder
+	Recorder      record.EventRecorder
+}
+
+// Run records the registration in `status.dbaas.registration` and returns the interval of the next check,
+// zero if there is nothing to track
+func (r *RegistrationManager) Run(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+	if !spec.DeletionTimestamp.IsZero() {
+		return 0, nil
+	}
+
+	var status *v1.RegistrationStatus
+	if spec.Status.Dbaas != nil {
+		status = spec.Status.Dbaas.Registration
+	}
+	configured := aggregatorConfigured(spec)
+	now := time.Now()
+
+	if !spec.Spec.Dbaas.Install || !configured {
+		if status == nil && !controllerutil.ContainsFinalizer(spec, RegistrationFinalizer) {
+			return 0, nil
+		}
+		if configured {
+			// a failed deregistration is retried on the interval
+			if status != nil && status.Result == ResultFailed && status.LastCheckTime != nil {
+				if due := status.LastCheckTime.Add(registrationCheckInterval).Sub(now); due > 0 {
+					return due, nil
+				}
+			}
+			if err := r.uninstall(ctx, spec, request.Namespace, log); err != nil {
+				return registrationCheckInterval, r.updateStatus(ctx, spec, &v1.RegistrationStatus{
+					PhysicalDatabaseIdentifier: PhysicalDatabaseIdentifier(spec, request.Namespace),
+					Registered:                 status != nil && status.Registered,
+					LastCheckTime:              &metav1.Time{Time: now},
+					Result:                     ResultFailed,
+					Message:                    err.Error(),
+				}, err)
+			}
+		}
+		if controllerutil.RemoveFinalizer(spec, RegistrationFinalizer) {
+			if err := r.Client.Update(ctx, spec); err != nil {
+				return registrationCheckInterval, fmt.Errorf("failed to remove finalizer %s: %w", RegistrationFinalizer, err)
+			}
+		}
+		return 0, r.updateStatus(ctx, spec, nil, nil)
+	}
+
+	if controllerutil.AddFinalizer(spec, RegistrationFinalizer) {
+		if err := r.Client.Update(ctx, spec); err != nil {
+			return registrationCheckInterval, fmt.Errorf("failed to add finalizer %s: %w", RegistrationFinalizer, err)
+		}
+	}
+
+	identifier := PhysicalDatabaseIdentifier(spec, request.Namespace)
+	// a changed identifier is handled at once, otherwise the aggregator is asked on the interval
+	if status != nil && status.LastCheckTime != nil && status.PhysicalDatabaseIdentifier == identifier {
+		if due := status.LastCheckTime.Add(registrationCheckInterval).Sub(now); due > 0 {
+			return due, nil
+		}
+	}
+	current := &v1.RegistrationStatus{PhysicalDatabaseIdentifier: identifier, LastCheckTime: &metav1.Time{Time: now}}
+	checkErr := r.check(ctx, spec, request.Namespace, status, current, log)
+	current.Result, current.Message = ResultSuccessful, ""
+	if checkErr != nil {
+		current.Result, current.Message = ResultFailed, checkErr.Error()
+		if status != nil {
+			// the last known state is kept until the aggregator answers again
+			current.Registered, current.AdapterAddress = status.Registered, status.AdapterAddress
+			if status.PhysicalDatabaseIdentifier != identifier {
+				current.PhysicalDatabaseIdentifier = status.PhysicalDatabaseIdentifier
+			}
+		}
+	}
+	return registrationCheckInterval, r.updateStatus(ctx, spec, current, checkErr)
+}
+
+func (r *RegistrationManager) check(ctx context.Context, spec *v1.CassandraSupplService, namespace string,
+	previous, current *v1.RegistrationStatus, log *zap.Logger) error {
+	aggregatorClient, err := r.ClientBuilder.Build(r.Client, spec, namespace)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && previous.PhysicalDatabaseIdentifier != "" && previous.PhysicalDatabaseIdentifier != current.PhysicalDatabaseIdentifier {
+		if err := r.deregister(aggregatorClient, spec, previous.PhysicalDatabaseIdentifier, log); err != nil {
+			return err
+		}
+	}
+
+	database, found, err := aggregatorClient.PhysicalDatabase(current.PhysicalDatabaseIdentifier)
+	if err != nil {
+		return err
+	}
+	current.Registered = found
+	if found {
+		current.AdapterAddress = database.AdapterAddress
+	} else if previous != nil && previous.Registered && previous.PhysicalDatabaseIdentifier == current.PhysicalDatabaseIdentifier {
+		r.Recorder.Event(spec, v12.EventTypeWarning, "PhysicalDatabaseNotRegistered",
+			fmt.Sprintf("physical database %s is no longer registered in DBaaS aggregator", current.PhysicalDatabaseIdentifier))
+	}
+	return nil
+}
+
+// Finalize deregisters the physical database of a deleted custom resource and releases it.
+// The custom resource is released without deregistration when SkipDeregistrationAnnotation is set
+// or the aggregator does not accept the deregistration within deregistrationTimeout.
+// It returns true while the custom resource is being deleted, so it is not reconciled.
+func (r *RegistrationManager) Finalize(ctx context.Context, request reconcile.Request, log *zap.Logger) (bool, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if spec.DeletionTimestamp.IsZero() {
+		return false, nil
+	}
+	if !controllerutil.ContainsFinalizer(spec, RegistrationFinalizer) {
+		return true, nil
+	}
+
+	identifier := PhysicalDatabaseIdentifier(spec, request.Namespace)
+	if !aggregatorConfigured(spec) {
+		log.Info("DBaaS aggregator is not configured, the physical database is not deregistered")
+	} else if spec.Annotations[SkipDeregistrationAnnotation] == "true" {
+		r.Recorder.Event(spec, v12.EventTypeWarning, "PhysicalDatabaseNotDeregistered",
+			fmt.Sprintf("physical database %s is left in DBaaS aggregator, deregistration is skipped by %s annotation", identifier, SkipDeregistrationAnnotation))
+	} else if err := r.uninstall(ctx, spec, request.Namespace, log); err != nil {
+		// credentials removed together with the custom resource or an aggregator gone for good must not block its deletion
+		if !errors.IsNotFound(err) && time.Since(spec.DeletionTimestamp.Time) < deregistrationTimeout {
+			return true, err
+		}
+		r.Recorder.Event(spec, v12.EventTypeWarning, "PhysicalDatabaseNotDeregistered",
+			fmt.Sprintf("physical database %s is left in DBaaS aggregator: %v", identifier, err))
+	}
+	controllerutil.RemoveFinalizer(spec, RegistrationFinalizer)
+	if err := r.Client.Update(ctx, spec); err != nil {
+		return true, fmt.Errorf("failed to remove finalizer %s: %w", RegistrationFinalizer, err)
+	}
+	return true, nil
+}
+
+func aggregatorConfigured(spec *v1.CassandraSupplService) bool {
+	aggregator := spec.Spec.Dbaas.Aggregator
+	return aggregator != nil && aggregator.DbaasAggregatorRegistrationAddress != ""
+}
+
+// uninstall stops the adapter, it registers the physical database again while it runs, and deregisters
+// the current and the last recorded physical database
+func (r *RegistrationManager) uninstall(ctx context.Context, spec *v1.CassandraSupplService, namespace string, log *zap.Logger) error {
+	err := core.DeleteRuntimeObject(r.Client, &v13.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: utils.DbaasName, Namespace: namespace},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete deployment %s: %w", utils.DbaasName, err)
+	}
+
+	aggregatorClient, err := r.ClientBuilder.Build(r.Client, spec, namespace)
+	if err != nil {
+		return err
+	}
+	identifiers := []string{PhysicalDatabaseIdentifier(spec, namespace)}
+	if spec.Status.Dbaas != nil && spec.Status.Dbaas.Registration != nil {
+		if recorded := spec.Status.Dbaas.Registration.PhysicalDatabaseIdentifier; recorded != "" && recorded != identifiers[0] {
+			identifiers = append(identifiers, recorded)
+		}
+	}
+	for _, identifier := range identifiers {
+		if err := r.deregister(aggregatorClient, spec, identifier, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RegistrationManager) deregister(aggregatorClient AggregatorClient, spec *v1.CassandraSupplService, identifier string, log *zap.Logger) error {
+	if err := aggregatorClient.Deregister(identifier); err != nil {
+		return fmt.Errorf("failed to deregister physical database %s: %w", identifier, err)
+	}
+	log.Info(fmt.Sprintf("Physical database %s is deregistered from DBaaS aggregator", identifier))
+	r.Recorder.Event(spec, v12.EventTypeNormal, "PhysicalDatabaseDeregistered",
+		fmt.Sprintf("physical database %s is deregistered from DBaaS aggregator", identifier))
+	return nil
+}
+
+func (r *RegistrationManager) updateStatus(ctx context.Context, spec *v1.CassandraSupplService, status *v1.RegistrationStatus, err error) error {
+	if spec.Status.Dbaas == nil {
+		spec.Status.Dbaas = &v1.DbaasStatus{}
+	}
+	spec.Status.Dbaas.Registration = status
+	if updateErr := r.Client.Status().Update(ctx, spec); updateErr != nil {
+		return fmt.Errorf("failed to update registration status: %w", updateErr)
+	}
+	return err
+}
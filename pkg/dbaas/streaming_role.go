@@ -0,0 +1,392 @@
+package dbaas
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
+	"github.com/Netcracker/qubership-cql-driver"
+	"github.com/gocql/gocql"
+	"go.uber.org/zap"
+	v13 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultPasswordRotationPeriod = 720 * time.Hour
+	defaultRoleCheckInterval      = 10 * time.Minute
+	streamingRolePasswordLength   = 24
+)
+
+const (
+	ResultSuccessful = "Successful"
+	ResultFailed     = "Failed"
+)
+
+// the resource of all keyspaces in system_auth.role_permissions
+const allKeyspacesResource = "data"
+
+// permissions Cassandra stores for GRANT ALL ON ALL KEYSPACES
+var allKeyspacesPermissions = []string{"ALTER", "AUTHORIZE", "CREATE", "DROP", "MODIFY", "SELECT"}
+
+// RoleStore reads and changes Cassandra roles
+type RoleStore interface {
+	// RolePermissions returns the permissions granted to the role by resource, found is false when the role does not exist
+	RolePermissions(role string) (permissions map[string][]string, found bool, err error)
+	Exec(statements ...string) error
+}
+
+type RoleStoreBuilder interface {
+	Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (RoleStore, error)
+}
+
+type CQLRoleStoreBuilder struct{}
+
+func (b *CQLRoleStoreBuilder) Build(kubeClient client.Client, spec *v1.CassandraSupplService, namespace string) (RoleStore, error) {
+	// role changes must reach every node before the adapter hands out the credentials
+	cluster, err := utils.NewCassandraCluster(kubeClient, spec, namespace, "system_auth", gocql.Quorum)
+	if err != nil {
+		return nil, err
+	}
+	return &cqlRoleStore{cluster: cluster}, nil
+}
+
+type cqlRoleStore struct {
+	cluster cql.Cluster
+}
+
+func (s *cqlRoleStore) RolePermissions(role string) (map[string][]string, bool, error) {
+	var found bool
+	permissions := map[string][]string{}
+	err := cql.ExecInAutoCloseSession(s.cluster, func(session cql.Session) error {
+		var name string
+		iter := session.Query("SELECT role FROM system_auth.roles WHERE role = ?", role).Iter()
+		found = iter.Scan(&name)
+		if err := iter.Close(); err != nil {
+			return fmt.Errorf("failed to read role %s: %w", role, err)
+		}
+
+		var resource string
+		var granted []string
+		iter = session.Query("SELECT resource, permissions FROM system_auth.role_permissions WHERE role = ?", role).Iter()
+		for iter.Scan(&resource, &granted) {
+			permissions[resource] = granted
+			granted = nil
+		}
+		if err := iter.Close(); err != nil {
+			return fmt.Errorf("failed to read permissions of role %s: %w", role, err)
+		}
+		return nil
+	})
+	return permissions, found, err
+}
+
+func (s *cqlRoleStore) Exec(statements ...string) error {
+	return cql.ExecInAutoCloseSession(s.cluster, func(session cql.Session) error {
+		for _, statement := range statements {
+			if err := session.Query(statement).Exec(false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// StreamingRoleManager keeps the DBaaS streaming role in Cassandra in line with the `dbaas-streaming-role` secret.
+// It rotates the role password and restores missing grants. Grants changed outside the operator are reported as drift,
+// unexpected ones are revoked only with `revokeUnexpectedPermissions`.
+// The operator owns the `password` and `pendingPassword` keys and the rotation annotation of the secret,
+// Helm must not render them or an upgrade brings back a password the role no longer has.
+type StreamingRoleManager struct {
+	Client   client.Client
+	Builder  RoleStoreBuilder
+	Recorder record.EventRecorder
+}
+
+// Run returns the interval after which the role should be checked again, zero if the role is not managed
+func (r *StreamingRoleManager) Run(ctx context.Context, request reconcile.Request, log *zap.Logger) (time.Duration, error) {
+	spec := &v1.CassandraSupplService{}
+	if err := r.Client.Get(ctx, request.NamespacedName, spec); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+
+	role := spec.Spec.Dbaas.StreamingRole
+	if !spec.Spec.Dbaas.Install || !role.Managed || spec.Spec.AWSKeyspaces.Install {
+		return 0, nil
+	}
+	interval, err := utils.ParseDurationOrDefault(role.CheckInterval, defaultRoleCheckInterval)
+	if err != nil {
+		return defaultRoleCheckInterval, fmt.Errorf("invalid dbaas.streamingRole.checkInterval: %w", err)
+	}
+	rotationPeriod, err := utils.ParseDurationOrDefault(role.PasswordRotationPeriod, defaultPasswordRotationPeriod)
+	if err != nil {
+		return interval, fmt.Errorf("invalid dbaas.streamingRole.passwordRotationPeriod: %w", err)
+	}
+
+	if spec.Status.Dbaas == nil {
+		spec.Status.Dbaas = &v1.DbaasStatus{}
+	}
+	status := spec.Status.Dbaas.StreamingRole
+	if status == nil {
+		status = &v1.StreamingRoleStatus{}
+	}
+
+	now := time.Now()
+	if status.LastCheckTime != nil {
+		if due := status.LastCheckTime.Add(interval).Sub(now); due > 0 {
+			return due, nil
+		}
+	}
+	syncErr := r.sync(ctx, spec, request.Namespace, status, rotationPeriod, now, log)
+	status.LastCheckTime = &metav1.Time{Time: now}
+	status.Result, status.Message = ResultSuccessful, ""
+	if syncErr != nil {
+		status.Result, status.Message = ResultFailed, syncErr.Error()
+	}
+	spec.Status.Dbaas.StreamingRole = status
+	if err := r.Client.Status().Update(ctx, spec); err != nil {
+		return interval, fmt.Errorf("failed to update streaming role status: %w", err)
+	}
+	return interval, syncErr
+}
+
+func (r *StreamingRoleManager) sync(ctx context.Context, spec *v1.CassandraSupplService, namespace string,
+	status *v1.StreamingRoleStatus, rotationPeriod time.Duration, now time.Time, log *zap.Logger) error {
+	role := spec.Spec.Dbaas.StreamingRole
+	secret := &v12.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: utils.DbaasAdminRoleCreds, Namespace: namespace}, secret); err != nil {
+		return fmt.Errorf("failed to read secret %s: %w", utils.DbaasAdminRoleCreds, err)
+	}
+	name := string(secret.Data[utils.Name])
+	if name == "" {
+		return fmt.Errorf("secret %s has no role %s", utils.DbaasAdminRoleCreds, utils.Name)
+	}
+	permissions, err := ParseRolePermissions(string(secret.Data[utils.Roles]))
+	if err != nil {
+		return err
+	}
+
+	store, err := r.Builder.Build(r.Client, spec, namespace)
+	if err != nil {
+		return err
+	}
+	granted, found, err := store.RolePermissions(name)
+	if err != nil {
+		return err
+	}
+
+	var statements []string
+	currentPassword := string(secret.Data[utils.Password])
+	password := currentPassword
+	rotated, recorded := passwordRotationTime(secret)
+	if pending := string(secret.Data[utils.PendingPassword]); pending != "" {
+		// the role may have been altered already by the interrupted rotation, it is altered again with the same password
+		password = pending
+	} else if password == "" || (recorded && now.Sub(rotated) >= rotationPeriod) {
+		if password, err = generatePassword(streamingRolePasswordLength); err != nil {
+			return err
+		}
+		// the password is stored before the role gets it, so it is not lost if the rotation is interrupted
+		if err := r.updateSecret(ctx, secret, func() { secret.Data[utils.PendingPassword] = []byte(password) }); err != nil {
+			return err
+		}
+	}
+	if !found {
+		log.Info(fmt.Sprintf("Creating DBaaS streaming role %s", name))
+		statements = append(statements, fmt.Sprintf("CREATE ROLE IF NOT EXISTS %s WITH PASSWORD = %s AND LOGIN = true", quoteIdentifier(name), quoteLiteral(password)))
+		granted = map[string][]string{}
+	} else if password != currentPassword {
+		statements = append(statements, fmt.Sprintf("ALTER ROLE %s WITH PASSWORD = %s", quoteIdentifier(name), quoteLiteral(password)))
+	}
+
+	grants, drift := permissionChanges(name, granted, permissions, role.RevokeUnexpectedPermissions)
+	// differences are drift unless the role is new or the secret has changed since the last check
+	if found && status.Name == name && slices.Equal(status.Permissions, permissions) && len(drift) > 0 {
+		status.Drift = drift
+		action := "missing grants are restored"
+		if role.RevokeUnexpectedPermissions {
+			action = "grants are restored"
+		}
+		r.Recorder.Event(spec, v12.EventTypeWarning, "StreamingRoleDrift",
+			fmt.Sprintf("grants of DBaaS streaming role %s were changed outside the operator, %s: %s", name, action, strings.Join(drift, "; ")))
+	} else {
+		status.Drift = nil
+	}
+	statements = append(statements, grants...)
+
+	if len(statements) > 0 {
+		if err := store.Exec(statements...); err != nil {
+			return fmt.Errorf("failed to apply DBaaS streaming role %s: %w", name, err)
+		}
+	}
+	status.Name, status.Permissions = name, permissions
+
+	if password != currentPassword {
+		err := r.updateSecret(ctx, secret, func() {
+			secret.Data[utils.Password] = []byte(password)
+			delete(secret.Data, utils.PendingPassword)
+			secret.Annotations[utils.PasswordRotatedAnnotation] = now.UTC().Format(time.RFC3339)
+		})
+		if err != nil {
+			return err
+		}
+		status.PasswordRotationTime = &metav1.Time{Time: now}
+		r.Recorder.Event(spec, v12.EventTypeNormal, "StreamingRolePasswordRotated",
+			fmt.Sprintf("password of DBaaS streaming role %s was rotated", name))
+		return r.rollAdapter(ctx, namespace, now)
+	}
+	if !recorded {
+		// the rotation period of a password the operator did not generate starts with the first check
+		return r.updateSecret(ctx, secret, func() {
+			secret.Annotations[utils.PasswordRotatedAnnotation] = now.UTC().Format(time.RFC3339)
+		})
+	}
+	return nil
+}
+
+// passwordRotationTime returns the time of the last rotation, false if it is not recorded
+func passwordRotationTime(secret *v12.Secret) (time.Time, bool) {
+	rotated, err := time.Parse(time.RFC3339, secret.Annotations[utils.PasswordRotatedAnnotation])
+	return rotated, err == nil
+}
+
+func (r *StreamingRoleManager) updateSecret(ctx context.Context, secret *v12.Secret, mutate func()) error {
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	mutate()
+	if err := r.Client.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to store the streaming role password: %w", err)
+	}
+	return nil
+}
+
+// rollAdapter restarts the adapter pods, they read the streaming role password on startup
+func (r *StreamingRoleManager) rollAdapter(ctx context.Context, namespace string, now time.Time) error {
+	deployment := &v13.Deployment{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: utils.DbaasName, Namespace: namespace}, deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[utils.PasswordRotatedAnnotation] = now.UTC().Format(time.RFC3339)
+	return r.Client.Update(ctx, deployment)
+}
+
+// ParseRolePermissions reads the `roles` value of the streaming role secret, e.g. `[ALL]` or `SELECT, MODIFY`
+func ParseRolePermissions(value string) ([]string, error) {
+	fields := strings.FieldsFunc(strings.Trim(value, "[] \n"), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+	permissions := map[string]bool{}
+	for _, field := range fields {
+		permission := strings.ToUpper(strings.Trim(field, `"'`))
+		switch {
+		case permission == "ALL":
+			for _, p := range allKeyspacesPermissions {
+				permissions[p] = true
+			}
+		case slices.Contains(allKeyspacesPermissions, permission):
+			permissions[permission] = true
+		default:
+			return nil, fmt.Errorf("unknown permission %q in secret %s", field, utils.DbaasAdminRoleCreds)
+		}
+	}
+	if len(permissions) == 0 {
+		return nil, fmt.Errorf("secret %s has no role permissions", utils.DbaasAdminRoleCreds)
+	}
+	result := make([]string, 0, len(permissions))
+	for permission := range permissions {
+		result = append(result, permission)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// permissionChanges returns GRANT statements of the expected permissions missing on all keyspaces, REVOKE statements
+// of the other granted permissions if revoke is set, and the differences in a readable form
+func permissionChanges(role string, granted map[string][]string, expected []string, revoke bool) ([]string, []string) {
+	var statements, changes []string
+	for _, permission := range expected {
+		if !slices.Contains(granted[allKeyspacesResource], permission) {
+			statements = append(statements, fmt.Sprintf("GRANT %s ON ALL KEYSPACES TO %s", permission, quoteIdentifier(role)))
+			changes = append(changes, fmt.Sprintf("%s on %s is missing", permission, allKeyspacesResource))
+		}
+	}
+
+	resources := make([]string, 0, len(granted))
+	for resource := range granted {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		for _, permission := range granted[resource] {
+			if resource == allKeyspacesResource && slices.Contains(expected, permission) {
+				continue
+			}
+			changes = append(changes, fmt.Sprintf("%s on %s is not expected", permission, resource))
+			if target, ok := cqlResource(resource); ok && revoke {
+				statements = append(statements, fmt.Sprintf("REVOKE %s ON %s FROM %s", permission, target, quoteIdentifier(role)))
+			}
+		}
+	}
+	return statements, changes
+}
+
+// cqlResource converts a system_auth resource name to the CQL grant target
+func cqlResource(resource string) (string, bool) {
+	parts := strings.Split(resource, "/")
+	switch {
+	case resource == "data":
+		return "ALL KEYSPACES", true
+	case parts[0] == "data" && len(parts) == 2:
+		return "KEYSPACE " + quoteIdentifier(parts[1]), true
+	case parts[0] == "data" && len(parts) == 3:
+		return "TABLE " + quoteIdentifier(parts[1]) + "." + quoteIdentifier(parts[2]), true
+	case resource == "roles":
+		return "ALL ROLES", true
+	case parts[0] == "roles" && len(parts) == 2:
+		return "ROLE " + quoteIdentifier(parts[1]), true
+	case resource == "functions":
+		return "ALL FUNCTIONS", true
+	case resource == "mbean":
+		return "ALL MBEANS", true
+	}
+	return "", false
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func generatePassword(length int) (string, error) {
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(utils.Charset))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = utils.Charset[n.Int64()]
+	}
+	return string(password), nil
+}
@@ -1,9 +1,15 @@
 package dbaas
 
 import (
+	"fmt"
+
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
 	v12 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -13,10 +19,11 @@ func DbaasDeploymentTemplate(namespace string,
 	nodeSelector map[string]string,
 	resources v1.ResourceRequirements,
 	env []v1.EnvVar,
-	port int32) *v12.Deployment {
+	port int32,
+	replicas int32) *v12.Deployment {
 
-	var replicas int32 = 1
 	allowPrivilegeEscalation := false
+	maxUnavailable, maxSurge := intstr.FromInt32(0), intstr.FromInt32(1)
 	dc := &v12.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      utils.DbaasName,
@@ -34,6 +41,14 @@ func DbaasDeploymentTemplate(namespace string,
 				},
 			},
 			Replicas: &replicas,
+			// a new pod is ready before an old one is stopped
+			Strategy: v12.DeploymentStrategy{
+				Type: v12.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &v12.RollingUpdateDeployment{
+					MaxUnavailable: &maxUnavailable,
+					MaxSurge:       &maxSurge,
+				},
+			},
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Namespace: namespace,
@@ -67,40 +82,45 @@ func DbaasDeploymentTemplate(namespace string,
 									MountPath: "/app/config",
 								},
 							},
-							LivenessProbe: &v1.Probe{
-								ProbeHandler: v1.ProbeHandler{
-									TCPSocket: &v1.TCPSocketAction{
-										Port: intstr.IntOrString{Type: intstr.Int, IntVal: port},
+						},
+					},
+					NodeSelector: nodeSelector,
+					Affinity: &v1.Affinity{
+						PodAntiAffinity: &v1.PodAntiAffinity{
+							PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+								{
+									Weight: 100,
+									PodAffinityTerm: v1.PodAffinityTerm{
+										LabelSelector: &metav1.LabelSelector{
+											MatchLabels: map[string]string{
+												utils.Name: utils.DbaasName,
+											},
+										},
+										TopologyKey: utils.HostnameTopologyKey,
 									},
 								},
-								InitialDelaySeconds: 5,
-								TimeoutSeconds:      5,
-								PeriodSeconds:       7,
-								SuccessThreshold:    1,
-								FailureThreshold:    12,
 							},
-							ReadinessProbe: &v1.Probe{
-								ProbeHandler: v1.ProbeHandler{
-									TCPSocket: &v1.TCPSocketAction{
-										Port: intstr.IntOrString{Type: intstr.Int, IntVal: port},
-									},
+						},
+					},
+					TopologySpreadConstraints: []v1.TopologySpreadConstraint{
+						{
+							MaxSkew:           1,
+							TopologyKey:       utils.ZoneTopologyKey,
+							WhenUnsatisfiable: v1.ScheduleAnyway,
+							LabelSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									utils.Name: utils.DbaasName,
 								},
-								InitialDelaySeconds: 5,
-								TimeoutSeconds:      5,
-								PeriodSeconds:       7,
-								SuccessThreshold:    1,
-								FailureThreshold:    12,
 							},
 						},
 					},
-					NodeSelector: nodeSelector,
 					Volumes: []v1.Volume{
 						{
 							Name: "dbaas-physical-databases-labels-mount",
 							VolumeSource: v1.VolumeSource{
 								ConfigMap: &v1.ConfigMapVolumeSource{
 									LocalObjectReference: v1.LocalObjectReference{
-										Name: "nc-dbaas-physical-databases-labels",
+										Name: utils.DbaasPhysicalDatabasesLabels,
 									},
 									DefaultMode: func() *int32 {
 										mode := int32(420) // Decimal representation of 0644
@@ -116,3 +136,85 @@ func DbaasDeploymentTemplate(namespace string,
 	}
 	return dc
 }
+
+func DbaasPodDisruptionBudgetTemplate(namespace string) *policyv1.PodDisruptionBudget {
+	budget := intstr.FromInt32(1)
+	spec := policyv1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				utils.Name: utils.DbaasName,
+			},
+		},
+		MaxUnavailable: &budget,
+	}
+
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.DbaasName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				utils.Name: utils.DbaasName,
+			},
+		},
+		Spec: spec,
+	}
+}
+
+func DbaasAutoscalerTemplate(namespace string, autoscaling v2.DbaasAutoscaling) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	var metrics []autoscalingv2.MetricSpec
+	resourceMetric := func(name v1.ResourceName, utilization *int32) {
+		if utilization == nil {
+			return
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: name,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: utilization,
+				},
+			},
+		})
+	}
+	resourceMetric(v1.ResourceCPU, autoscaling.TargetCPUUtilization)
+	resourceMetric(v1.ResourceMemory, autoscaling.TargetMemoryUtilization)
+
+	if metric := autoscaling.CustomMetric; metric != nil {
+		value, err := resource.ParseQuantity(metric.TargetAverageValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dbaas.autoscaling.customMetric.targetAverageValue: %w", err)
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: metric.Name},
+				Target: autoscalingv2.MetricTarget{
+					Type:         autoscalingv2.AverageValueMetricType,
+					AverageValue: &value,
+				},
+			},
+		})
+	}
+
+	minReplicas := max(autoscaling.MinReplicas, 1)
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.DbaasName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				utils.Name: utils.DbaasName,
+			},
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       utils.DbaasName,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: max(autoscaling.MaxReplicas, minReplicas),
+			Metrics:     metrics,
+		},
+	}, nil
+}
@@ -11,6 +11,7 @@ import (
 	coreUtils "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/utils"
 	"go.uber.org/zap"
 	v12 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -19,6 +20,7 @@ type RobotDeployment struct {
 }
 
 func (r *RobotDeployment) Execute(ctx core.ExecutionContext) error {
+	kubeClient := ctx.Get(constants.ContextClient).(client.Client)
 	request := ctx.Get(constants.ContextRequest).(reconcile.Request)
 	spec := ctx.Get(constants.ContextSpec).(*v1.CassandraSupplService)
 	robot := spec.Spec.RobotTests
@@ -38,21 +40,29 @@ func (r *RobotDeployment) Execute(ctx core.ExecutionContext) error {
 		},
 	}
 
-	currentDc := utils.NewStream(spec.Spec.Cassandra.DeploymentSchema.DataCenters).FindFirst(func(dc interface{}) bool {
-		return dc.(*v1.DataCenter).Deploy
-	}).(*v1.DataCenter)
+	if spec.Spec.AWSKeyspaces.Install {
+		// AWS Keyspaces has no nodes and data centers to check
+		envs = append(envs, utils.AWSKeyspacesEnvs(spec.Spec.AWSKeyspaces, "CASSANDRA_HOST", "CASSANDRA_PORT")...)
+		envs = append(envs, coreUtils.GetPlainTextEnvVar("EXCLUDE_TAGS", utils.AWSKeyspacesExcludedTags))
+	} else {
+		currentDc := utils.NewStream(spec.Spec.Cassandra.DeploymentSchema.DataCenters).FindFirst(func(dc interface{}) bool {
+			return dc.(*v1.DataCenter).Deploy
+		}).(*v1.DataCenter)
+
+		envs = append(envs,
+			coreUtils.GetPlainTextEnvVar("CASSANDRA_HOST", core.OptionalString(spec.Spec.Cassandra.Host, fmt.Sprintf("%s.%s", utils.Cassandra, request.Namespace))),
+			coreUtils.GetPlainTextEnvVar("CASSANDRA_PORT", core.OptionalString(strconv.Itoa(spec.Spec.Cassandra.Port), "9042")),
+			coreUtils.GetPlainTextEnvVar("DC_NAME", currentDc.Name),
+		)
+		envs = append(envs, utils.CassandraCredentialEnvs(spec.Spec)...)
+	}
 
 	envs = append(envs,
-		coreUtils.GetPlainTextEnvVar("CASSANDRA_HOST", core.OptionalString(spec.Spec.Cassandra.Host, fmt.Sprintf("%s.%s", utils.Cassandra, request.Namespace))),
-		coreUtils.GetPlainTextEnvVar("CASSANDRA_PORT", core.OptionalString(strconv.Itoa(spec.Spec.Cassandra.Port), "9042")),
-		coreUtils.GetSecretEnvVar("CASSANDRA_USERNAME", spec.Spec.Cassandra.SecretName, utils.Username),
-		coreUtils.GetSecretEnvVar("CASSANDRA_PASSWORD", spec.Spec.Cassandra.SecretName, utils.Password),
 		coreUtils.GetPlainTextEnvVar("TEST_KEYSPACES_REPLICATION_FACTOR", strconv.Itoa(robot.ReplicationFactor)),
 		coreUtils.GetPlainTextEnvVar("ATTEMPTS_NUMBER", strconv.Itoa(robot.AttemptsNumber)),
 		coreUtils.GetPlainTextEnvVar("PROMETHEUS_URL", robot.PrometheusUrl),
 		coreUtils.GetPlainTextEnvVar("TAGS", robot.Tags),
 		coreUtils.GetPlainTextEnvVar("WAIT_TIMEOUT", strconv.Itoa(spec.Spec.WaitTimeout)),
-		coreUtils.GetPlainTextEnvVar("DC_NAME", currentDc.Name),
 		coreUtils.GetPlainTextEnvVar("DBAAS_ADAPTER_API_VERSION", spec.Spec.Dbaas.ApiVersion),
 		coreUtils.GetPlainTextEnvVar("PORT", fmt.Sprint(utils.GetHTTPPort(spec.Spec.TLS.Enabled))),
 		coreUtils.GetPlainTextEnvVar("CONFIG_NAME", "cassandra-tests-config"),
@@ -74,6 +84,8 @@ func (r *RobotDeployment) Execute(ctx core.ExecutionContext) error {
 	if spec.Spec.Dbaas.Install {
 		envs = append(envs,
 			coreUtils.GetPlainTextEnvVar("DBAAS_HOST", fmt.Sprintf("%s.%s.svc", utils.DbaasName, request.Namespace)),
+			coreUtils.GetPlainTextEnvVar("DBAAS_PORT", fmt.Sprint(utils.GetHTTPPort(utils.DbaasServerTLS(spec.Spec)))),
+			coreUtils.GetPlainTextEnvVar("DBAAS_PROTOCOL", utils.GetHTTPProtocol(utils.DbaasServerTLS(spec.Spec))),
 			coreUtils.GetSecretEnvVar("DBAAS_ADAPTER_USERNAME", spec.Spec.Dbaas.Adapter.SecretName, utils.Username),
 			coreUtils.GetSecretEnvVar("DBAAS_ADAPTER_PASSWORD", spec.Spec.Dbaas.Adapter.SecretName, utils.Password),
 		)
@@ -89,7 +101,7 @@ func (r *RobotDeployment) Execute(ctx core.ExecutionContext) error {
 		envs,
 		spec.Spec.RobotTests.Args)
 
-	err := credsManager.AddCredHashToPodTemplate([]string{spec.Spec.Cassandra.SecretName}, &dc.Spec.Template)
+	err := credsManager.AddCredHashToPodTemplate(utils.CassandraSecretNames(spec.Spec), &dc.Spec.Template)
 	if err != nil {
 		log.Error(fmt.Sprintf("can't add secret HASH to annotations for %s", dc.Name), zap.Error(err))
 		return err
@@ -99,6 +111,10 @@ func (r *RobotDeployment) Execute(ctx core.ExecutionContext) error {
 
 	coreUtils.VaultPodSpec(&dc.Spec.Template.Spec, robotArgs, spec.Spec.VaultRegistration)
 	utils.TLSClientSpecUpdate(&dc.Spec.Template.Spec, utils.RootCertPath, spec.Spec.TLS)
+	utils.KeystoresSpecUpdate(&dc.Spec.Template.Spec, spec.Spec, utils.Robot)
+	utils.CassandraClientCertSpecUpdate(&dc.Spec.Template.Spec, spec.Spec)
+	err = utils.AddCertificateHashToPodTemplate(kubeClient, &dc.Spec.Template, spec.Spec, utils.Robot, request.Namespace)
+	core.PanicError(err, log.Error, "RobotTests certificate reading failed")
 
 	err = helperImpl.DeleteDeploymentAndPods(dc.Name, request.Namespace, spec.Spec.WaitTimeout)
 
@@ -1,9 +1,9 @@
 package pkg
 
 import (
-	"github.com/Netcracker/qubership-cql-driver"
 	v1 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/backup"
+	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/common"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/dbaas"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/robotTests"
 	"github.com/Netcracker/qubership-cassandra-supplementary/pkg/utils"
@@ -12,6 +12,7 @@ import (
 	"go.uber.org/zap"
 	v1core "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
 )
 
 type CassandraServicesCompound struct {
@@ -36,8 +37,9 @@ func (r *CassandraServiceBuilder) Build(ctx core.ExecutionContext) core.Executab
 	}
 
 	ctx.Set(utils.KubernetesHelperImpl, defaultKubernetesHelper)
-	ctx.Set(utils.ContextClusterBuilder, &cql.ClusterBuilderImpl{})
+	ctx.Set(utils.ContextClusterBuilder, &utils.CassandraClusterBuilder{})
 	ctx.Set(utils.ContextCredsManager, &utils.CredsManager{})
+	ctx.Set(utils.ContextBackupDaemonClientBuilder, &backup.DaemonClientBuilderImpl{})
 
 	// Default tries for wait or init operations (e.g. hosts unreachable)
 	ctx.Set(utils.TriesCount, 5)
@@ -62,22 +64,46 @@ func (r *CassandraServiceBuilder) Build(ctx core.ExecutionContext) core.Executab
 	core.PanicError(commonParamCheckErr, log.Error, "Error happened during checking common parameters for changes")
 	ctx.Set(constants.IsAnyCommonParameterChanged, isAnyParamChanged)
 
+	// the same checks as the validating webhook, for installations running without it
+	core.PanicError(spec.Validate(), log.Error, "Custom resource validation failed")
+
 	var compound core.ExecutableCompound = &CassandraServicesCompound{}
+	steps := compound
+
+	if spec.Spec.Backup.Install && spec.Spec.Backup.BackupBeforeUpgrade {
+		// must go before any component is redeployed
+		compound.AddStep(&backup.PreUpgradeBackupStep{Timeout: time.Hour})
+		// the components are updated by the reconcile that finds the backup completed
+		steps = &backup.PreUpgradeBackupGate{}
+		compound.AddStep(steps)
+	}
+
+	if spec.Spec.TLS.Issuer != nil || spec.Spec.TLS.SelfSigned != nil {
+		steps.AddStep(&common.CertificatesStep{})
+	}
+
+	if spec.Spec.TLS.Enabled {
+		// the stores are built from the certificates above and removed when no component mounts them
+		steps.AddStep(&common.KeystoresStep{})
+	}
+
+	// the components start with their own service accounts unless the shared one is set
+	steps.AddStep(&common.ServiceAccountsStep{})
 
 	if spec.Spec.Backup.Install {
 		if spec.Spec.Backup.LegacyMode {
-			compound.AddStep((&backup.BackupBuilder{}).Build(ctx))
+			steps.AddStep((&backup.BackupBuilder{}).Build(ctx))
 		} else {
-			compound.AddStep((&backup.BackupBuilder{}).Build(ctx))
+			steps.AddStep((&backup.BackupBuilder{}).Build(ctx))
 		}
 	}
 
 	if spec.Spec.Dbaas.Install {
-		compound.AddStep((&dbaas.DbaasBuilder{}).Build(ctx))
+		steps.AddStep((&dbaas.DbaasBuilder{}).Build(ctx))
 	}
 
 	if spec.Spec.RobotTests.Install {
-		compound.AddStep((&robotTests.RobotBuilder{}).Build(ctx))
+		steps.AddStep((&robotTests.RobotBuilder{}).Build(ctx))
 	}
 	log.Debug("Cassandra Executable has been built")
 
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+)
+
+// CAKeyFileName is the key of the self-signed CA private key in the `tls.rootCASecretName` secret
+const CAKeyFileName = "ca.key"
+
+const selfSignedKeySize = 2048
+
+// SelfSignedDurations returns the CA and server certificate lifetimes and the renewal window of the `tls.selfSigned` mode
+func SelfSignedDurations(selfSigned v2.TLSSelfSigned) (caDuration, duration, renewBefore time.Duration, err error) {
+	parse := func(value, defaultValue string) time.Duration {
+		if err != nil {
+			return 0
+		}
+		var parsed time.Duration
+		parsed, err = time.ParseDuration(orDefault(value, defaultValue))
+		return parsed
+	}
+	caDuration = parse(selfSigned.CADuration, "87600h")
+	duration = parse(selfSigned.Duration, "8760h")
+	renewBefore = parse(selfSigned.RenewBefore, "720h")
+	return
+}
+
+// GenerateCA creates a self-signed CA certificate and its private key in PEM format
+func GenerateCA(commonName string, validity time.Duration) ([]byte, []byte, error) {
+	privateKey, err := generatePrivateKey(selfSignedKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := certificateTemplate(commonName, validity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCertificateToPEM(der), encodePrivateKeyToPEM(privateKey), nil
+}
+
+// GenerateServerCertificate creates a server certificate for the DNS names signed by the CA,
+// the certificate and its private key are returned in PEM format
+func GenerateServerCertificate(caCertPEM, caKeyPEM []byte, commonName string, dnsNames []string, validity time.Duration) ([]byte, []byte, error) {
+	ca, err := ParseCertificate(caCertPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyBlock, _ := pem.Decode(caKeyPEM)
+	if keyBlock == nil {
+		return nil, nil, errors.New("no PEM private key found")
+	}
+	caKey, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := generatePrivateKey(selfSignedKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := certificateTemplate(commonName, validity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.DNSNames = dnsNames
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &privateKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCertificateToPEM(der), encodePrivateKeyToPEM(privateKey), nil
+}
+
+// ParseCertificate returns the first certificate of the PEM data
+func ParseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// CertificateDue reports if the certificate is missing, invalid or expires within the renewal window
+func CertificateDue(certPEM []byte, renewBefore time.Duration, now time.Time) bool {
+	certificate, err := ParseCertificate(certPEM)
+	if err != nil {
+		return true
+	}
+	return now.Add(renewBefore).After(certificate.NotAfter)
+}
+
+func certificateTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate serial number: %w", err)
+	}
+	// backdated a little to tolerate clock skew between nodes
+	notBefore := time.Now().Add(-5 * time.Minute)
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(validity),
+	}, nil
+}
+
+func encodeCertificateToPEM(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
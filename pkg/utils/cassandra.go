@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	"github.com/Netcracker/qubership-cql-driver"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/vault"
+	"github.com/gocql/gocql"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewCassandraCluster connects to Cassandra with the admin credentials of the Cassandra secret,
+// the password is resolved through Vault when the registration is enabled
+func NewCassandraCluster(kubeClient client.Client, spec *v2.CassandraSupplService, namespace, keyspace string, consistency gocql.Consistency) (cql.Cluster, error) {
+	var user, pass string
+	if !CassandraPasswordless(spec.Spec) {
+		secret, err := core.ReadSecret(kubeClient, spec.Spec.Cassandra.SecretName, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", spec.Spec.Cassandra.SecretName, err)
+		}
+		user, pass = string(secret.Data[Username]), string(secret.Data[Password])
+		if spec.Spec.VaultRegistration.Enabled {
+			vaultHelper := vault.NewVaulterHelperImpl(vault.NewVaultClientImpl(&spec.Spec.VaultRegistration))
+			if pass, err = vaultHelper.ResolvePassword(pass); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	builder, err := WithCassandraClientCertificate(&CassandraClusterBuilder{}, kubeClient, spec.Spec, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return builder.
+		WithHost(core.OptionalString(spec.Spec.Cassandra.Host, fmt.Sprintf("%s.%s", Cassandra, namespace))).
+		WithUser(user).
+		WithPassword(func() string { return pass }).
+		WithRootCertPath(RootCertPath + spec.Spec.TLS.RootCAFileName).
+		WithTLSEnabled(spec.Spec.TLS.Enabled).
+		WithConnectTimeout(spec.Spec.GocqlConnectTimeout).
+		WithTimeout(spec.Spec.GocqlTimeout).
+		WithKeyspace(keyspace).
+		WithConsistency(consistency).Build(), nil
+}
+
+// CassandraClientCertificate reports if the components authenticate to Cassandra with the `cassandra.clientCertSecretName` certificate
+func CassandraClientCertificate(spec v2.CassandraServiceSpec) bool {
+	return spec.Cassandra.ClientCertSecretName != "" && spec.TLS.Enabled && !spec.AWSKeyspaces.Install
+}
+
+// CassandraPasswordless reports if Cassandra authenticates the components by the client certificate only
+func CassandraPasswordless(spec v2.CassandraServiceSpec) bool {
+	return CassandraClientCertificate(spec) && spec.Cassandra.PasswordlessAuth
+}
+
+// WithCassandraClientCertificate passes the `cassandra.clientCertSecretName` certificate to the builder, if one is set
+func WithCassandraClientCertificate(builder cql.ClusterBuilder, kubeClient client.Client, spec v2.CassandraServiceSpec, namespace string) (cql.ClusterBuilder, error) {
+	if !CassandraClientCertificate(spec) {
+		return builder, nil
+	}
+	certBuilder, ok := builder.(ClientCertClusterBuilder)
+	if !ok {
+		return nil, fmt.Errorf("cluster builder %T does not support client certificates", builder)
+	}
+	secret, err := core.ReadSecret(kubeClient, spec.Cassandra.ClientCertSecretName, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", spec.Cassandra.ClientCertSecretName, err)
+	}
+	return certBuilder.WithClientCertificate(secret.Data[spec.TLS.SignedCRTFileName], secret.Data[spec.TLS.PrivateKeyFileName]), nil
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// TLSComponent is a component serving HTTPS with a certificate from a secret
+type TLSComponent struct {
+	// Service and Deployment name, the certificate is issued for the Service
+	Name       string
+	SecretName string
+}
+
+// TLSComponents returns the installed components that serve HTTPS
+func TLSComponents(spec v2.CassandraServiceSpec) []TLSComponent {
+	var components []TLSComponent
+	if spec.Backup.Install && spec.TLS.Enabled {
+		components = append(components, TLSComponent{Name: BackupDaemon, SecretName: spec.Backup.TLS.BackupDaemonCASecretName})
+	}
+	if spec.Dbaas.Install && DbaasServerTLS(spec) {
+		components = append(components, TLSComponent{Name: DbaasName, SecretName: spec.Dbaas.TLS.DbaasAdapterCASecretName})
+	}
+	return components
+}
+
+// CertificateName is the cert-manager Certificate of the component
+func CertificateName(component TLSComponent) string {
+	return component.Name + "-tls"
+}
+
+// ServiceDNSNames returns the names a Service is reached by inside the cluster
+func ServiceDNSNames(service, namespace string) []string {
+	return []string{
+		service,
+		fmt.Sprintf("%s.%s", service, namespace),
+		fmt.Sprintf("%s.%s.svc", service, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+		"localhost",
+	}
+}
+
+// CertificateTemplate returns a cert-manager Certificate of the component issued by the `tls.issuer`
+func CertificateTemplate(component TLSComponent, namespace string, issuer v2.TLSIssuer) *unstructured.Unstructured {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(CertificateGVK)
+	certificate.SetName(CertificateName(component))
+	certificate.SetNamespace(namespace)
+
+	dnsNames := []interface{}{}
+	for _, name := range ServiceDNSNames(component.Name, namespace) {
+		dnsNames = append(dnsNames, name)
+	}
+	spec := map[string]interface{}{
+		"secretName": component.SecretName,
+		"commonName": component.Name,
+		"dnsNames":   dnsNames,
+		"privateKey": map[string]interface{}{
+			"algorithm":      "RSA",
+			"encoding":       "PKCS1",
+			"size":           int64(2048),
+			"rotationPolicy": "Always",
+		},
+		"usages": []interface{}{"digital signature", "key encipherment", "server auth"},
+		"issuerRef": map[string]interface{}{
+			"name":  issuer.Name,
+			"kind":  orDefault(issuer.Kind, "Issuer"),
+			"group": orDefault(issuer.Group, CertificateGVK.Group),
+		},
+	}
+	if issuer.Duration != "" {
+		spec["duration"] = issuer.Duration
+	}
+	if issuer.RenewBefore != "" {
+		spec["renewBefore"] = issuer.RenewBefore
+	}
+	certificate.Object["spec"] = spec
+	return certificate
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// WaitForCertificateSecret waits until the issuer stores the certificate to the secret
+func WaitForCertificateSecret(kubeClient client.Client, secretName, namespace string, timeout time.Duration) (*v1.Secret, error) {
+	secret := &v1.Secret{}
+	err := wait.PollUntilContextTimeout(context.TODO(), 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		err := kubeClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, secret)
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil && len(secret.Data[v1.TLSCertKey]) > 0, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certificate secret %s is not ready: %w", secretName, err)
+	}
+	return secret, nil
+}
+
+// TLSSecret is a secret with a certificate mounted into the component pods
+type TLSSecret struct {
+	Name string
+	// key of the certificate in the secret, empty for the keystores secret
+	CertificateKey string
+}
+
+// TLSConsumers returns the TLS secrets mounted into each deployment of the installed components
+// by TLSClientSpecUpdate, TLSServerSpecUpdate, DbaasAggregatorTLSSpecUpdate, CassandraClientCertSpecUpdate and KeystoresSpecUpdate
+func TLSConsumers(spec v2.CassandraServiceSpec) map[string][]TLSSecret {
+	consumers := map[string][]TLSSecret{}
+	add := func(deployment string, secret TLSSecret) {
+		if secret.Name != "" && !slices.Contains(consumers[deployment], secret) {
+			consumers[deployment] = append(consumers[deployment], secret)
+		}
+	}
+	rootCA := TLSSecret{Name: spec.TLS.RootCASecretName, CertificateKey: spec.TLS.RootCAFileName}
+	if spec.TLS.Enabled {
+		if spec.Backup.Install {
+			add(BackupDaemon, rootCA)
+		}
+		if spec.Dbaas.Install {
+			add(DbaasName, rootCA)
+		}
+		if spec.RobotTests.Install {
+			add(Robot, rootCA)
+		}
+	}
+	for _, component := range TLSComponents(spec) {
+		add(component.Name, TLSSecret{Name: component.SecretName, CertificateKey: spec.TLS.SignedCRTFileName})
+	}
+	if spec.Dbaas.Install && DbaasClientTLS(spec) {
+		add(DbaasName, rootCA)
+		add(DbaasName, TLSSecret{Name: spec.Dbaas.TLS.ClientCertSecretName, CertificateKey: spec.TLS.SignedCRTFileName})
+	}
+	if CassandraClientCertificate(spec) {
+		clientCert := TLSSecret{Name: spec.Cassandra.ClientCertSecretName, CertificateKey: spec.TLS.SignedCRTFileName}
+		for deployment, installed := range map[string]bool{BackupDaemon: spec.Backup.Install, DbaasName: spec.Dbaas.Install, Robot: spec.RobotTests.Install} {
+			if installed {
+				add(deployment, clientCert)
+			}
+		}
+	}
+	for deployment := range KeystoreConsumers(spec) {
+		add(deployment, TLSSecret{Name: KeystoresSecretName(spec.TLS)})
+	}
+	return consumers
+}
+
+// TLSSecretsHash identifies the content of the secrets, pods are rolled when it changes.
+// Secrets that do not exist yet are skipped.
+func TLSSecretsHash(kubeClient client.Client, secrets []TLSSecret, namespace string) (string, error) {
+	hash := sha256.New()
+	for _, tlsSecret := range secrets {
+		secret := &v1.Secret{}
+		err := kubeClient.Get(context.TODO(), types.NamespacedName{Name: tlsSecret.Name, Namespace: namespace}, secret)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(secret.Name))
+		for _, key := range slices.Sorted(maps.Keys(secret.Data)) {
+			hash.Write([]byte(key))
+			hash.Write(secret.Data[key])
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// AddCertificateHashToPodTemplate records the content of the TLS secrets mounted into the deployment in its pod template
+func AddCertificateHashToPodTemplate(kubeClient client.Client, template *v1.PodTemplateSpec, spec v2.CassandraServiceSpec, deployment, namespace string) error {
+	secrets := TLSConsumers(spec)[deployment]
+	if len(secrets) == 0 {
+		return nil
+	}
+	hash, err := TLSSecretsHash(kubeClient, secrets, namespace)
+	if err != nil {
+		return err
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[CertificateHashAnnotation] = hash
+	return nil
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/Netcracker/qubership-cql-driver"
+	"github.com/gocql/gocql"
+)
+
+// ClientCertClusterBuilder is a cql.ClusterBuilder that can authenticate with a client certificate over mutual TLS
+type ClientCertClusterBuilder interface {
+	cql.ClusterBuilder
+	WithClientCertificate(certPEM, keyPEM []byte) cql.ClusterBuilder
+}
+
+// CassandraClusterBuilder builds clusters the same way as cql.ClusterBuilderImpl, and with a client certificate
+// connects over mutual TLS. Without a username no password is sent, for clusters authenticating by the certificate only.
+// The driver keeps its gocql cluster and session unexported and has no client certificate option, so the cluster,
+// session, query and iterator wrappers below mirror the driver ones. They are to be dropped for a driver release
+// that accepts a client certificate.
+type CassandraClusterBuilder struct {
+	cql.ClusterBuilderImpl
+	ClientCertificate []byte
+	ClientKey         []byte
+}
+
+var _ ClientCertClusterBuilder = &CassandraClusterBuilder{}
+
+func (r *CassandraClusterBuilder) Build() cql.Cluster {
+	if len(r.ClientCertificate) == 0 {
+		return r.ClusterBuilderImpl.Build()
+	}
+
+	cluster := gocql.NewCluster(r.Host...)
+	if r.Port != 0 {
+		cluster.Port = r.Port
+	}
+	if r.User != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: r.User,
+			Password: r.Password(),
+		}
+	} else {
+		cluster.Authenticator = certificateAuthenticator{}
+	}
+	cluster.ProtoVersion = 4
+	cluster.Keyspace = r.Keyspace
+	cluster.Consistency = r.Consistency
+	cluster.Timeout = time.Duration(r.Timeout) * time.Second
+	cluster.ConnectTimeout = time.Duration(r.ConnectTimeout) * time.Second
+	if r.DCName != "" {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.DCAwareRoundRobinPolicy(r.DCName)
+	}
+
+	certificate, err := tls.X509KeyPair(r.ClientCertificate, r.ClientKey)
+	if err != nil {
+		return &clientCertCluster{err: fmt.Errorf("invalid Cassandra client certificate: %w", err)}
+	}
+	cluster.SslOpts = &gocql.SslOptions{
+		// the server certificate is not verified against the host name, as with cql.ClusterBuilderImpl
+		Config: &tls.Config{Certificates: []tls.Certificate{certificate}, InsecureSkipVerify: true},
+		CaPath: r.RootCertPath,
+	}
+	return &clientCertCluster{cluster: cluster}
+}
+
+func (r *CassandraClusterBuilder) WithClientCertificate(certPEM, keyPEM []byte) cql.ClusterBuilder {
+	r.ClientCertificate = certPEM
+	r.ClientKey = keyPEM
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithHost(host ...string) cql.ClusterBuilder {
+	r.Host = host
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithPort(port int) cql.ClusterBuilder {
+	r.Port = port
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithUser(user string) cql.ClusterBuilder {
+	r.User = user
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithPassword(password func() string) cql.ClusterBuilder {
+	r.Password = password
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithConsistency(consistency gocql.Consistency) cql.ClusterBuilder {
+	r.Consistency = consistency
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithKeyspace(keyspace string) cql.ClusterBuilder {
+	r.Keyspace = keyspace
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithConnectTimeout(connectTimeout int) cql.ClusterBuilder {
+	r.ConnectTimeout = connectTimeout
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithTimeout(timeout int) cql.ClusterBuilder {
+	r.Timeout = timeout
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithTLSEnabled(tlsEnabled bool) cql.ClusterBuilder {
+	r.TlsEnabled = tlsEnabled
+	return r
+}
+
+func (r *CassandraClusterBuilder) WithRootCertPath(rootCertPath string) cql.ClusterBuilder {
+	r.RootCertPath = rootCertPath
+	return r
+}
+
+// certificateAuthenticator answers the authentication request of a Cassandra authenticating clients by their certificate
+type certificateAuthenticator struct{}
+
+func (a certificateAuthenticator) Challenge(_ []byte) ([]byte, gocql.Authenticator, error) {
+	return []byte{}, nil, nil
+}
+
+func (a certificateAuthenticator) Success(_ []byte) error {
+	return nil
+}
+
+// clientCertCluster opens sessions of a cluster configured outside cql.ClusterBuilderImpl
+type clientCertCluster struct {
+	cluster *gocql.ClusterConfig
+	err     error
+}
+
+func (c *clientCertCluster) CreateSession() (cql.Session, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	session, err := c.cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	return &clientCertSession{session}, nil
+}
+
+type clientCertSession struct {
+	session *gocql.Session
+}
+
+func (s *clientCertSession) Query(stmt string, values ...interface{}) cql.QueryInterface {
+	return &clientCertQuery{s.session.Query(stmt, values...)}
+}
+
+func (s *clientCertSession) SetConsistency(consistency gocql.Consistency) {
+	s.session.SetConsistency(consistency)
+}
+
+func (s *clientCertSession) Close() {
+	s.session.Close()
+}
+
+type clientCertQuery struct {
+	query *gocql.Query
+}
+
+func (q *clientCertQuery) Iter() cql.IterInterface {
+	return &clientCertIter{q.query.Iter(), q.query.Statement()}
+}
+
+func (q *clientCertQuery) Exec(panicOnError bool) error {
+	if err := q.Iter().Close(); err != nil {
+		if panicOnError {
+			panic(fmt.Sprintf("Error during query execution %s: %v", q.query.Statement(), err))
+		}
+		return err
+	}
+	return nil
+}
+
+type clientCertIter struct {
+	iter      *gocql.Iter
+	statement string
+}
+
+func (i *clientCertIter) Scan(dest ...interface{}) bool {
+	return i.iter.Scan(dest...)
+}
+
+func (i *clientCertIter) RowData() (cql.RowData, error) {
+	result, err := i.iter.RowData()
+	if err != nil {
+		return cql.RowData{}, fmt.Errorf("Error during getting row data for statement %s, err: %v", i.statement, err)
+	}
+	return cql.RowData{RowData: result}, nil
+}
+
+func (i *clientCertIter) Close() error {
+	return i.iter.Close()
+}
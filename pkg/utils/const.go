@@ -11,6 +11,7 @@ const KubernetesHelperImpl = "kubernetesHelperImpl"
 const ContextClusterBuilder = "clusterBuilder"
 
 const ContextCredsManager = "contextCredsManager"
+const ContextBackupDaemonClientBuilder = "backupDaemonClientBuilder"
 
 const Name = "name"
 const Service = "service"
@@ -21,7 +22,6 @@ const Roles = "roles"
 
 const SSHSecret = "ssh-keys"
 
-
 const Microservice = "microservice"
 
 const TriesCount = "triesCount"
@@ -33,6 +33,43 @@ const Backup = "backup"
 const BackupDaemon = "cassandra-backup-daemon"
 const BackupStorage = "backup-storage"
 
+// backup daemon throttling config
+const BackupThrottlingConfig = "cassandra-backup-daemon-throttling"
+const BackupThrottlingConfigFile = "throttling.json"
+const BackupThrottlingConfigPath = "/opt/backup/throttling/"
+
+// CSI snapshot backups
+const SnapshotGroupLabel = "netcracker.com/snapshot-group"
+const SnapshotSourcePVCAnnotation = "netcracker.com/source-pvc"
+
+// backup catalog
+const BackupCatalog = "cassandra-backup-catalog"
+const LegalHoldLabelPrefix = "legal-hold.netcracker.com/"
+
+// backup replication PVC mount
+const BackupReplica = "backup-replica"
+const BackupReplicaPath = "/backup-replica"
+
+// backup volume storage class migration
+const BackupVolumeMigrationJob = "cassandra-backup-daemon-volume-migration"
+const MigratedFromAnnotation = "netcracker.com/migrated-from"
+const MigratedToAnnotation = "netcracker.com/migrated-to"
+
+// jobs copying backup files with rclone
+const BackupTransferJob = "cassandra-backup-transfer"
+const BackupReplicationJob = "cassandra-backup-replication"
+const ReplicatedBackupAnnotation = "netcracker.com/replicated-backup"
+
+// commitlog archiving
+const CommitlogArchivingConfig = "cassandra-commitlog-archiving"
+const CommitlogArchivingConfigFile = "commitlog_archiving.properties"
+const CommitlogShippingJob = "cassandra-commitlog-shipping-%s"
+const CommitlogStagingJob = "cassandra-commitlog-staging-%s"
+
+// schema backups
+const SchemaBackup = "cassandra-schema-backup"
+const SchemaVersionLabel = "netcracker.com/schema-version"
+
 var BackupEntrypoint = []string{"/opt/backup/run.sh"}
 
 const Robot = "robot-tests"
@@ -41,15 +78,40 @@ const Dbaas = "dbaas"
 
 const DbaasName = "dbaas-cassandra-adapter"
 
+const DbaasPhysicalDatabasesLabels = "nc-dbaas-physical-databases-labels"
+const DbaasPhysicalDatabasesLabelsKey = "dbaas.physical_databases.registration.labels.json"
+const DbaasPhysicalDatabasesLabelsHash = "netcracker.com/physical-databases-labels-hash"
+
+const HostnameTopologyKey = "kubernetes.io/hostname"
+const ZoneTopologyKey = "topology.kubernetes.io/zone"
+
 const RootCert = "root-ca"
 const RootCertPath = "/usr/ssl/"
 
 const ServerCertsPath = "/certs/"
 
+// the plain HTTP port a component keeps in the `tls.optional` mode
+const PlainHTTPPortName = "plain-http"
+
+// hash of the TLS secrets the pods were started with
+const CertificateHashAnnotation = "netcracker.com/tls-certificate-hash"
+
+const AggregatorCerts = "aggregator-certs"
+const AggregatorCertsPath = "/aggregator-certs/"
+
+const CassandraClientCerts = "cassandra-client-certs"
+const CassandraClientCertsPath = "/cassandra-client-certs/"
+
 const AccessKey = "accessKey"
 const SecretKey = "secretKey"
 const Region = "region"
 
+// AWS Keyspaces accepts TLS connections on 9142 only
+const AWSKeyspacesPort = 9142
+
+// cluster-topology robot suites are excluded against AWS Keyspaces
+const AWSKeyspacesExcludedTags = "topology"
+
 var RobotEntrypoint = []string{"/docker-entrypoint.sh"}
 
 const Charset = "abcdefghijklmnopqrstuvwxyz" +
@@ -57,6 +119,12 @@ const Charset = "abcdefghijklmnopqrstuvwxyz" +
 
 const DbaasAdminRoleCreds = "dbaas-streaming-role"
 
+// new streaming role password kept in the secret until the role is altered with it
+const PendingPassword = "pendingPassword"
+
+// time of the last streaming role password rotation, also rolls the adapter
+const PasswordRotatedAnnotation = "netcracker.com/password-rotated-at"
+
 // labels
 const (
 	AppName              = "app.kubernetes.io/name"
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EvictionRule is a single `<start>/<interval>` item of the backup daemon eviction policy,
+// e.g. `3d/7d` keeps one backup per 7 days for backups older than 3 days, `1y/delete` removes backups older than a year.
+type EvictionRule struct {
+	Start    time.Duration
+	Interval time.Duration
+	Delete   bool
+}
+
+// NoEvictionPolicy keeps every backup: one backup per second is kept from the start,
+// and the backup daemon ids are timestamps with seconds, so no two backups share a second.
+// An empty policy is not a documented way to disable the daemon eviction.
+const NoEvictionPolicy = "0/1s"
+
+var evictionPeriodRegexp = regexp.MustCompile(`^(\d+)(s|min|h|d|w|m|y)?$`)
+
+var evictionPeriodUnits = map[string]time.Duration{
+	"":    time.Second,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+	"w":   7 * 24 * time.Hour,
+	"m":   30 * 24 * time.Hour,
+	"y":   365 * 24 * time.Hour,
+}
+
+func parseEvictionPeriod(value string) (time.Duration, error) {
+	match := evictionPeriodRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("invalid period %q", value)
+	}
+	amount, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid period %q: %w", value, err)
+	}
+	return time.Duration(amount) * evictionPeriodUnits[match[2]], nil
+}
+
+// ParseEvictionPolicy parses the backup daemon eviction policy, e.g. `0/1h,3d/7d,1m/1m,1y/delete`.
+// Rules are returned sorted by start.
+func ParseEvictionPolicy(policy string) ([]EvictionRule, error) {
+	var rules []EvictionRule
+	for _, item := range strings.Split(policy, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.Split(item, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid eviction rule %q, expected <start>/<interval>", item)
+		}
+		start, err := parseEvictionPeriod(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		rule := EvictionRule{Start: start}
+		if strings.TrimSpace(parts[1]) == "delete" {
+			rule.Delete = true
+		} else if rule.Interval, err = parseEvictionPeriod(parts[1]); err != nil {
+			return nil, err
+		} else if rule.Interval == 0 {
+			return nil, fmt.Errorf("invalid eviction rule %q, interval must be positive", item)
+		}
+		rules = append(rules, rule)
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Start < rules[j].Start })
+	return rules, nil
+}
+
+// EvictedItems returns indexes of items that should be removed according to the rules.
+// The newest item in each interval is kept, the newest item overall is never evicted.
+func EvictedItems(times []time.Time, rules []EvictionRule, now time.Time) []int {
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return times[order[i]].After(times[order[j]]) })
+
+	var evicted []int
+	kept := map[string]bool{}
+	for position, index := range order {
+		age := now.Sub(times[index])
+		ruleIndex := -1
+		for i, rule := range rules {
+			if rule.Start <= age {
+				ruleIndex = i
+			}
+		}
+		if ruleIndex < 0 {
+			continue
+		}
+
+		rule := rules[ruleIndex]
+		bucket := ""
+		if !rule.Delete {
+			bucket = fmt.Sprintf("%d/%d", ruleIndex, (age-rule.Start)/rule.Interval)
+		}
+		if position > 0 && (rule.Delete || kept[bucket]) {
+			evicted = append(evicted, index)
+		} else {
+			kept[bucket] = true
+		}
+	}
+	sort.Ints(evicted)
+	return evicted
+}
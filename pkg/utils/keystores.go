@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	coreUtils "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const KeystoresPath = "/keystores/"
+
+const TruststoreFileName = "truststore.p12"
+
+// KeystorePassKey is the key of `tls.keystorePass` in the keystores secret, the pods read it from there
+const KeystorePassKey = "keystore-pass"
+
+// KeystoresSourceHashAnnotation identifies the TLS secrets and the password the keystores were built from
+const KeystoresSourceHashAnnotation = "netcracker.com/tls-source-hash"
+
+const keystoresVolume = "tls-keystores"
+
+// Keystore is a PKCS12 keystore built from the certificate and private key of a TLS secret
+type Keystore struct {
+	FileName   string
+	SecretName string
+}
+
+// KeystoresSecretName returns the secret the operator stores the keystores and truststore to
+func KeystoresSecretName(tls v2.TLS) string {
+	return orDefault(tls.KeystoresSecretName, "cassandra-tls-keystores")
+}
+
+// KeystoreConsumers returns the deployments that mount the keystores with the keystore each of them uses,
+// a deployment with an empty keystore file name gets the truststore only
+func KeystoreConsumers(spec v2.CassandraServiceSpec) map[string]Keystore {
+	consumers := map[string]Keystore{}
+	if !spec.TLS.Enabled {
+		return consumers
+	}
+	if spec.Backup.Install && spec.Backup.MountKeystores {
+		consumers[BackupDaemon] = Keystore{FileName: BackupDaemon + ".p12", SecretName: spec.Backup.TLS.BackupDaemonCASecretName}
+	}
+	if spec.RobotTests.Install && spec.RobotTests.MountKeystores {
+		// the self-signed CA secret holds no client certificate
+		var keystore Keystore
+		if spec.TLS.SelfSigned == nil {
+			keystore = Keystore{FileName: "keystore.p12", SecretName: spec.TLS.RootCASecretName}
+		}
+		consumers[Robot] = keystore
+	}
+	return consumers
+}
+
+// EncodeTruststore returns a PKCS12 truststore of the PEM certificates
+func EncodeTruststore(caPEM []byte, password string) ([]byte, error) {
+	certificates, err := ParseCertificates(caPEM)
+	if err != nil {
+		return nil, err
+	}
+	return pkcs12.Modern.EncodeTrustStore(certificates, password)
+}
+
+// EncodeKeystore returns a PKCS12 keystore of the PEM private key and certificate chain, the CA certificates complete the chain
+func EncodeKeystore(certPEM, keyPEM, caPEM []byte, password string) ([]byte, error) {
+	chain, err := ParseCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := ParsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	caCertificates := chain[1:]
+	if cas, err := ParseCertificates(caPEM); err == nil {
+		caCertificates = append(caCertificates, cas...)
+	}
+	return pkcs12.Modern.Encode(privateKey, chain[0], caCertificates, password)
+}
+
+// ParseCertificates returns all certificates of the PEM data
+func ParseCertificates(certPEM []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certificates, nil
+}
+
+// ParsePrivateKey returns the PKCS1, PKCS8 or EC private key of the PEM data
+func ParsePrivateKey(keyPEM []byte) (interface{}, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key format")
+}
+
+// KeystoresSpecUpdate mounts the keystores secret to the deployment and points the Java tooling to the stores
+func KeystoresSpecUpdate(depl *v1.PodSpec, spec v2.CassandraServiceSpec, deployment string) {
+	keystore, ok := KeystoreConsumers(spec)[deployment]
+	if !ok {
+		return
+	}
+	secretName := KeystoresSecretName(spec.TLS)
+	depl.Volumes = append(depl.Volumes, v1.Volume{
+		Name: keystoresVolume,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: secretName},
+		},
+	})
+	depl.Containers[0].VolumeMounts = append(depl.Containers[0].VolumeMounts, v1.VolumeMount{
+		Name:      keystoresVolume,
+		ReadOnly:  true,
+		MountPath: KeystoresPath,
+	})
+	depl.Containers[0].Env = append(depl.Containers[0].Env,
+		coreUtils.GetPlainTextEnvVar("TLS_KEYSTORE_TYPE", "PKCS12"),
+		coreUtils.GetPlainTextEnvVar("TLS_TRUSTSTORE", KeystoresPath+TruststoreFileName),
+		coreUtils.GetSecretEnvVar("TLS_KEYSTORE_PASSWORD", secretName, KeystorePassKey),
+	)
+	if keystore.FileName != "" {
+		depl.Containers[0].Env = append(depl.Containers[0].Env,
+			coreUtils.GetPlainTextEnvVar("TLS_KEYSTORE", KeystoresPath+keystore.FileName))
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const HealthPath = "/health"
+
+// liveness and readiness start after the startup probe, so they need no initial delay
+var (
+	defaultLivenessProbe  = v2.ProbeTimings{TimeoutSeconds: 5, PeriodSeconds: 10, SuccessThreshold: 1, FailureThreshold: 6}
+	defaultReadinessProbe = v2.ProbeTimings{TimeoutSeconds: 5, PeriodSeconds: 10, SuccessThreshold: 1, FailureThreshold: 3}
+	// up to five minutes for the initial startup
+	defaultStartupProbe = v2.ProbeTimings{InitialDelaySeconds: 5, TimeoutSeconds: 5, PeriodSeconds: 10, SuccessThreshold: 1, FailureThreshold: 30}
+)
+
+// HTTPHealthProbes sets the startup, liveness and readiness probes of the container to the health endpoint.
+// HTTPS is used when the component listens on the TLS port.
+func HTTPHealthProbes(container *v1.Container, port int32, probes v2.Probes) {
+	container.StartupProbe = httpProbe(port, probes.Startup, defaultStartupProbe)
+	container.LivenessProbe = httpProbe(port, probes.Liveness, defaultLivenessProbe)
+	container.ReadinessProbe = httpProbe(port, probes.Readiness, defaultReadinessProbe)
+}
+
+func httpProbe(port int32, timings, defaults v2.ProbeTimings) *v1.Probe {
+	scheme := v1.URISchemeHTTP
+	if port == GetHTTPPort(true) {
+		scheme = v1.URISchemeHTTPS
+	}
+	orDefault := func(value, defaultValue int32) int32 {
+		if value > 0 {
+			return value
+		}
+		return defaultValue
+	}
+	return &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path:   HealthPath,
+				Port:   intstr.FromInt32(port),
+				Scheme: scheme,
+			},
+		},
+		InitialDelaySeconds: orDefault(timings.InitialDelaySeconds, defaults.InitialDelaySeconds),
+		TimeoutSeconds:      orDefault(timings.TimeoutSeconds, defaults.TimeoutSeconds),
+		PeriodSeconds:       orDefault(timings.PeriodSeconds, defaults.PeriodSeconds),
+		SuccessThreshold:    orDefault(timings.SuccessThreshold, defaults.SuccessThreshold),
+		FailureThreshold:    orDefault(timings.FailureThreshold, defaults.FailureThreshold),
+	}
+}
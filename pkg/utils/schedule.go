@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	// the operator image has no zoneinfo, the blackout window time zones are resolved from the embedded database
+	_ "time/tzdata"
+)
+
+// CronSchedule is a standard 5-field cron expression as used by the backup daemon schedules
+type CronSchedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	// day of month and day of week restrictions are combined with OR if both are set
+	anyDay     bool
+	anyWeekday bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCronSchedule parses `<minute> <hour> <day of month> <month> <day of week>` with lists, ranges and steps
+func ParseCronSchedule(expression string) (*CronSchedule, error) {
+	if macro, found := cronMacros[strings.TrimSpace(expression)]; found {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q, expected 5 fields", expression)
+	}
+
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+		}
+		sets[i] = set
+	}
+	// both 0 and 7 are Sunday
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+
+	return &CronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if parts := strings.SplitN(item, "/", 2); len(parts) == 2 {
+			rangePart = parts[0]
+			value, err := strconv.Atoi(parts[1])
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", item)
+			}
+			step = value
+		}
+
+		from, to := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			value, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %q", item)
+			}
+			from, to = value, value
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value in %q", item)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+		}
+		for value := from; value <= to; value += step {
+			set[value] = true
+		}
+	}
+	return set, nil
+}
+
+// Matches reports if the schedule fires at the minute of t
+func (s *CronSchedule) Matches(t time.Time) bool {
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+	day, weekday := s.days[t.Day()], s.weekdays[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// BlackoutWindow is a daily time range in a time zone during which scheduled backups are deferred.
+// The range may cross midnight, e.g. `22:00`-`06:00`, then it belongs to the day it starts at.
+type BlackoutWindow struct {
+	Days     map[time.Weekday]bool
+	Start    int
+	End      int
+	Location *time.Location
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseBlackoutWindow parses `HH:MM` bounds, day names and IANA time zone, UTC if empty. No days mean every day.
+func ParseBlackoutWindow(days []string, start, end, timeZone string) (*BlackoutWindow, error) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	window := &BlackoutWindow{Days: map[time.Weekday]bool{}, Location: location}
+	if window.Start, err = parseClock(start); err != nil {
+		return nil, err
+	}
+	if window.End, err = parseClock(end); err != nil {
+		return nil, err
+	}
+	if window.Start == window.End {
+		return nil, fmt.Errorf("blackout window %s-%s is empty", start, end)
+	}
+	for _, day := range days {
+		weekday, found := weekdayNames[strings.ToLower(strings.TrimSpace(day))]
+		if !found {
+			return nil, fmt.Errorf("invalid day %q", day)
+		}
+		window.Days[weekday] = true
+	}
+	return window, nil
+}
+
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+func (w *BlackoutWindow) onDay(weekday time.Weekday) bool {
+	return len(w.Days) == 0 || w.Days[weekday]
+}
+
+// Contains reports if t is inside the window
+func (w *BlackoutWindow) Contains(t time.Time) bool {
+	local := t.In(w.Location)
+	minute := local.Hour()*60 + local.Minute()
+	if w.Start < w.End {
+		return w.onDay(local.Weekday()) && minute >= w.Start && minute < w.End
+	}
+	return (w.onDay(local.Weekday()) && minute >= w.Start) ||
+		(w.onDay((local.Weekday()+6)%7) && minute < w.End)
+}
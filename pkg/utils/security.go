@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"slices"
+
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RestrictedUID is the user and group the `restricted` profile runs the pods with unless the security context sets them
+const RestrictedUID int64 = 1000
+
+// RestrictedWritablePaths are backed by emptyDir volumes in the `restricted` profile, the root filesystem is read-only
+var RestrictedWritablePaths = []v1.VolumeMount{
+	{Name: "tmp", MountPath: "/tmp"},
+}
+
+// componentSecurityContexts returns the security context overrides of the component the pod belongs to
+func componentSecurityContexts(spec v2.CassandraServiceSpec, component string) (*v1.PodSecurityContext, *v1.SecurityContext) {
+	switch component {
+	case BackupDaemon, BackupVolumeMigrationJob, BackupTransferJob:
+		return spec.Backup.SecurityContext, spec.Backup.ContainerSecurityContext
+	case DbaasName:
+		return spec.Dbaas.SecurityContext, spec.Dbaas.ContainerSecurityContext
+	case Robot:
+		return spec.RobotTests.SecurityContext, spec.RobotTests.ContainerSecurityContext
+	}
+	return nil, nil
+}
+
+// PodSecurityContext returns the component pod security context, or the shared one,
+// completed with the `restricted` profile settings that are not set explicitly
+func PodSecurityContext(spec v2.CassandraServiceSpec, component string) *v1.PodSecurityContext {
+	podContext, _ := componentSecurityContexts(spec, component)
+	if podContext == nil {
+		podContext = spec.PodSecurityContext
+	}
+	if spec.SecurityProfile != v2.RestrictedSecurityProfile {
+		return podContext
+	}
+
+	if podContext == nil {
+		podContext = &v1.PodSecurityContext{}
+	} else {
+		podContext = podContext.DeepCopy()
+	}
+	if podContext.RunAsNonRoot == nil {
+		podContext.RunAsNonRoot = ptr(true)
+	}
+	if podContext.RunAsUser == nil {
+		podContext.RunAsUser = ptr(RestrictedUID)
+	}
+	if podContext.RunAsGroup == nil {
+		podContext.RunAsGroup = ptr(RestrictedUID)
+	}
+	if podContext.FSGroup == nil {
+		podContext.FSGroup = ptr(RestrictedUID)
+	}
+	if podContext.SeccompProfile == nil {
+		podContext.SeccompProfile = &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault}
+	}
+	return podContext
+}
+
+// ContainerSecurityContext returns the component container security context, by default all capabilities are dropped.
+// The `restricted` profile completes it with the settings that are not set explicitly.
+func ContainerSecurityContext(spec v2.CassandraServiceSpec, component string) *v1.SecurityContext {
+	_, containerContext := componentSecurityContexts(spec, component)
+	if containerContext == nil {
+		containerContext = &v1.SecurityContext{
+			Capabilities:             &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+			AllowPrivilegeEscalation: ptr(false),
+		}
+	} else {
+		containerContext = containerContext.DeepCopy()
+	}
+	if spec.SecurityProfile == v2.RestrictedSecurityProfile {
+		restrictContainer(containerContext)
+	}
+	return containerContext
+}
+
+func restrictContainer(containerContext *v1.SecurityContext) {
+	if containerContext.Capabilities == nil {
+		containerContext.Capabilities = &v1.Capabilities{}
+	}
+	// an override dropping some capabilities only still drops all of them
+	if !slices.Contains(containerContext.Capabilities.Drop, "ALL") {
+		containerContext.Capabilities.Drop = append(containerContext.Capabilities.Drop, "ALL")
+	}
+	if containerContext.AllowPrivilegeEscalation == nil {
+		containerContext.AllowPrivilegeEscalation = ptr(false)
+	}
+	if containerContext.ReadOnlyRootFilesystem == nil {
+		containerContext.ReadOnlyRootFilesystem = ptr(true)
+	}
+	if containerContext.RunAsNonRoot == nil {
+		containerContext.RunAsNonRoot = ptr(true)
+	}
+}
+
+// SecurityContextSpecUpdate sets the security contexts of the component pod. In the `restricted` profile
+// init containers are hardened too and the writable paths are mounted from emptyDir volumes.
+func SecurityContextSpecUpdate(depl *v1.PodSpec, spec v2.CassandraServiceSpec, component string) {
+	depl.SecurityContext = PodSecurityContext(spec, component)
+	for i := range depl.Containers {
+		depl.Containers[i].SecurityContext = ContainerSecurityContext(spec, component)
+	}
+	if spec.SecurityProfile != v2.RestrictedSecurityProfile {
+		return
+	}
+
+	for i := range depl.InitContainers {
+		if depl.InitContainers[i].SecurityContext == nil {
+			depl.InitContainers[i].SecurityContext = &v1.SecurityContext{}
+		}
+		restrictContainer(depl.InitContainers[i].SecurityContext)
+	}
+	for _, mount := range RestrictedWritablePaths {
+		if hasVolume(depl, mount.Name) {
+			continue
+		}
+		depl.Volumes = append(depl.Volumes, v1.Volume{
+			Name:         mount.Name,
+			VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+		})
+		for i := range depl.Containers {
+			depl.Containers[i].VolumeMounts = append(depl.Containers[i].VolumeMounts, mount)
+		}
+	}
+}
+
+func hasVolume(depl *v1.PodSpec, name string) bool {
+	for _, volume := range depl.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
+)
+
+// InstalledComponents returns the deployments of the installed components
+func InstalledComponents(spec v2.CassandraServiceSpec) []string {
+	var components []string
+	if spec.Backup.Install {
+		components = append(components, BackupDaemon)
+	}
+	if spec.Dbaas.Install {
+		components = append(components, DbaasName)
+	}
+	if spec.RobotTests.Install {
+		components = append(components, Robot)
+	}
+	return components
+}
+
+// ServiceAccountName returns the shared `serviceAccountName` if set, otherwise the account the operator creates
+// for the component. The backup volume migration and transfer jobs run as the backup daemon.
+func ServiceAccountName(spec v2.CassandraServiceSpec, component string) string {
+	if spec.ServiceAccountName != "" {
+		return spec.ServiceAccountName
+	}
+	if component == BackupVolumeMigrationJob || component == BackupTransferJob {
+		return BackupDaemon
+	}
+	return component
+}
@@ -1,17 +1,20 @@
 package utils
 
 import (
+	"fmt"
 	v2 "github.com/Netcracker/qubership-cassandra-supplementary/api/v1alpha1"
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/constants"
 	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/core"
+	"github.com/Netcracker/qubership-nosqldb-operator-core/pkg/types"
 	coreUtils "github.com/Netcracker/qubership-nosqldb-operator-core/pkg/utils"
 	v11 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"net/url"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strconv"
-	"strings"
+	"time"
 )
 
 type BasicLabels struct {
@@ -56,8 +59,8 @@ func CreateRuntimeObjectContextWrapper(
 			tolerations = spec.Spec.Policies.Tolerations
 		}
 		obj.Spec.Template.Spec.Tolerations = tolerations
-		obj.Spec.Template.Spec.SecurityContext = spec.Spec.PodSecurityContext
-		obj.Spec.Template.Spec.ServiceAccountName = spec.Spec.ServiceAccountName
+		SecurityContextSpecUpdate(&obj.Spec.Template.Spec, spec.Spec, obj.Name)
+		obj.Spec.Template.Spec.ServiceAccountName = ServiceAccountName(spec.Spec, obj.Name)
 		obj.Spec.Template.Spec.PriorityClassName = spec.Spec.Dbaas.PriorityClassName
 		for _, container := range obj.Spec.Template.Spec.Containers {
 			container.ImagePullPolicy = spec.Spec.ImagePullPolicy
@@ -162,6 +165,18 @@ func TLSServerSpecUpdate(depl *v1.PodSpec, tls v2.TLS, secretName, mountPath str
 		coreUtils.GetPlainTextEnvVar("INTERNAL_TLS_KEY_FILENAME", mountPath+tls.PrivateKeyFileName),
 		coreUtils.GetPlainTextEnvVar("INTERNAL_TLS_PATH", mountPath),
 	)
+
+	if tls.Optional {
+		depl.Containers[0].Ports = append(depl.Containers[0].Ports, v1.ContainerPort{
+			Name:          PlainHTTPPortName,
+			ContainerPort: GetHTTPPort(false),
+			Protocol:      v1.ProtocolTCP,
+		})
+		depl.Containers[0].Env = append(depl.Containers[0].Env,
+			coreUtils.GetPlainTextEnvVar("INTERNAL_TLS_OPTIONAL", "true"),
+			coreUtils.GetPlainTextEnvVar("INTERNAL_PLAIN_HTTP_PORT", fmt.Sprint(GetHTTPPort(false))),
+		)
+	}
 }
 
 func GetHTTPPort(tlsEnabled bool) int32 {
@@ -179,10 +194,187 @@ func GetHTTPProtocol(tlsEnabled bool) string {
 	return "http"
 }
 
-func IsTLSEnableForDBAAS(aggregatorRegistrationAddress string, tlsEnabled bool) bool {
-	if !strings.Contains(aggregatorRegistrationAddress, "https") {
+// HTTPServicePorts returns the named Service ports of a component,
+// in the `tls.optional` mode the plain HTTP port stays open next to the TLS one for clients not migrated yet
+func HTTPServicePorts(tlsEnabled bool, tls v2.TLS) map[string]int32 {
+	ports := map[string]int32{"http": GetHTTPPort(tlsEnabled)}
+	if tlsEnabled && tls.Optional {
+		ports[PlainHTTPPortName] = GetHTTPPort(false)
+	}
+	return ports
+}
+
+func BackupDaemonAddress(namespace string, tlsEnabled bool) string {
+	return fmt.Sprintf("%s://%s.%s:%d", GetHTTPProtocol(tlsEnabled), BackupDaemon, namespace, GetHTTPPort(tlsEnabled))
+}
+
+// DbaasServerTLS reports whether the adapter serves its API over HTTPS
+func DbaasServerTLS(spec v2.CassandraServiceSpec) bool {
+	if enabled := spec.Dbaas.TLS.Server; enabled != nil {
+		return *enabled
+	}
+	// custom resources without the setting enabled HTTPS together with an HTTPS aggregator
+	return spec.TLS.Enabled && aggregatorHTTPS(spec)
+}
+
+// DbaasClientTLS reports whether DBaaS aggregator is reached over TLS
+func DbaasClientTLS(spec v2.CassandraServiceSpec) bool {
+	if enabled := spec.Dbaas.TLS.Client; enabled != nil {
+		return *enabled
+	}
+	return aggregatorHTTPS(spec)
+}
+
+func aggregatorHTTPS(spec v2.CassandraServiceSpec) bool {
+	if spec.Dbaas.Aggregator == nil {
 		return false
 	}
+	address, err := url.Parse(spec.Dbaas.Aggregator.DbaasAggregatorRegistrationAddress)
+	return err == nil && address.Scheme == "https"
+}
+
+// DbaasAggregatorTLSSpecUpdate mounts the CA the aggregator certificate is verified with and the mutual TLS
+// client certificate to the adapter
+func DbaasAggregatorTLSSpecUpdate(depl *v1.PodSpec, spec v2.CassandraServiceSpec) {
+	if !DbaasClientTLS(spec) {
+		return
+	}
+	tls := spec.TLS
+	var sources []v1.VolumeProjection
+	envs := []v1.EnvVar{coreUtils.GetPlainTextEnvVar("DBAAS_AGGREGATOR_TLS_ENABLED", "true")}
+	// without a root CA the aggregator certificate is verified with the system trust store
+	if tls.RootCASecretName != "" {
+		sources = append(sources, v1.VolumeProjection{
+			Secret: &v1.SecretProjection{
+				LocalObjectReference: v1.LocalObjectReference{Name: tls.RootCASecretName},
+				Items:                []v1.KeyToPath{{Key: tls.RootCAFileName, Path: tls.RootCAFileName}},
+			},
+		})
+		envs = append(envs, coreUtils.GetPlainTextEnvVar("DBAAS_AGGREGATOR_TLS_ROOTCERT", AggregatorCertsPath+tls.RootCAFileName))
+	}
+	if secretName := spec.Dbaas.TLS.ClientCertSecretName; secretName != "" {
+		sources = append(sources, v1.VolumeProjection{
+			Secret: &v1.SecretProjection{
+				LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+				Items: []v1.KeyToPath{
+					{Key: tls.SignedCRTFileName, Path: tls.SignedCRTFileName},
+					{Key: tls.PrivateKeyFileName, Path: tls.PrivateKeyFileName},
+				},
+			},
+		})
+		envs = append(envs,
+			coreUtils.GetPlainTextEnvVar("DBAAS_AGGREGATOR_TLS_CERTIFICATE_FILENAME", AggregatorCertsPath+tls.SignedCRTFileName),
+			coreUtils.GetPlainTextEnvVar("DBAAS_AGGREGATOR_TLS_KEY_FILENAME", AggregatorCertsPath+tls.PrivateKeyFileName),
+		)
+	}
+
+	depl.Containers[0].Env = append(depl.Containers[0].Env, envs...)
+	if len(sources) == 0 {
+		return
+	}
+	depl.Volumes = append(depl.Volumes, v1.Volume{
+		Name:         AggregatorCerts,
+		VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: sources}},
+	})
+	depl.Containers[0].VolumeMounts = append(depl.Containers[0].VolumeMounts, v1.VolumeMount{
+		Name:      AggregatorCerts,
+		ReadOnly:  true,
+		MountPath: AggregatorCertsPath,
+	})
+}
+
+// CassandraClientCertSpecUpdate mounts the client certificate the component authenticates to Cassandra with
+func CassandraClientCertSpecUpdate(depl *v1.PodSpec, spec v2.CassandraServiceSpec) {
+	if !CassandraClientCertificate(spec) {
+		return
+	}
+	tls := spec.TLS
+	depl.Volumes = append(depl.Volumes, v1.Volume{
+		Name: CassandraClientCerts,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: spec.Cassandra.ClientCertSecretName,
+				Items: []v1.KeyToPath{
+					{Key: tls.SignedCRTFileName, Path: tls.SignedCRTFileName},
+					{Key: tls.PrivateKeyFileName, Path: tls.PrivateKeyFileName},
+				},
+			},
+		},
+	})
+	depl.Containers[0].VolumeMounts = append(depl.Containers[0].VolumeMounts, v1.VolumeMount{
+		Name:      CassandraClientCerts,
+		ReadOnly:  true,
+		MountPath: CassandraClientCertsPath,
+	})
+	depl.Containers[0].Env = append(depl.Containers[0].Env,
+		coreUtils.GetPlainTextEnvVar("TLS_CLIENT_CERTIFICATE_FILENAME", CassandraClientCertsPath+tls.SignedCRTFileName),
+		coreUtils.GetPlainTextEnvVar("TLS_CLIENT_KEY_FILENAME", CassandraClientCertsPath+tls.PrivateKeyFileName),
+	)
+}
 
-	return tlsEnabled
+// CassandraCredentialEnvs returns the Cassandra role credentials of the component,
+// in the password-less mode the component authenticates by the client certificate instead
+func CassandraCredentialEnvs(spec v2.CassandraServiceSpec) []v1.EnvVar {
+	if CassandraPasswordless(spec) {
+		return []v1.EnvVar{coreUtils.GetPlainTextEnvVar("CASSANDRA_AUTH", "certificate")}
+	}
+	return []v1.EnvVar{
+		coreUtils.GetSecretEnvVar("CASSANDRA_USERNAME", spec.Cassandra.SecretName, Username),
+		coreUtils.GetSecretEnvVar("CASSANDRA_PASSWORD", spec.Cassandra.SecretName, Password),
+	}
+}
+
+// AWSKeyspacesEnvs returns the regional endpoint and the SigV4 credentials of AWS Keyspaces.
+// Host and port variable names differ between components, so they are passed by the caller.
+func AWSKeyspacesEnvs(keyspaces v2.AWSKeyspaces, hostEnv, portEnv string) []v1.EnvVar {
+	return []v1.EnvVar{
+		coreUtils.GetPlainTextEnvVar("AWS_KEYSPACES", "true"),
+		coreUtils.GetPlainTextEnvVar(hostEnv, keyspaces.Host),
+		coreUtils.GetPlainTextEnvVar(portEnv, strconv.Itoa(AWSKeyspacesPort)),
+		coreUtils.GetPlainTextEnvVar("CASSANDRA_AUTH", "sigv4"),
+		coreUtils.GetSecretEnvVar("AWS_ACCESS_KEY", keyspaces.SecretName, AccessKey),
+		coreUtils.GetSecretEnvVar("AWS_SECRET_KEY", keyspaces.SecretName, SecretKey),
+		coreUtils.GetSecretEnvVar("AWS_REGION", keyspaces.SecretName, Region),
+	}
+}
+
+// CassandraSecretNames returns the secrets with credentials of the target cluster, none in the password-less mode
+func CassandraSecretNames(spec v2.CassandraServiceSpec) []string {
+	if spec.AWSKeyspaces.Install {
+		return []string{spec.AWSKeyspaces.SecretName}
+	}
+	if CassandraPasswordless(spec) {
+		return nil
+	}
+	return []string{spec.Cassandra.SecretName}
+}
+
+// CassandraConsistency returns the configured consistency, AWS Keyspaces accepts LOCAL_QUORUM writes only
+func CassandraConsistency(spec v2.CassandraServiceSpec) string {
+	if spec.AWSKeyspaces.Install {
+		return core.OptionalString(spec.Cassandra.Consistency, v2.AWSKeyspacesConsistency)
+	}
+	return core.OptionalString(spec.Cassandra.Consistency, "QUORUM")
+}
+
+// SetCondition adds the condition or replaces the existing one with the same type.
+// The transition time is kept if the condition status is not changed.
+func SetCondition(conditions []types.ServiceStatusCondition, condition types.ServiceStatusCondition) []types.ServiceStatusCondition {
+	for i, existing := range conditions {
+		if existing.Type == condition.Type {
+			if existing.Status == condition.Status {
+				condition.LastTransitionTime = existing.LastTransitionTime
+			}
+			conditions[i] = condition
+			return conditions
+		}
+	}
+	return append(conditions, condition)
+}
+
+func ParseDurationOrDefault(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
 }